@@ -0,0 +1,48 @@
+// Package cmd Check Spectra top level command
+package cmd
+
+import (
+	"os"
+
+	"github.com/Nesvilab/philosopher/lib/chk"
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/sys"
+
+	"github.com/spf13/cobra"
+)
+
+// checkSpectraCmd represents the check-spectra command
+var checkSpectraCmd = &cobra.Command{
+	Use:   "check-spectra",
+	Short: "Check the integrity of mzML files before processing them",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		m.FunctionInitCheckUp()
+
+		msg.Executing("Check spectra ", Version)
+
+		chk.Run(m.CheckSpectra, args)
+
+		// store parameters on meta data
+		m.Serialize()
+
+		// clean tmp
+		met.CleanTemp(m.Temp)
+
+		msg.Done()
+	},
+}
+
+func init() {
+
+	if len(os.Args) > 1 && os.Args[1] == "check-spectra" {
+
+		m.Restore(sys.Meta())
+
+		checkSpectraCmd.Flags().StringVarP(&m.CheckSpectra.Dir, "dir", "", "", "folder containing the mzML files")
+		checkSpectraCmd.Flags().IntSliceVarP(&m.CheckSpectra.MSLevel, "mslevel", "", []int{1, 2}, "MS levels every file must have")
+	}
+
+	RootCmd.AddCommand(checkSpectraCmd)
+}
@@ -0,0 +1,56 @@
+// Package cmd Convert top level command
+package cmd
+
+import (
+	"os"
+
+	"github.com/Nesvilab/philosopher/lib/cnv"
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/sys"
+
+	"github.com/spf13/cobra"
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert Thermo RAW and other spectra files to indexed mzML",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		m.FunctionInitCheckUp()
+
+		msg.Executing("Convert ", Version)
+
+		cnv.Run(m.Msconvert, Version, args)
+
+		// store parameters on meta data
+		m.Serialize()
+
+		// clean tmp
+		met.CleanTemp(m.Temp)
+
+		msg.Done()
+	},
+}
+
+func init() {
+
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+
+		m.Restore(sys.Meta())
+
+		convertCmd.Flags().StringVarP(&m.Msconvert.Output, "output", "", "", "folder for the mzML files, the default is the folder of each input file")
+		convertCmd.Flags().StringVarP(&m.Msconvert.Format, "format", "", "mzML", "output file format (mzML)")
+		convertCmd.Flags().StringVarP(&m.Msconvert.MZBinaryEncoding, "mzEncoding", "", "64", "m/z array precision (32, 64)")
+		convertCmd.Flags().StringVarP(&m.Msconvert.IntensityBinaryEncoding, "intEncoding", "", "32", "intensity array precision (32, 64)")
+		convertCmd.Flags().BoolVarP(&m.Msconvert.Zlib, "zlib", "", true, "compress the binary arrays with zlib")
+		convertCmd.Flags().BoolVarP(&m.Msconvert.NoIndex, "noindex", "", false, "write mzML without the spectrum index")
+		convertCmd.Flags().IntSliceVarP(&m.Msconvert.MSLevel, "mslevel", "", []int{}, "MS levels to keep, all levels are written by default (e.g. 1,2)")
+		convertCmd.Flags().BoolVarP(&m.Msconvert.PeakPicking, "peakpicking", "", false, "write centroided peaks instead of the profile data, RAW files use the instrument centroids")
+		convertCmd.Flags().StringVarP(&m.Msconvert.PeakApex, "apex", "", "gaussian", "apex fitting used for peak picking (gaussian, parabolic)")
+		convertCmd.Flags().Float64VarP(&m.Msconvert.SignalToNoise, "snr", "", 0, "minimum signal to noise ratio of the picked peaks")
+	}
+
+	RootCmd.AddCommand(convertCmd)
+}
@@ -37,16 +37,29 @@ func init() {
 
 		databaseCmd.Flags().StringVarP(&m.Database.ID, "id", "", "", "UniProt proteome ID")
 		databaseCmd.Flags().StringVarP(&m.Database.Annot, "annotate", "", "", "process a ready-to-use database")
-		databaseCmd.Flags().StringVarP(&m.Database.Enz, "enzyme", "", "trypsin", "enzyme for digestion (trypsin, lys_c, lys_n, glu_c, chymotrypsin)")
+		databaseCmd.Flags().StringVarP(&m.Database.Enz, "enzyme", "", "trypsin", "enzyme for digestion, combine enzymes with + (trypsin, trypsin/p, lys_c, lys_n, arg_c, asp_n, glu_c, chymotrypsin)")
+		databaseCmd.Flags().StringVarP(&m.Database.EnzymeFile, "enzyme-file", "", "", "YAML or TOML (.toml) file with user-defined enzymes")
 		databaseCmd.Flags().StringVarP(&m.Database.Tag, "prefix", "", "rev_", "define a decoy prefix")
+		databaseCmd.Flags().StringVarP(&m.Database.DecoyMethod, "decoy-method", "", "reverse", "decoy generation method (reverse, pseudo-reverse, shuffle)")
+		databaseCmd.Flags().Int64VarP(&m.Database.DecoySeed, "decoy-seed", "", 0, "random seed for shuffled decoys (0 draws a new seed)")
 		databaseCmd.Flags().StringVarP(&m.Database.Add, "add", "", "", "add custom sequences (UniProt FASTA format only)")
 		databaseCmd.Flags().StringVarP(&m.Database.Custom, "custom", "", "", "use a pre-formatted custom database")
-		databaseCmd.Flags().BoolVarP(&m.Database.Crap, "contam", "", false, "add common contaminants")
+		databaseCmd.Flags().StringVarP(&m.Database.UniProtFile, "uniprot-file", "", "", "use a local UniProtKB flat file (.dat) or XML file instead of downloading the proteome, also adds its annotations with --annotate")
+		databaseCmd.Flags().StringVarP(&m.Database.Variants, "variants", "", "", "add variant proteins from a table of amino acid variants, indels and novel ORFs")
+		databaseCmd.Flags().StringVarP(&m.Database.Entrapment, "entrapment", "", "", "add the sequences of a foreign proteome as entrapment targets")
+		databaseCmd.Flags().StringVarP(&m.Database.EntrapTag, "entrapment-tag", "", "", "define the entrapment prefix (default entrap_ when --entrapment is set)")
+		databaseCmd.Flags().BoolVarP(&m.Database.Crap, "contam", "", false, "add common contaminants, with --annotate flags entries identical to a common contaminant")
+		databaseCmd.Flags().StringVarP(&m.Database.ContamFile, "contam-file", "", "", "comma-separated FASTA files with custom contaminant sequences")
 		databaseCmd.Flags().BoolVarP(&m.Database.CrapTag, "contamprefix", "", false, "mark the contaminant sequences with a prefix tag")
 		databaseCmd.Flags().BoolVarP(&m.Database.Rev, "reviewed", "", false, "use only reviwed sequences from Swiss-Prot")
 		databaseCmd.Flags().BoolVarP(&m.Database.Iso, "isoform", "", false, "add isoform sequences")
 		databaseCmd.Flags().BoolVarP(&m.Database.NoD, "nodecoys", "", false, "don't add decoys to the database")
 		databaseCmd.Flags().BoolVarP(&m.Database.Verbose, "verbose", "", false, "debug the sequence classification for each FASTA record")
+		databaseCmd.Flags().StringVarP(&m.Database.HeaderFile, "header-file", "", "", "YAML file with custom FASTA header formats")
+		databaseCmd.Flags().StringVarP(&m.Database.HeaderFormat, "header-format", "", "", "parse all FASTA headers with the given header format, a format from --header-file or the built-in gencode")
+		databaseCmd.Flags().BoolVarP(&m.Database.Stats, "stats", "", false, "show statistics of the workspace database, or of the --annotate file")
+		databaseCmd.Flags().StringVarP(&m.Database.Diff, "diff", "", "", "compare two comma-separated databases (db.bin, workspace or FASTA), or one against the workspace database")
+		databaseCmd.Flags().BoolVarP(&m.Database.DebugHeaders, "debug-headers", "", false, "write a report showing how each FASTA header was parsed")
 	}
 
 	RootCmd.AddCommand(databaseCmd)
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/Nesvilab/philosopher/lib/fil"
+	"github.com/Nesvilab/philosopher/lib/id"
 	"github.com/Nesvilab/philosopher/lib/met"
 	"github.com/Nesvilab/philosopher/lib/msg"
 	"github.com/Nesvilab/philosopher/lib/sys"
@@ -35,11 +36,11 @@ var filterCmd = &cobra.Command{
 		m.Quantify = met.Quantify{}
 
 		// check file existence
-		if len(m.Filter.Pex) < 1 {
+		if len(m.Filter.Pex) < 1 && len(m.Filter.Percolator) < 1 && len(m.Filter.MzID) < 1 && len(m.Filter.MaxQuant) < 1 && len(m.Filter.Sage) < 1 {
 			msg.InputNotFound(errors.New("you must provide a pepXML file or a folder with one or more files, Run 'philosopher filter --help' for more information"), "fatal")
 		}
 
-		if len(m.Filter.Pox) == 0 && m.Filter.Razor {
+		if len(m.Filter.Pox) == 0 && len(id.MaxQuantProteinGroups(m.Filter.MaxQuant)) == 0 && m.Filter.Razor {
 			msg.Custom(errors.New("razor option will be ignored because there is no protein inference data"), "warning")
 			m.Filter.Razor = false
 		}
@@ -62,6 +63,11 @@ func init() {
 		m.Restore(sys.Meta())
 
 		filterCmd.Flags().StringVarP(&m.Filter.Pex, "pepxml", "", "", "pepXML file or directory containing a set of pepXML files")
+		filterCmd.Flags().StringVarP(&m.Filter.Percolator, "percolator", "", "", "Percolator results file or directory with the target and decoy results, used instead of pepXML")
+		filterCmd.Flags().StringVarP(&m.Filter.MzID, "mzid", "", "", "mzIdentML file or directory containing a set of mzIdentML files, used instead of pepXML")
+		filterCmd.Flags().StringVarP(&m.Filter.MaxQuant, "maxquant", "", "", "MaxQuant txt folder with the msms.txt or evidence.txt, parameters.txt and proteinGroups.txt files, used instead of pepXML and protXML")
+		filterCmd.Flags().StringVarP(&m.Filter.Sage, "sage", "", "", "Sage results.sage.tsv file or the directory containing it, used instead of pepXML")
+		filterCmd.Flags().BoolVarP(&m.Filter.Rescore, "rescore", "", false, "rescore the PSMs with a semi-supervised target-decoy model and use its probabilities instead of the search engine or PeptideProphet ones")
 		filterCmd.Flags().StringVarP(&m.Filter.Pox, "protxml", "", "", "protXML file path")
 		filterCmd.Flags().StringVarP(&m.Filter.Tag, "tag", "", "rev_", "decoy tag")
 		filterCmd.Flags().StringVarP(&m.Filter.Mods, "mods", "", "", "list of modifications for a stratified FDR filtering")
@@ -22,26 +22,29 @@ var freequant = &cobra.Command{
 
 		m.FunctionInitCheckUp()
 
-		m.Quantify.Format = "mzML"
 		if len(m.Quantify.Dir) < 1 {
 			msg.InputNotFound(errors.New("you need to provide the path to the mz files and the correct extension"), "fatal")
 		}
 
 		msg.Executing("Label-free quantification ", Version)
 
+		// Thermo RAW files are read natively
+		if m.Quantify.Raw {
+			m.Quantify.Format = "raw"
+		}
+
 		if strings.EqualFold(m.Quantify.Format, "mzml") {
 			m.Quantify.Format = "mzML"
 		} else if strings.EqualFold(m.Quantify.Format, "mzxml") {
-			msg.InputNotFound(errors.New("only the mzML format is supported"), "fatal")
 			m.Quantify.Format = "mzXML"
+		} else if strings.EqualFold(m.Quantify.Format, "raw") {
+			m.Quantify.Format = "raw"
+		} else if strings.EqualFold(m.Quantify.Format, "mgf") {
+			msg.InputNotFound(errors.New("MGF files have no MS1 scans, label-free quantification needs mzML or mzXML files"), "fatal")
 		} else {
 			msg.InputNotFound(errors.New("unknown file format"), "fatal")
 		}
 
-		if m.Quantify.Raw {
-			msg.Custom(errors.New("support for Thermo raw files was temporarily removed, please convert your files to mzML"), "fatal")
-		}
-
 		//forcing the larger time window to be the same as the smaller one
 		//m.Quantify.RTWin = 3
 		m.Quantify.RTWin = m.Quantify.PTWin
@@ -66,10 +69,13 @@ func init() {
 		m.Restore(sys.Meta())
 
 		freequant.Flags().StringVarP(&m.Quantify.Dir, "dir", "", "", "folder path containing the raw files")
+		freequant.Flags().StringVarP(&m.Quantify.Format, "format", "", "mzML", "spectra file format (mzML, mzXML, raw)")
 		freequant.Flags().Float64VarP(&m.Quantify.Tol, "tol", "", 10, "m/z tolerance in ppm")
 		freequant.Flags().Float64VarP(&m.Quantify.PTWin, "ptw", "", 0.4, "specify the time windows for the peak (minute)")
 		freequant.Flags().BoolVarP(&m.Quantify.Raw, "raw", "", false, "read raw files instead of converted XML")
 		freequant.Flags().BoolVarP(&m.Quantify.Faims, "faims", "", false, "Use FAIMS information for the quantification")
+		freequant.Flags().StringVarP(&m.Quantify.PeakApex, "apex", "", "gaussian", "apex fitting used to centroid profile spectra (gaussian, parabolic)")
+		freequant.Flags().Float64VarP(&m.Quantify.SNR, "snr", "", 0, "minimum signal to noise ratio of the peaks picked from profile spectra")
 	}
 
 	RootCmd.AddCommand(freequant)
@@ -0,0 +1,49 @@
+// Package cmd Genome top level command
+package cmd
+
+import (
+	"os"
+
+	"github.com/Nesvilab/philosopher/lib/gen"
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/sys"
+
+	"github.com/spf13/cobra"
+)
+
+// genomeCmd represents the genome command
+var genomeCmd = &cobra.Command{
+	Use:   "genome",
+	Short: "Map identified peptides to genomic coordinates",
+	Run: func(cmd *cobra.Command, args []string) {
+
+		m.FunctionInitCheckUp()
+
+		msg.Executing("Genome ", Version)
+
+		m = gen.Run(m)
+
+		// store parameters on meta data
+		m.Serialize()
+
+		// clean tmp
+		met.CleanTemp(m.Temp)
+
+		msg.Done()
+	},
+}
+
+func init() {
+
+	if len(os.Args) > 1 && os.Args[1] == "genome" {
+
+		m.Restore(sys.Meta())
+
+		genomeCmd.Flags().StringVarP(&m.Genome.Annotation, "annotation", "", "", "GTF or GFF3 annotation file with the CDS features")
+		genomeCmd.Flags().StringVarP(&m.Genome.Fasta, "fasta", "", "", "FASTA file with the transcript translations")
+		genomeCmd.Flags().StringVarP(&m.Genome.Tag, "tag", "", "rev_", "decoy tag")
+	}
+
+	RootCmd.AddCommand(genomeCmd)
+}
@@ -22,8 +22,6 @@ var labelquantCmd = &cobra.Command{
 
 		m.FunctionInitCheckUp()
 
-		m.Quantify.Format = "mzML"
-
 		if len(m.Quantify.Format) < 1 || len(m.Quantify.Dir) < 1 {
 			msg.InputNotFound(errors.New("you need to provide the path to the mz files and the correct extension"), "fatal")
 		}
@@ -34,19 +32,26 @@ var labelquantCmd = &cobra.Command{
 
 		msg.Executing("Isobaric-label quantification ", Version)
 
+		// Thermo RAW files are read natively
+		if m.Quantify.Raw {
+			m.Quantify.Format = "raw"
+		}
+
 		if strings.EqualFold(strings.ToLower(m.Quantify.Format), "mzml") {
 			m.Quantify.Format = "mzML"
 		} else if strings.EqualFold(m.Quantify.Format, "mzxml") {
-			msg.InputNotFound(errors.New("only the mzML format is supported"), "fatal")
 			m.Quantify.Format = "mzXML"
+		} else if strings.EqualFold(m.Quantify.Format, "raw") {
+			m.Quantify.Format = "raw"
+		} else if strings.EqualFold(m.Quantify.Format, "mgf") {
+			m.Quantify.Format = "mgf"
+			if m.Quantify.Level == 3 {
+				msg.InputNotFound(errors.New("MGF files have no MS level information, MS3 quantification needs mzML or mzXML files"), "fatal")
+			}
 		} else {
 			msg.InputNotFound(errors.New("unknown file format"), "fatal")
 		}
 
-		if m.Quantify.Raw {
-			msg.Custom(errors.New("support for Thermo raw files was temporarily removed, please convert your files to mzML"), "fatal")
-		}
-
 		m.Quantify = qua.RunIsobaricLabelQuantification(m.Quantify, m.Filter.Mapmods)
 
 		// store parameters on meta data
@@ -68,6 +73,7 @@ func init() {
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Annot, "annot", "", "", "annotation file with custom names for the TMT channels")
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Plex, "plex", "", "", "number of reporter ion channels")
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Dir, "dir", "", "", "folder path containing the raw files")
+		labelquantCmd.Flags().StringVarP(&m.Quantify.Format, "format", "", "mzML", "spectra file format (mzML, mzXML, mgf, raw)")
 		labelquantCmd.Flags().StringVarP(&m.Quantify.Brand, "brand", "", "", "isobaric labeling brand (tmt, itraq, sCLIP)")
 		labelquantCmd.Flags().Float64VarP(&m.Quantify.Tol, "tol", "", 20, "m/z tolerance in ppm")
 		labelquantCmd.Flags().IntVarP(&m.Quantify.Level, "level", "", 2, "ms level for the quantification")
@@ -77,6 +83,8 @@ func init() {
 		labelquantCmd.Flags().BoolVarP(&m.Quantify.Unique, "uniqueonly", "", false, "report quantification based only on unique peptides")
 		labelquantCmd.Flags().BoolVarP(&m.Quantify.BestPSM, "bestpsm", "", false, "select the best PSMs for protein quantification")
 		labelquantCmd.Flags().BoolVarP(&m.Quantify.Raw, "raw", "", false, "read raw files instead of converted XML")
+		labelquantCmd.Flags().StringVarP(&m.Quantify.PeakApex, "apex", "", "gaussian", "apex fitting used to centroid profile spectra (gaussian, parabolic)")
+		labelquantCmd.Flags().Float64VarP(&m.Quantify.SNR, "snr", "", 0, "minimum signal to noise ratio of the peaks picked from profile spectra")
 
 	}
 
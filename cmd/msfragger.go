@@ -22,6 +22,9 @@ var msfraggerCmd = &cobra.Command{
 
 		msg.Executing("MSFragger ", Version)
 
+		// explicit cleavage rules take precedence over the ones of the registered enzyme
+		msfragger.SetEnzymes(&m, cmd.Flags().Changed)
+
 		m := msfragger.Run(m, args)
 
 		m.Serialize()
@@ -64,7 +67,7 @@ func init() {
 		msfraggerCmd.Flags().StringVarP(&m.MSFragger.PrecursorMassMode, "precursor_mass_mode", "", "selected", "")
 		msfraggerCmd.Flags().StringVarP(&m.MSFragger.FragmentIonSeries, "fragment_ion_series", "", "b,y", "Ion series used in search, specify any of a,b,c,x,y,z,b~,y~,Y,b-18,y-18 (comma separated)")
 		msfraggerCmd.Flags().StringVarP(&m.MSFragger.IonSeriesDefinitions, "ion_series_definitions", "", "", "User defined ion series. (Example: b* N -17.026548;b0 N -18.010565)")
-		msfraggerCmd.Flags().StringVarP(&m.MSFragger.SearchEnzymeName1, "search_enzyme_name_1", "", "Trypsin", "Name of the first enzyme.")
+		msfraggerCmd.Flags().StringVarP(&m.MSFragger.SearchEnzymeName1, "search_enzyme_name_1", "", "Trypsin", "Name of the first enzyme. Registered enzymes (see database --enzyme) fill in the cleavage rules.")
 		msfraggerCmd.Flags().StringVarP(&m.MSFragger.SearchEnzymeCut1, "search_enzyme_cut_1", "", "KR", "First enzyme's cutting amino acid.")
 		msfraggerCmd.Flags().StringVarP(&m.MSFragger.SearchEnzymeNocut1, "search_enzyme_nocut_1", "", "P", "First enzyme's protecting amino acid.")
 		msfraggerCmd.Flags().IntVarP(&m.MSFragger.AllowedMissedCleavage1, "allowed_missed_cleavage_1", "", 2, "First enzyme's allowed number of missed cleavages per peptide. Maximum value is 5.")
@@ -0,0 +1,207 @@
+package bio
+
+import (
+	"sort"
+	"strings"
+)
+
+// digestion specificity modes
+const (
+	Specific     = "specific"
+	SemiSpecific = "semi"
+	NonSpecific  = "nonspecific"
+)
+
+// Digestion holds the in-silico digestion rules, a zero limit means no limit
+type Digestion struct {
+	Enzyme          Enzyme
+	Specificity     string
+	MissedCleavages int
+	MinLength       int
+	MaxLength       int
+	MinMass         float64
+	MaxMass         float64
+	ClipNTermM      bool
+}
+
+// Peptide is a digestion product, Start and End are 1-based and inclusive
+type Peptide struct {
+	Sequence        string
+	Start           int
+	End             int
+	MissedCleavages int
+	MonoIsotopeMass float64
+}
+
+var residueMass map[byte]float64
+
+func init() {
+
+	residueMass = make(map[byte]float64)
+
+	names := []string{"Alanine", "Arginine", "Asparagine", "Aspartic Acid", "Cysteine", "Glutamine", "Glutamic Acid", "Glycine", "Histidine", "Isoleucine",
+		"Leucine", "Lysine", "Methionine", "Phenylalanine", "Proline", "Serine", "Threonine", "Tryptophan", "Tyrosine", "Valine"}
+
+	for _, i := range names {
+		aa := New(i)
+		residueMass[aa.Code[0]] = aa.MonoIsotopeMass
+	}
+}
+
+// PeptideMass returns the neutral monoisotopic mass of an unmodified peptide,
+// the second value is false when the sequence has residues without a defined mass
+func PeptideMass(seq string) (float64, bool) {
+
+	var mass = Water
+
+	for i := 0; i < len(seq); i++ {
+		m, ok := residueMass[seq[i]]
+		if !ok {
+			return 0, false
+		}
+		mass += m
+	}
+
+	return mass, true
+}
+
+// IsCleavageSite reports if the enzyme cuts the sequence between positions i-1 and i
+func (e Enzyme) IsCleavageSite(seq string, i int) bool {
+
+	if i <= 0 || i >= len(seq) {
+		return false
+	}
+
+	for _, r := range e.Rules {
+		if r.Sense == "N" {
+			if strings.IndexByte(r.Cut, seq[i]) != -1 && strings.IndexByte(r.NoCut, seq[i-1]) == -1 {
+				return true
+			}
+		} else if strings.IndexByte(r.Cut, seq[i-1]) != -1 && strings.IndexByte(r.NoCut, seq[i]) == -1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CleavageSites returns the sequence boundaries produced by the enzyme, including both protein termini
+func (e Enzyme) CleavageSites(seq string) []int {
+
+	var sites = []int{0}
+
+	for i := 1; i < len(seq); i++ {
+		if e.IsCleavageSite(seq, i) {
+			sites = append(sites, i)
+		}
+	}
+
+	return append(sites, len(seq))
+}
+
+// Digest cleaves the protein sequence and returns the peptides that pass the digestion limits
+func (d Digestion) Digest(seq string) []Peptide {
+
+	seq = strings.ToUpper(seq)
+
+	var peptides []Peptide
+	var seen = make(map[[2]int]struct{})
+
+	add := func(start, end int) {
+
+		if _, ok := seen[[2]int{start, end}]; ok {
+			return
+		}
+
+		if l := end - start; l < 1 || l < d.MinLength || (d.MaxLength > 0 && l > d.MaxLength) {
+			return
+		}
+
+		mass, ok := PeptideMass(seq[start:end])
+		if !ok || mass < d.MinMass || (d.MaxMass > 0 && mass > d.MaxMass) {
+			return
+		}
+
+		seen[[2]int{start, end}] = struct{}{}
+		peptides = append(peptides, Peptide{
+			Sequence:        seq[start:end],
+			Start:           start + 1,
+			End:             end,
+			MissedCleavages: d.missed(seq, start, end),
+			MonoIsotopeMass: mass,
+		})
+	}
+
+	if d.Specificity == NonSpecific {
+		for i := 0; i < len(seq); i++ {
+			for j := i + 1; j <= len(seq); j++ {
+				if d.MaxLength > 0 && j-i > d.MaxLength {
+					break
+				}
+				add(i, j)
+			}
+		}
+		sortPeptides(peptides)
+		return peptides
+	}
+
+	sites := d.Enzyme.CleavageSites(seq)
+
+	// the initiator methionine removal adds an alternative protein N-terminus
+	var starts = []int{0}
+	if d.ClipNTermM && strings.HasPrefix(seq, "M") && len(seq) > 1 {
+		starts = append(starts, 1)
+	}
+
+	for i := 0; i < len(sites)-1; i++ {
+		for j := i + 1; j < len(sites) && j-i-1 <= d.MissedCleavages; j++ {
+
+			var begin = []int{sites[i]}
+			if i == 0 {
+				begin = starts
+			}
+
+			for _, b := range begin {
+
+				add(b, sites[j])
+
+				if d.Specificity != SemiSpecific {
+					continue
+				}
+
+				// semi-specific peptides keep one enzymatic terminus
+				for k := b + 1; k < sites[j]; k++ {
+					add(b, k)
+					add(k, sites[j])
+				}
+			}
+		}
+	}
+
+	sortPeptides(peptides)
+
+	return peptides
+}
+
+// missed counts the internal cleavage sites of a peptide
+func (d Digestion) missed(seq string, start, end int) int {
+
+	var n int
+
+	for i := start + 1; i < end; i++ {
+		if d.Enzyme.IsCleavageSite(seq, i) {
+			n++
+		}
+	}
+
+	return n
+}
+
+func sortPeptides(p []Peptide) {
+	sort.Slice(p, func(i, j int) bool {
+		if p[i].Start == p[j].Start {
+			return p[i].End < p[j].End
+		}
+		return p[i].Start < p[j].Start
+	})
+}
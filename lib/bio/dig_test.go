@@ -0,0 +1,170 @@
+package bio
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPeptideMass(t *testing.T) {
+
+	// PEPTIDE monoisotopic neutral mass
+	got, ok := PeptideMass("PEPTIDE")
+	if !ok || math.Abs(got-799.35996) > 0.0001 {
+		t.Errorf("PeptideMass() = %v, want %v", got, 799.35996)
+	}
+
+	if _, ok := PeptideMass("PEPTXDE"); ok {
+		t.Errorf("PeptideMass() should fail on undefined residues")
+	}
+}
+
+func TestDigestion_Digest(t *testing.T) {
+
+	var trypsin Enzyme
+	trypsin.Synth("trypsin")
+
+	var lysn Enzyme
+	lysn.Synth("lys_n")
+
+	tests := []struct {
+		name string
+		dig  Digestion
+		seq  string
+		want []string
+	}{
+		{
+			name: "Testing fully specific digestion",
+			dig:  Digestion{Enzyme: trypsin, Specificity: Specific},
+			seq:  "MAKPEKAARGG",
+			want: []string{"MAKPEK", "AAR", "GG"},
+		},
+		{
+			name: "Testing missed cleavages and methionine clipping",
+			dig:  Digestion{Enzyme: trypsin, Specificity: Specific, MissedCleavages: 1, ClipNTermM: true},
+			seq:  "MAKPEKAARGG",
+			want: []string{"MAKPEK", "MAKPEKAAR", "AKPEK", "AKPEKAAR", "AAR", "AARGG", "GG"},
+		},
+		{
+			name: "Testing length limits",
+			dig:  Digestion{Enzyme: trypsin, Specificity: Specific, MissedCleavages: 2, MinLength: 4, MaxLength: 6},
+			seq:  "MAKPEKAARGG",
+			want: []string{"MAKPEK", "AARGG"},
+		},
+		{
+			name: "Testing N-terminal cleavage",
+			dig:  Digestion{Enzyme: lysn, Specificity: Specific},
+			seq:  "AAKGGKC",
+			want: []string{"AA", "KGG", "KC"},
+		},
+		{
+			name: "Testing semi-specific digestion",
+			dig:  Digestion{Enzyme: trypsin, Specificity: SemiSpecific, MinLength: 3},
+			seq:  "ACDKEFG",
+			want: []string{"ACD", "ACDK", "CDK", "EFG"},
+		},
+		{
+			name: "Testing non-specific digestion",
+			dig:  Digestion{Enzyme: trypsin, Specificity: NonSpecific, MinLength: 2, MaxLength: 2},
+			seq:  "ACDK",
+			want: []string{"AC", "CD", "DK"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.dig.Digest(tt.seq)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Digest() returned %d peptides, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i].Sequence != tt.want[i] {
+					t.Errorf("Digest() peptide %d = %v, want %v", i, got[i].Sequence, tt.want[i])
+				}
+				if tt.seq[got[i].Start-1:got[i].End] != got[i].Sequence {
+					t.Errorf("Digest() peptide %s has wrong positions %d-%d", got[i].Sequence, got[i].Start, got[i].End)
+				}
+			}
+		})
+	}
+}
+
+func TestGetEnzyme(t *testing.T) {
+
+	e, ok := GetEnzyme("Trypsin+Lys-N")
+	if !ok || e.Name != "trypsin+lys_n" || len(e.Rules) != 2 {
+		t.Fatalf("GetEnzyme() = %v, want a combined trypsin+lys_n enzyme", e)
+	}
+
+	got := Digestion{Enzyme: e, Specificity: Specific}.Digest("AAKGGRCC")
+	want := []string{"AA", "K", "GGR", "CC"}
+	if len(got) != len(want) {
+		t.Fatalf("Digest() returned %d peptides, want %d: %v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i].Sequence != want[i] {
+			t.Errorf("Digest() peptide %d = %v, want %v", i, got[i].Sequence, want[i])
+		}
+	}
+
+	if _, ok := GetEnzyme("trypsin+unknown"); ok {
+		t.Errorf("GetEnzyme() should not resolve undefined enzymes")
+	}
+}
+
+func TestLoadEnzymes(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "enzymes.yml")
+	content := "enzymes:\n  - name: My-Enzyme\n    rules:\n      - cut: w\n        nocut: p\n        sense: c\n"
+	if e := os.WriteFile(file, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	LoadEnzymes(file)
+
+	e, ok := GetEnzyme("my_enzyme")
+	if !ok {
+		t.Fatalf("LoadEnzymes() did not register the enzyme")
+	}
+
+	if e.Pattern != "W[^P]" || e.Join != "W" {
+		t.Errorf("LoadEnzymes() pattern = %s and join = %s, want W[^P] and W", e.Pattern, e.Join)
+	}
+}
+
+func TestLoadEnzymesTOML(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "enzymes.toml")
+	content := "# user enzymes\n[[enzymes]]\nname = \"Double-Cut\"\n\n  [[enzymes.rules]]\n  cut = \"w\" # tryptophan\n  nocut = \"p\"\n\n  [[enzymes.rules]]\n  cut = \"d\"\n  sense = \"n\"\n"
+	if e := os.WriteFile(file, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	LoadEnzymes(file)
+
+	e, ok := GetEnzyme("double_cut")
+	if !ok {
+		t.Fatalf("LoadEnzymes() did not register the TOML enzyme")
+	}
+
+	if len(e.Rules) != 2 || e.Rules[0] != (CleavageRule{Cut: "W", NoCut: "P", Sense: "C"}) || e.Rules[1] != (CleavageRule{Cut: "D", Sense: "N"}) {
+		t.Errorf("LoadEnzymes() rules = %+v", e.Rules)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"rules before an enzyme", "[[enzymes.rules]]\ncut = \"K\"\n"},
+		{"unquoted value", "[[enzymes]]\nname = trypsin\n"},
+		{"unknown key", "[[enzymes]]\nname = \"a\"\n[[enzymes.rules]]\nsite = \"K\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, e := parseEnzymeTOML([]byte(tt.content)); e == nil {
+				t.Errorf("parseEnzymeTOML() accepted %q", tt.content)
+			}
+		})
+	}
+}
@@ -1,44 +1,293 @@
 package bio
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Nesvilab/philosopher/lib/msg"
+	"gopkg.in/yaml.v2"
 )
 
+// CleavageRule defines the residues where an enzyme cuts, the residues that block the cut
+// when found on the other side of the bond, and the side of the cut residue (C or N)
+type CleavageRule struct {
+	Cut   string `yaml:"cut"`
+	NoCut string `yaml:"nocut"`
+	Sense string `yaml:"sense"`
+}
+
 // Enzyme struct
 type Enzyme struct {
-	Name    string
-	Pattern string
-	Join    string
+	Name    string         `yaml:"name"`
+	Pattern string         `yaml:"-"`
+	Join    string         `yaml:"-"`
+	Rules   []CleavageRule `yaml:"rules"`
+}
+
+// EnzymeFile is the layout of a user-defined enzyme file
+type EnzymeFile struct {
+	Enzymes []Enzyme `yaml:"enzymes"`
+}
+
+var enzymeRegistry = make(map[string]Enzyme)
+
+var enzymeAliases = map[string]string{
+	"lysc":          "lys_c",
+	"lysn":          "lys_n",
+	"gluc":          "glu_c",
+	"argc":          "arg_c",
+	"aspn":          "asp_n",
+	"trypsin_p":     "trypsin/p",
+	"stricttrypsin": "trypsin/p",
+}
+
+func init() {
+	RegisterEnzyme(Enzyme{Name: "trypsin", Rules: []CleavageRule{{Cut: "KR", NoCut: "P", Sense: "C"}}})
+	RegisterEnzyme(Enzyme{Name: "trypsin/p", Rules: []CleavageRule{{Cut: "KR", Sense: "C"}}})
+	RegisterEnzyme(Enzyme{Name: "lys_c", Rules: []CleavageRule{{Cut: "K", NoCut: "P", Sense: "C"}}})
+	RegisterEnzyme(Enzyme{Name: "lys_n", Rules: []CleavageRule{{Cut: "K", Sense: "N"}}})
+	RegisterEnzyme(Enzyme{Name: "arg_c", Rules: []CleavageRule{{Cut: "R", NoCut: "P", Sense: "C"}}})
+	RegisterEnzyme(Enzyme{Name: "asp_n", Rules: []CleavageRule{{Cut: "D", Sense: "N"}}})
+	RegisterEnzyme(Enzyme{Name: "glu_c", Rules: []CleavageRule{{Cut: "DE", NoCut: "P", Sense: "C"}}})
+	RegisterEnzyme(Enzyme{Name: "chymotrypsin", Rules: []CleavageRule{{Cut: "FWYL", NoCut: "P", Sense: "C"}}})
+}
+
+// enzymeKey normalizes enzyme names so Lys-C, LysC and lys_c are the same entry
+func enzymeKey(name string) string {
+
+	key := strings.ToLower(strings.TrimSpace(name))
+	key = strings.ReplaceAll(key, "-", "_")
+	key = strings.ReplaceAll(key, " ", "_")
+
+	if v, ok := enzymeAliases[key]; ok {
+		return v
+	}
+
+	return key
+}
+
+// RegisterEnzyme adds or replaces an enzyme definition in the registry
+func RegisterEnzyme(e Enzyme) {
+
+	if len(e.Name) == 0 || len(e.Rules) == 0 {
+		msg.Custom(errors.New("enzyme definitions need a name and at least one cleavage rule"), "error")
+	}
+
+	for i := range e.Rules {
+		e.Rules[i].Cut = strings.ToUpper(e.Rules[i].Cut)
+		e.Rules[i].NoCut = strings.ToUpper(e.Rules[i].NoCut)
+		e.Rules[i].Sense = strings.ToUpper(e.Rules[i].Sense)
+		if len(e.Rules[i].Sense) == 0 {
+			e.Rules[i].Sense = "C"
+		}
+		if e.Rules[i].Sense != "C" && e.Rules[i].Sense != "N" {
+			msg.Custom(fmt.Errorf("enzyme %s has an invalid cleavage sense, use C or N", e.Name), "error")
+		}
+	}
+
+	e.Name = enzymeKey(e.Name)
+	e.build()
+	enzymeRegistry[e.Name] = e
+}
+
+// LoadEnzymes reads user-defined enzymes from a YAML or TOML file into the registry, files
+// ending in .toml are read as TOML
+func LoadEnzymes(file string) {
+
+	content, e := os.ReadFile(file)
+	if e != nil {
+		msg.ReadFile(e, "error")
+	}
+
+	var f EnzymeFile
+	if strings.EqualFold(filepath.Ext(file), ".toml") {
+		f, e = parseEnzymeTOML(content)
+	} else {
+		e = yaml.Unmarshal(content, &f)
+	}
+	if e != nil {
+		msg.ReadFile(fmt.Errorf("cannot parse the enzyme file: %s", e), "error")
+	}
+
+	for _, i := range f.Enzymes {
+		RegisterEnzyme(i)
+	}
+}
+
+// parseEnzymeTOML reads the TOML layout of the enzyme file, an array of enzyme tables each
+// holding an array of rule tables:
+//
+//	[[enzymes]]
+//	name = "trypsin_lys_n"
+//	[[enzymes.rules]]
+//	cut = "KR"
+//	nocut = "P"
+//	sense = "C"
+func parseEnzymeTOML(content []byte) (EnzymeFile, error) {
+
+	var f EnzymeFile
+	var inRule bool
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 && strings.Count(line[:i], "\"")%2 == 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+
+		if len(line) == 0 {
+			continue
+		}
+
+		switch line {
+		case "[[enzymes]]":
+			f.Enzymes = append(f.Enzymes, Enzyme{})
+			inRule = false
+			continue
+		case "[[enzymes.rules]]":
+			if len(f.Enzymes) == 0 {
+				return f, fmt.Errorf("line %d: rules must follow an [[enzymes]] table", n)
+			}
+			enz := &f.Enzymes[len(f.Enzymes)-1]
+			enz.Rules = append(enz.Rules, CleavageRule{})
+			inRule = true
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || len(f.Enzymes) == 0 {
+			return f, fmt.Errorf("line %d: expected a key = \"value\" pair inside an [[enzymes]] table", n)
+		}
+
+		key := strings.TrimSpace(kv[0])
+		value, e := strconv.Unquote(strings.TrimSpace(kv[1]))
+		if e != nil {
+			return f, fmt.Errorf("line %d: the value of %s must be a quoted string", n, key)
+		}
+
+		enz := &f.Enzymes[len(f.Enzymes)-1]
+
+		if !inRule {
+			if key != "name" {
+				return f, fmt.Errorf("line %d: unknown enzyme key %s", n, key)
+			}
+			enz.Name = value
+			continue
+		}
+
+		rule := &enz.Rules[len(enz.Rules)-1]
+		switch key {
+		case "cut":
+			rule.Cut = value
+		case "nocut":
+			rule.NoCut = value
+		case "sense":
+			rule.Sense = value
+		default:
+			return f, fmt.Errorf("line %d: unknown rule key %s", n, key)
+		}
+	}
+
+	return f, scanner.Err()
+}
+
+// EnzymeNames lists the registered enzymes
+func EnzymeNames() []string {
+
+	var names []string
+	for k := range enzymeRegistry {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// GetEnzyme returns a registered enzyme, names joined by + are combined into a single digest
+func GetEnzyme(name string) (Enzyme, bool) {
+
+	var enz Enzyme
+	var names []string
+
+	for _, i := range strings.Split(name, "+") {
+
+		e, ok := enzymeRegistry[enzymeKey(i)]
+		if !ok {
+			return Enzyme{}, false
+		}
+
+		names = append(names, e.Name)
+		enz.Rules = append(enz.Rules, e.Rules...)
+	}
+
+	enz.Name = strings.Join(names, "+")
+	enz.build()
+
+	return enz, true
 }
 
 // Synth is an enzyme builder
 func (e *Enzyme) Synth(t string) {
 
-	if strings.EqualFold(strings.ToLower(t), "trypsin") {
-		e.Name = "trypsin"
-		e.Pattern = "KR[^P]"
-		e.Join = "KR"
-	} else if strings.EqualFold(strings.ToLower(t), "lys_c") {
-		e.Name = "lys_c"
-		e.Pattern = "K[^P]"
-		e.Join = "K"
-	} else if strings.EqualFold(strings.ToLower(t), "lys_n") {
-		e.Name = "lys_n"
-		e.Pattern = "K"
-		e.Join = "K"
-	} else if strings.EqualFold(strings.ToLower(t), "chymotrypsin") {
-		e.Name = "chymotrypsin"
-		e.Pattern = "FWYL[^P]"
-		e.Join = "K"
-	} else if strings.EqualFold(strings.ToLower(t), "glu_c") {
-		e.Name = "glu_c"
-		e.Pattern = "DE[^P]"
-		e.Join = "K"
-	} else {
+	enz, ok := GetEnzyme(t)
+	if !ok {
 		msg.Custom(errors.New("Enzyme not supported"), "warning")
+		return
 	}
 
+	*e = enz
+}
+
+// build derives the legacy pattern and join strings from the cleavage rules
+func (e *Enzyme) build() {
+
+	var patterns []string
+	var join string
+
+	for _, r := range e.Rules {
+
+		p := r.Cut
+		if len(r.NoCut) > 0 {
+			p = fmt.Sprintf("%s[^%s]", r.Cut, r.NoCut)
+		}
+		patterns = append(patterns, p)
+
+		for _, c := range r.Cut {
+			if !strings.ContainsRune(join, c) {
+				join += string(c)
+			}
+		}
+	}
+
+	e.Pattern = strings.Join(patterns, "|")
+	e.Join = join
+}
+
+// CutsAfter reports if the residue is cut on its C-terminal side
+func (e Enzyme) CutsAfter(aa byte) bool {
+	for _, r := range e.Rules {
+		if r.Sense == "C" && strings.IndexByte(r.Cut, aa) != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// CutsBefore reports if the residue is cut on its N-terminal side
+func (e Enzyme) CutsBefore(aa byte) bool {
+	for _, r := range e.Rules {
+		if r.Sense == "N" && strings.IndexByte(r.Cut, aa) != -1 {
+			return true
+		}
+	}
+	return false
 }
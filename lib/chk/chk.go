@@ -0,0 +1,131 @@
+// Package chk checks the integrity of the spectra files before they are processed
+package chk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/mzn"
+	"github.com/Nesvilab/philosopher/lib/rep"
+	"github.com/Nesvilab/philosopher/lib/sys"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Run checks every mzML file and prints a pass or fail summary, the program stops with an error when
+// any of the files fails, so the command can guard the start of a pipeline
+func Run(p met.CheckSpectra, files []string) {
+
+	if len(p.Dir) > 0 {
+		found, e := filepath.Glob(filepath.Join(p.Dir, "*.mzML"))
+		if e != nil {
+			msg.Custom(e, "error")
+		}
+		files = append(files, found...)
+	}
+
+	if len(files) == 0 {
+		msg.InputNotFound(errors.New("you need to provide the mzML files or the folder containing them"), "error")
+	}
+
+	for _, f := range files {
+		if !strings.EqualFold(filepath.Ext(f), ".mzML") {
+			msg.Custom(fmt.Errorf("%s is not a mzML file, other formats can be converted with the convert command", f), "error")
+		}
+	}
+
+	var levels []string
+	for _, i := range p.MSLevel {
+		levels = append(levels, strconv.Itoa(i))
+	}
+
+	psms := workspaceScans()
+
+	var failed []string
+
+	for _, f := range files {
+
+		logrus.Info("Checking ", filepath.Base(f))
+
+		g := mzn.CheckMzML(f, levels)
+
+		name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+		if scans, ok := psms[name]; ok {
+			g.Checks = append(g.Checks, matchPSMs(g, scans))
+		}
+
+		for _, i := range g.Checks {
+			if i.Passed {
+				logrus.Info(fmt.Sprintf("  %-11s PASS  %s", i.Name, i.Detail))
+			} else {
+				logrus.Warning(fmt.Sprintf("  %-11s FAIL  %s", i.Name, i.Detail))
+			}
+		}
+
+		if !g.Passed() {
+			failed = append(failed, filepath.Base(f))
+		}
+	}
+
+	logrus.Info(fmt.Sprintf("%d of %d files passed", len(files)-len(failed), len(files)))
+
+	if len(failed) > 0 {
+		msg.Custom(fmt.Errorf("the spectra files %s failed the integrity check", strings.Join(failed, ", ")), "error")
+	}
+}
+
+// workspaceScans collects the scan numbers of the PSMs on the workspace, grouped by spectra file
+func workspaceScans() map[string][]string {
+
+	var scans = make(map[string][]string)
+
+	if _, e := os.Stat(sys.PSMBin()); e != nil {
+		return scans
+	}
+
+	var psm rep.PSMEvidenceList
+	rep.RestorePSM(&psm)
+
+	for _, i := range psm {
+
+		// the spectrum names are built as <file>.<scan>.<scan>.<charge>
+		parts := strings.Split(i.Spectrum, ".")
+		if len(parts) < 4 {
+			continue
+		}
+
+		source := strings.Join(parts[:len(parts)-3], ".")
+		scans[source] = append(scans[source], parts[len(parts)-3])
+	}
+
+	return scans
+}
+
+// matchPSMs tests that the scans of the identified spectra are fragment spectra of the file
+func matchPSMs(g mzn.Integrity, scans []string) mzn.Check {
+
+	var missing []string
+
+	for _, i := range scans {
+
+		scan := strings.TrimLeft(i, "0")
+
+		if level, ok := g.Scans[scan]; !ok || level == "1" {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return mzn.Check{Name: "psm scans", Detail: fmt.Sprintf("%d of %d PSMs have no matching MSn scan, the first is scan %s", len(missing), len(scans), missing[0])}
+	}
+
+	return mzn.Check{Name: "psm scans", Passed: true, Detail: fmt.Sprintf("%d PSMs matched", len(scans))}
+}
@@ -85,3 +85,56 @@ func IsDecoyIon(names map[string]int, tag string) bool {
 
 	return class
 }
+
+// contaminantTags are the prefixes added to the contaminant sequences by the database command
+var contaminantTags = []string{"contam_", "Cont_"}
+
+// IsContaminant identifies a Protein as a contaminant based on the contaminant tags
+func IsContaminant(name string) bool {
+
+	for _, i := range contaminantTags {
+		if strings.HasPrefix(name, i) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsEntrapment identifies a Protein as an entrapment sequence based on the entrapment tag
+func IsEntrapment(name string, tag string) bool {
+
+	if len(tag) == 0 {
+		return false
+	}
+
+	return strings.HasPrefix(name, tag)
+}
+
+// IsEntrapmentPSM identifies a PSM as an entrapment match when the protein and all
+// the alternative proteins carry the entrapment tag
+func IsEntrapmentPSM(protein string, alternatives []string, tag string) bool {
+	return allTagged(protein, alternatives, tag)
+}
+
+// IsVariantOnly identifies evidences that are explained only by variant protein entries
+func IsVariantOnly(protein string, alternatives []string, prefix string) bool {
+	return allTagged(protein, alternatives, prefix)
+}
+
+// allTagged reports if the protein and all the alternative proteins start with the tag
+func allTagged(protein string, alternatives []string, tag string) bool {
+
+	if len(tag) == 0 || !strings.HasPrefix(protein, tag) {
+		return false
+	}
+
+	// a single untagged protein is enough to explain the match
+	for _, i := range alternatives {
+		if !strings.HasPrefix(i, tag) {
+			return false
+		}
+	}
+
+	return true
+}
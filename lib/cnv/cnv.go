@@ -0,0 +1,96 @@
+package cnv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/mzn"
+	"github.com/Nesvilab/philosopher/lib/sys"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Run is the convert main entry point, each spectra file is written as mzML
+func Run(p met.Msconvert, version string, files []string) {
+
+	if len(files) == 0 {
+		msg.InputNotFound(errors.New("you need to provide at least one spectra file to convert"), "error")
+	}
+
+	if !strings.EqualFold(p.Format, "mzml") {
+		msg.Custom(fmt.Errorf("unsupported output format: %s, only mzML is available", p.Format), "error")
+	}
+
+	if p.MZBinaryEncoding != "32" && p.MZBinaryEncoding != "64" {
+		msg.Custom(fmt.Errorf("the m/z encoding must be 32 or 64, got %s", p.MZBinaryEncoding), "error")
+	}
+
+	if p.IntensityBinaryEncoding != "32" && p.IntensityBinaryEncoding != "64" {
+		msg.Custom(fmt.Errorf("the intensity encoding must be 32 or 64, got %s", p.IntensityBinaryEncoding), "error")
+	}
+
+	var o = mzn.WriteOptions{
+		MzPrecision:        p.MZBinaryEncoding,
+		IntensityPrecision: p.IntensityBinaryEncoding,
+		Zlib:               p.Zlib,
+		NoIndex:            p.NoIndex,
+		PeakPicking:        p.PeakPicking,
+		Picking:            mzn.NewPickOptions(p.PeakApex, p.SignalToNoise),
+		Version:            version,
+	}
+
+	for _, i := range p.MSLevel {
+		o.Levels = append(o.Levels, strconv.Itoa(i))
+	}
+
+	for _, f := range files {
+
+		output := OutputFile(f, p.Output)
+		if absolute(output) == absolute(f) {
+			msg.Custom(fmt.Errorf("the output file %s would overwrite the input file", output), "error")
+		}
+
+		logrus.Info("Converting ", filepath.Base(f))
+
+		src := mzn.NewSource(f)
+
+		// the vendor centroids are used for peak picking on RAW files, otherwise profile scans are written as acquired
+		if r, ok := src.(*mzn.RawReader); ok {
+			r.Profile = !p.PeakPicking
+		}
+
+		n := mzn.WriteMzML(src, output, o)
+		src.Close()
+
+		logrus.Info("Wrote ", n, " spectra to ", output)
+	}
+
+}
+
+// OutputFile returns the mzML file name for a spectra file, written next to it when no folder is given
+func OutputFile(f, dir string) string {
+
+	name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)) + ".mzML"
+
+	if len(dir) == 0 {
+		dir = filepath.Dir(f)
+	} else if _, e := os.Stat(dir); os.IsNotExist(e) {
+		if e := os.MkdirAll(dir, sys.FilePermission()); e != nil {
+			msg.WriteFile(e, "error")
+		}
+	}
+
+	return filepath.Join(dir, name)
+}
+
+// absolute resolves a file path, used to compare the input and output files
+func absolute(f string) string {
+	abs, _ := filepath.Abs(f)
+	return abs
+}
@@ -0,0 +1,132 @@
+package dat
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/fas"
+	"github.com/sirupsen/logrus"
+)
+
+// contaminantSequences holds the contaminant library sequences used to flag untagged contaminants
+var contaminantSequences map[string]struct{}
+
+// LoadContaminants reads the sequences of the contaminant FASTA libraries, records with an identical
+// sequence are flagged as contaminants even when their headers carry no contaminant tag
+func LoadContaminants(files []string) {
+
+	if contaminantSequences == nil {
+		contaminantSequences = make(map[string]struct{})
+	}
+
+	for _, f := range files {
+
+		db := fas.ParseFile(f)
+		for _, v := range db {
+			contaminantSequences[normalizeSequence(v)] = struct{}{}
+		}
+
+		logrus.Info(fmt.Sprintf("Loaded %d contaminant sequences from %s", len(db), filepath.Base(f)))
+	}
+}
+
+// IsContaminantSequence reports if the sequence is part of the loaded contaminant libraries
+func IsContaminantSequence(seq string) bool {
+	_, ok := contaminantSequences[normalizeSequence(seq)]
+	return ok
+}
+
+// detectContaminant flags the record when its sequence matches a contaminant library
+func (r *Record) detectContaminant() {
+	if !r.IsContaminant && contaminantSequences != nil && IsContaminantSequence(r.Sequence) {
+		r.IsContaminant = true
+	}
+}
+
+// addContaminantFiles adds the user contaminant libraries to the database, entries with the same
+// sequence are replaced by the contaminant entry and reported. The headers of the added entries
+// are kept on the library set
+func (d *Base) addContaminantFiles(files []string, cTag bool, library map[string]struct{}) {
+
+	LoadContaminants(files)
+
+	var sequences = make(map[string]string)
+	for h, s := range d.TaDeDB {
+		sequences[normalizeSequence(s)] = h
+	}
+
+	var added, replaced int
+
+	for _, f := range files {
+		for k, v := range fas.ParseFile(f) {
+
+			if cTag && !strings.HasPrefix(k, "contam_") {
+				k = "contam_" + k
+			}
+
+			if h, ok := sequences[normalizeSequence(v)]; ok && h != ">"+k {
+				logrus.Info(fmt.Sprintf("Replacing %s by the contaminant entry %s", strings.TrimPrefix(h, ">"), k))
+				delete(d.TaDeDB, h)
+				delete(library, h)
+				replaced++
+			}
+
+			d.TaDeDB[">"+k] = v
+			library[">"+k] = struct{}{}
+			sequences[normalizeSequence(v)] = ">" + k
+			added++
+		}
+	}
+
+	logrus.Info(fmt.Sprintf("Added %d custom contaminant entries, %d identical entries were replaced", added, replaced))
+}
+
+// tagContaminants adds the contaminant tag to the database entries that are not part of a contaminant
+// library but share the sequence of a contaminant, so they are flagged as the library entries are. Without
+// the contaminant tag the headers are kept, and the entries are flagged by their sequence on annotation
+func (d *Base) tagContaminants(library map[string]struct{}, cTag bool) {
+
+	var tagged int
+
+	for h, s := range d.TaDeDB {
+
+		if _, ok := library[h]; ok || strings.HasPrefix(h, ">contam_") || !IsContaminantSequence(s) {
+			continue
+		}
+
+		tagged++
+
+		if !cTag {
+			logrus.Info(fmt.Sprintf("Flagging %s as a contaminant, its sequence matches a contaminant library entry", strings.TrimPrefix(h, ">")))
+			continue
+		}
+
+		logrus.Info(fmt.Sprintf("Tagging %s as a contaminant, its sequence matches a contaminant library entry", strings.TrimPrefix(h, ">")))
+
+		delete(d.TaDeDB, h)
+		d.TaDeDB[">contam_"+strings.TrimPrefix(h, ">")] = s
+	}
+
+	if tagged > 0 {
+		logrus.Info(fmt.Sprintf("Found %d entries with contaminant sequences", tagged))
+	}
+}
+
+func normalizeSequence(seq string) string {
+	return strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(seq)), "*")
+}
+
+// contaminantFiles splits the comma-separated list of contaminant libraries
+func contaminantFiles(list string) []string {
+
+	var files []string
+	for _, i := range strings.Split(list, ",") {
+		if i = strings.TrimSpace(i); len(i) > 0 {
+			abs, _ := filepath.Abs(i)
+			files = append(files, abs)
+		}
+	}
+
+	return files
+}
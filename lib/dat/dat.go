@@ -16,6 +16,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Nesvilab/philosopher/lib/bio"
 	"github.com/Nesvilab/philosopher/lib/fas"
 
 	"github.com/Nesvilab/philosopher/lib/msg"
@@ -40,6 +41,8 @@ type Base struct {
 	RecordsLen      int
 	NParts          uint
 	PartsLen        []int
+	DecoyCollisions int
+	EntrapmentRatio float64
 	TaDeDB          map[string]string
 }
 
@@ -59,37 +62,95 @@ func Run(m met.Data) met.Data {
 
 	var db = New()
 
-	if len(m.Database.ID) == 0 && (len(m.Database.Annot) == 0 || m.Database.Annot == "--contam" || m.Database.Annot == "--prefix") && (len(m.Database.Custom) == 0 || m.Database.Custom == "--contam" || m.Database.Custom == "--prefix") {
+	if m.Database.Stats || len(m.Database.Diff) > 0 {
+		inspect(m)
+		return m
+	}
+
+	if len(m.Database.ID) == 0 && len(m.Database.UniProtFile) == 0 && (len(m.Database.Annot) == 0 || m.Database.Annot == "--contam" || m.Database.Annot == "--prefix") && (len(m.Database.Custom) == 0 || m.Database.Custom == "--contam" || m.Database.Custom == "--prefix") {
 		msg.InputNotFound(errors.New("provide a protein FASTA file or Proteome ID"), "error")
 	}
 
 	db.Prefix = m.Database.Tag
 
+	if len(m.Database.EnzymeFile) > 0 {
+		m.Database.EnzymeFile, _ = filepath.Abs(m.Database.EnzymeFile)
+		bio.LoadEnzymes(m.Database.EnzymeFile)
+	}
+
+	if len(m.Database.HeaderFile) > 0 {
+		m.Database.HeaderFile, _ = filepath.Abs(m.Database.HeaderFile)
+		LoadHeaderFormats(m.Database.HeaderFile)
+	}
+
+	SetHeaderFormat(m.Database.HeaderFormat)
+
+	if len(m.Database.Entrapment) > 0 && len(m.Database.EntrapTag) == 0 {
+		m.Database.EntrapTag = "entrap_"
+	}
+
+	entrapmentTag = m.Database.EntrapTag
+
+	if len(m.Database.UniProtFile) > 0 {
+		m.Database.UniProtFile, _ = filepath.Abs(m.Database.UniProtFile)
+	}
+
 	if len(m.Database.Annot) > 0 {
 
 		logrus.Info("Annotating the database")
 
+		if len(m.Database.UniProtFile) > 0 {
+			LoadUniProtAnnotations(m.Database.UniProtFile)
+		}
+
+		// sequence identity flags contaminants that have no contaminant tag
+		if m.Database.Crap {
+			db.Deploy(m.Temp)
+			LoadContaminants([]string{db.CrapDB})
+		}
+
+		if len(m.Database.ContamFile) > 0 {
+			LoadContaminants(contaminantFiles(m.Database.ContamFile))
+		}
+
 		m.DB = m.Database.Annot
 
 		db.ProcessDBAndSerialize(m.Database.Annot, m.Database.Tag, m.Database.Verbose)
 
 		db.Serialize()
 
+		if len(m.Database.EntrapTag) > 0 {
+			m.Database.EntrapmentRatio = EntrapmentRatio(m.Database.Annot, m.Database.Tag, m.Database.EntrapTag)
+			logrus.Info(fmt.Sprintf("Entrapment to target sequence ratio: %.4f", m.Database.EntrapmentRatio))
+		}
+
+		if m.Database.DebugHeaders {
+			logrus.Info("Writing the header parsing report to ", WriteHeaderReport(m.Database.Annot, m.Database.Tag, m.Home))
+		}
+
 		return m
 	}
 
-	if len(m.Database.ID) < 1 && len(m.Database.Custom) < 1 {
+	if len(m.Database.ID) < 1 && len(m.Database.Custom) < 1 && len(m.Database.UniProtFile) < 1 {
 		msg.InputNotFound(errors.New("you need to provide a taxon ID or a custom FASTA file"), "error")
 	}
 
-	if !m.Database.Crap {
+	if !m.Database.Crap && len(m.Database.ContamFile) == 0 {
 		msg.Custom(errors.New("contaminants are not going to be added to database"), "warning")
 	}
 
 	// bool variable will control the adition fo contaminant tags to contam proteins from the same organism.
 	var ids = make(map[string]string)
 
-	if len(m.Database.Custom) < 1 {
+	if len(m.Database.UniProtFile) > 0 {
+
+		logrus.Info("Reading the local UniProt file ", filepath.Base(m.Database.UniProtFile))
+
+		m.Database.TimeStamp = time.Now().Format("2006.01.02 15:04:05")
+
+		db.Ingest(m.Database.UniProtFile, m.Temp, m.Database.Rev)
+
+	} else if len(m.Database.Custom) < 1 {
 
 		m.DB = m.Database.Custom
 
@@ -114,8 +175,23 @@ func Run(m met.Data) met.Data {
 		db.DownloadedFiles = append(db.DownloadedFiles, dbPath)
 	}
 
+	if _, ok := bio.GetEnzyme(m.Database.Enz); !ok {
+		msg.Custom(fmt.Errorf("enzyme %s is not defined, available enzymes are %s", m.Database.Enz, strings.Join(bio.EnzymeNames(), ", ")), "error")
+	}
+
+	if len(m.Database.DecoyMethod) == 0 {
+		m.Database.DecoyMethod = DecoyReverse
+	}
+
+	// a zero seed means a new one is drawn, and kept on the meta data so the decoys can be rebuilt
+	if m.Database.DecoyMethod == DecoyShuffle && m.Database.DecoySeed == 0 {
+		m.Database.DecoySeed = time.Now().UnixNano()
+	}
+
 	logrus.Info("Generating the target-decoy database")
-	db.Create(m.Temp, m.Database.Add, m.Database.Enz, m.Database.Tag, m.Database.Crap, m.Database.NoD, m.Database.CrapTag, ids)
+	db.Create(m.Temp, m.Database.Add, m.Database.Enz, m.Database.Tag, m.Database.DecoyMethod, m.Database.Variants, m.Database.Entrapment, m.Database.EntrapTag, m.Database.ContamFile, m.Database.DecoySeed, m.Database.Crap, m.Database.NoD, m.Database.CrapTag, ids)
+	m.Database.DecoyCollisions = db.DecoyCollisions
+	m.Database.EntrapmentRatio = db.EntrapmentRatio
 
 	logrus.Info("Creating file")
 	db.Save(m.Home, m.Temp, m.Database.ID, m.Database.Tag, m.Database.Rev, m.Database.Iso, m.Database.NoD, m.Database.Crap)
@@ -168,7 +244,10 @@ func (d *Base) ProcessDBAndSerialize(filename, decoyTag string, verbose bool) {
 				d.PartsLen[i] += len(fastaSlice)
 				for _, e := range fastaSlice {
 					class := Classify(e.Header, decoyTag)
-					enc.Encode(ProcessHeader(e.Header, e.Seq, class, decoyTag, verbose))
+					r := ProcessHeader(e.Header, e.Seq, class, decoyTag, verbose)
+					r.annotate()
+					r.detectContaminant()
+					enc.Encode(r)
 				}
 			}
 		}(i)
@@ -283,10 +362,13 @@ func (d *Base) Fetch(uniprotID, proteomeID, temp string, iso, rev bool) {
 }
 
 // Create processes the given fasta file and add decoy sequences
-func (d *Base) Create(temp, add, enz, tag string, crap, noD, cTag bool, ids map[string]string) {
+func (d *Base) Create(temp, add, enz, tag, method, variants, entrap, entrapTag, contam string, seed int64, crap, noD, cTag bool, ids map[string]string) {
 
 	d.TaDeDB = make(map[string]string)
 
+	// the contaminant library entries, every other entry with a contaminant sequence is flagged
+	var library = make(map[string]struct{})
+
 	for _, i := range d.DownloadedFiles {
 
 		dbfile, _ := filepath.Abs(i)
@@ -305,6 +387,7 @@ func (d *Base) Create(temp, add, enz, tag string, crap, noD, cTag bool, ids map[
 		if crap {
 
 			d.Deploy(temp)
+			LoadContaminants([]string{d.CrapDB})
 
 			crapMap := fas.ParseFile(d.CrapDB)
 
@@ -328,6 +411,7 @@ func (d *Base) Create(temp, add, enz, tag string, crap, noD, cTag bool, ids map[
 					}
 				}
 				db[k] = v
+				library[">"+k] = struct{}{}
 			}
 
 			e := os.Remove(fmt.Sprintf("%s%scrap-gpmdb.fas", temp, string(filepath.Separator)))
@@ -338,17 +422,31 @@ func (d *Base) Create(temp, add, enz, tag string, crap, noD, cTag bool, ids map[
 		}
 
 		for h, s := range db {
-
 			th := ">" + h
 			d.TaDeDB[th] = s
+		}
 
-			if !noD {
-				dh := ">" + tag + h
-				d.TaDeDB[dh] = reverseSeq(s)
-			}
+	}
 
-		}
+	if len(contam) > 0 {
+		d.addContaminantFiles(contaminantFiles(contam), cTag, library)
+	}
+
+	if crap || len(contam) > 0 {
+		d.tagContaminants(library, cTag)
+	}
 
+	if len(variants) > 0 {
+		d.addVariants(variants, tag)
+	}
+
+	// entrapment sequences come from a foreign proteome and are treated as targets by the search
+	if len(entrap) > 0 {
+		d.addEntrapment(entrap, entrapTag)
+	}
+
+	if !noD {
+		d.addDecoys(tag, NewDecoyMaker(method, enz, seed))
 	}
 
 }
@@ -3,6 +3,9 @@ package dat_test
 import (
 	"github.com/Nesvilab/philosopher/lib/fas"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	. "github.com/Nesvilab/philosopher/lib/dat"
@@ -108,3 +111,455 @@ func TestBase_Deploy(t *testing.T) {
 		})
 	}
 }
+
+func TestDecoyMaker_Build(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		seq    string
+		want   string
+	}{
+		{
+			name:   "Testing reversed decoys",
+			method: DecoyReverse,
+			seq:    "MPEPTIDEKAAR",
+			want:   "MRAAKEDITPEP",
+		},
+		{
+			name:   "Testing pseudo-reversed decoys",
+			method: DecoyPseudoReverse,
+			seq:    "MPEPTIDEKAAR",
+			want:   "MEDITPEPKAAR",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoyMaker(tt.method, "trypsin", 1)
+			if got := d.Build(tt.seq); got != tt.want {
+				t.Errorf("Build() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoyMaker_Shuffle(t *testing.T) {
+
+	target := "MPEPTIDESEQUENCEKLONGERPEPTIDER"
+
+	d1 := NewDecoyMaker(DecoyShuffle, "trypsin", 42)
+	d1.AddTargets([]string{target})
+	d2 := NewDecoyMaker(DecoyShuffle, "trypsin", 42)
+	d2.AddTargets([]string{target})
+
+	decoy := d1.Build(target)
+	if decoy != d2.Build(target) {
+		t.Errorf("Shuffled decoys are not reproducible with the same seed")
+	}
+
+	if decoy[0] != 'M' || decoy[16] != 'K' || decoy[len(decoy)-1] != 'R' {
+		t.Errorf("Shuffled decoy %s did not keep the methionine and cleavage sites in place", decoy)
+	}
+
+	if n, _ := d1.Collisions([]string{decoy}); n != 0 {
+		t.Errorf("Shuffled decoy %s collides with %d target peptides", decoy, n)
+	}
+}
+
+func TestDecoyMaker_ShuffleCollision(t *testing.T) {
+
+	// every arrangement of the body is a target, so no shuffle can avoid a collision
+	var targets []string
+	for i := 0; i < 7; i++ {
+		targets = append(targets, strings.Repeat("A", i)+"C"+strings.Repeat("A", 6-i)+"K")
+	}
+
+	d := NewDecoyMaker(DecoyShuffle, "trypsin", 42)
+	d.AddTargets(targets)
+
+	decoy := d.Build(targets[0])
+	if decoy[len(decoy)-1] != 'K' {
+		t.Errorf("Shuffled decoy %s did not keep the cleavage site in place", decoy)
+	}
+
+	if d.ShuffleCollisions() != 1 {
+		t.Errorf("Shuffle collisions = %d, want 1", d.ShuffleCollisions())
+	}
+
+	if n, _ := d.Collisions([]string{decoy}); n != 1 {
+		t.Errorf("Shuffled decoy %s collides with %d target peptides, want 1", decoy, n)
+	}
+
+	// a single target leaves free arrangements, the shuffle must find one
+	d = NewDecoyMaker(DecoyShuffle, "trypsin", 42)
+	d.AddTargets(targets[:1])
+
+	for i := 0; i < 20; i++ {
+		if decoy := d.Build(targets[0]); decoy == targets[0] {
+			t.Fatalf("Shuffled decoy %s is the target", decoy)
+		}
+	}
+
+	if d.ShuffleCollisions() != 0 {
+		t.Errorf("Shuffle collisions = %d, want 0", d.ShuffleCollisions())
+	}
+}
+
+func TestHeaderFormats(t *testing.T) {
+
+	RegisterHeaderFormat(HeaderFormat{
+		Name:    "lims",
+		Pattern: `^LIMS:(?P<id>\w+)\s+gene=(?P<gene>\w+)\s+org=(?P<organism>[^;]+);`,
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		format   string
+		id       string
+		gene     string
+		organism string
+	}{
+		{
+			name:     "Testing a user-defined header format",
+			header:   "rev_LIMS:P0001 gene=ABC1 org=Mus musculus;",
+			format:   "lims",
+			id:       "P0001",
+			gene:     "ABC1",
+			organism: "Mus musculus",
+		},
+		{
+			name:   "Testing GENCODE headers keep the built-in parser",
+			header: "ENSP00000493376.2|ENST00000641515.2|ENSG00000186092.7|OTTHUMG00000001094.4|OTTHUMT00000003223.4|OR4F5-201|OR4F5|326",
+			id:     "ENSP00000493376.2",
+			gene:   "ENSG00000186092.7",
+		},
+		{
+			name:   "Testing the built-in UniProt parser",
+			header: "sp|P69905|HBA_HUMAN Hemoglobin subunit alpha OS=Homo sapiens OX=9606 GN=HBA1 PE=1 SV=2",
+			id:     "P69905",
+			gene:   "HBA1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := ProcessHeader(tt.header, "", Classify(tt.header, "rev_"), "rev_", false)
+			if r.HeaderFormat != tt.format || r.ID != tt.id || r.GeneNames != tt.gene {
+				t.Errorf("ProcessHeader() = %s %s %s, want %s %s %s", r.HeaderFormat, r.ID, r.GeneNames, tt.format, tt.id, tt.gene)
+			}
+			if len(tt.organism) > 0 && r.Organism != tt.organism {
+				t.Errorf("ProcessHeader() organism = %s, want %s", r.Organism, tt.organism)
+			}
+		})
+	}
+
+	SetHeaderFormat("lims")
+	r := ProcessHeader("sp|P69905|HBA_HUMAN", "", Classify("sp|P69905|HBA_HUMAN", "rev_"), "rev_", false)
+	if r.HeaderFormat != "lims" || r.ID != "sp|P69905|HBA_HUMAN" {
+		t.Errorf("ProcessHeader() with a forced format = %s %s, want lims and the header ID", r.HeaderFormat, r.ID)
+	}
+	SetHeaderFormat("")
+
+	gencode := "ENSP00000493376.2|ENST00000641515.2|ENSG00000186092.7|OTTHUMG00000001094.4|OTTHUMT00000003223.4|OR4F5-201|OR4F5|326"
+	SetHeaderFormat("gencode")
+	r = ProcessHeader(gencode, "", Classify(gencode, "rev_"), "rev_", false)
+	if r.HeaderFormat != "gencode" || r.ID != "ENSP00000493376.2" || r.EntryName != "OR4F5-201" || r.GeneNames != "OR4F5" {
+		t.Errorf("ProcessHeader() with the gencode format = %s %s %s %s", r.HeaderFormat, r.ID, r.EntryName, r.GeneNames)
+	}
+	SetHeaderFormat("")
+}
+
+func TestEntrapmentRatio(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "db.fas")
+	content := ">sp|P1|A_HUMAN\nPEPTIDEK\n>sp|P2|B_HUMAN\nPEPTIDER\n>entrap_sp|Q1|C_ARATH\nPEPTIDEAK\n>rev_sp|P1|A_HUMAN\nKEDITPEP\n>rev_entrap_sp|Q1|C_ARATH\nKAEDITPEP\n"
+	if e := os.WriteFile(file, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	if got := EntrapmentRatio(file, "rev_", "entrap_"); got != 0.5 {
+		t.Errorf("EntrapmentRatio() = %v, want %v", got, 0.5)
+	}
+}
+
+func TestApplyVariant(t *testing.T) {
+
+	seq := "MPEPTIDEKAR"
+
+	tests := []struct {
+		name    string
+		change  string
+		want    string
+		wantErr bool
+	}{
+		{"Testing a substitution", "P2A", "MAEPTIDEKAR", false},
+		{"Testing a stop gain", "K9*", "MPEPTIDE", false},
+		{"Testing a single deletion", "E3del", "MPPTIDEKAR", false},
+		{"Testing a range deletion", "P4_I6del", "MPEDEKAR", false},
+		{"Testing an insertion", "K9_A10insGG", "MPEPTIDEKGGAR", false},
+		{"Testing a deletion-insertion", "T5_D7delinsW", "MPEPWEKAR", false},
+		{"Testing a reference mismatch", "A2P", "", true},
+		{"Testing a position outside the protein", "R20K", "", true},
+		{"Testing an unsupported notation", "fs", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, e := ApplyVariant(seq, tt.change)
+			if (e != nil) != tt.wantErr {
+				t.Errorf("ApplyVariant() error = %v, wantErr %v", e, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ApplyVariant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	header := "var|P69905_L30P|P69905_L30P L30P variant of P69905 GN=HBA1 SRC=P69905 VAR=L30P SMP=S1;S2"
+	r := ProcessHeader(header, "", Classify(header, "rev_"), "rev_", false)
+	if r.HeaderFormat != "variant" || r.ID != "P69905_L30P" || r.GeneNames != "HBA1" {
+		t.Errorf("ProcessHeader() = %s %s %s, want variant P69905_L30P HBA1", r.HeaderFormat, r.ID, r.GeneNames)
+	}
+}
+
+func TestCreateVariants(t *testing.T) {
+
+	dir := t.TempDir()
+	db := filepath.Join(dir, "db.fas")
+	variants := filepath.Join(dir, "variants.tsv")
+
+	if e := os.WriteFile(db, []byte(">sp|P69905|HBA_HUMAN Hemoglobin OS=Homo sapiens OX=9606 GN=HBA1 PE=1 SV=2\nMVLSPADKTNVK\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	table := "protein\tvariant\tsample\tsequence\n" +
+		"P69905\tp.L3P\tS1\n" +
+		"ENST0001\torf\tS1\tMNOVELPEPTIDEK\n" +
+		"ENST0001\torf\tS2\tMANOTHERNOVELK\n" +
+		"ENST0001\torf\tS2\tMNOVELPEPTIDEK\n"
+	if e := os.WriteFile(variants, []byte(table), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	base := New()
+	base.DownloadedFiles = []string{db}
+	base.Create(dir, "", "trypsin", "rev_", "", variants, "", "", "", 0, false, true, false, nil)
+
+	want := map[string]string{
+		"var|P69905_L3P|P69905_L3P":       "MVPSPADKTNVK",
+		"var|ENST0001_ORF1|ENST0001_ORF1": "MNOVELPEPTIDEK",
+		"var|ENST0001_ORF2|ENST0001_ORF2": "MANOTHERNOVELK",
+	}
+
+	var found int
+	for h, s := range base.TaDeDB {
+		for k, v := range want {
+			if strings.HasPrefix(h, ">"+k+" ") {
+				found++
+				if s != v {
+					t.Errorf("Create() wrote %s for %s, want %s", s, k, v)
+				}
+			}
+		}
+	}
+
+	if found != len(want) || len(base.TaDeDB) != len(want)+1 {
+		t.Errorf("Create() variant entries are incorrect, got %v", base.TaDeDB)
+	}
+}
+
+func TestReadUniProt(t *testing.T) {
+
+	flat := `ID   CYC_HUMAN               Reviewed;         12 AA.
+AC   P99999; Q6IBC3;
+DT   21-JUL-1986, sequence version 2.
+DE   RecName: Full=Cytochrome c {ECO:0000305};
+DE   AltName: Full=Other name;
+GN   Name=CYCS {ECO:0000312|HGNC:HGNC:19986}; Synonyms=CYC, HCS;
+OS   Homo sapiens (Human).
+OX   NCBI_TaxID=9606;
+CC   -!- SUBCELLULAR LOCATION: Mitochondrion intermembrane space. Note=Loosely
+CC       associated with the inner membrane.
+DR   GO; GO:0005758; C:mitochondrial intermembrane space; IDA:UniProtKB.
+PE   1: Evidence at protein level;
+KW   3D-structure; Acetylation; Apoptosis.
+FT   INIT_MET        1
+FT                   /note="Removed"
+FT   MOD_RES         2
+FT                   /note="N-acetylglycine; long
+FT                   note"
+FT                   /evidence="ECO:0000269|PubMed:123"
+SQ   SEQUENCE   12 AA;  1200 MW;  0 CRC64;
+     MGDVEKGKKI FV
+//
+`
+
+	xmlFile := `<?xml version="1.0" encoding="UTF-8"?>
+<uniprot xmlns="http://uniprot.org/uniprot">
+<entry dataset="Swiss-Prot">
+  <accession>P99999</accession>
+  <accession>Q6IBC3</accession>
+  <name>CYC_HUMAN</name>
+  <protein><recommendedName><fullName>Cytochrome c</fullName></recommendedName></protein>
+  <gene><name type="primary">CYCS</name><name type="synonym">CYC</name><name type="synonym">HCS</name></gene>
+  <organism><name type="scientific">Homo sapiens</name><dbReference type="NCBI Taxonomy" id="9606"/></organism>
+  <comment type="subcellular location"><subcellularLocation><location>Mitochondrion intermembrane space</location></subcellularLocation></comment>
+  <dbReference type="GO" id="GO:0005758"><property type="term" value="C:mitochondrial intermembrane space"/></dbReference>
+  <proteinExistence type="evidence at protein level"/>
+  <keyword id="KW-0002">3D-structure</keyword>
+  <keyword id="KW-0007">Acetylation</keyword>
+  <keyword id="KW-0053">Apoptosis</keyword>
+  <feature type="initiator methionine" description="Removed"><location><position position="1"/></location></feature>
+  <feature type="modified residue" description="N-acetylglycine; long note"><location><position position="2"/></location></feature>
+  <sequence length="12" version="2">MGDVEKGKKI
+FV</sequence>
+</entry>
+</uniprot>
+`
+
+	dir := t.TempDir()
+	files := map[string]string{"flat": filepath.Join(dir, "up.dat"), "xml": filepath.Join(dir, "up.xml")}
+	if e := os.WriteFile(files["flat"], []byte(flat), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.WriteFile(files["xml"], []byte(xmlFile), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	header := "sp|P99999|CYC_HUMAN Cytochrome c OS=Homo sapiens (Human) OX=9606 GN=CYCS PE=1 SV=2"
+
+	for name, file := range files {
+		t.Run("Testing the UniProt "+name+" format", func(t *testing.T) {
+
+			entries := ReadUniProt(file)
+			if len(entries) != 1 {
+				t.Fatalf("ReadUniProt() = %d entries, want 1", len(entries))
+			}
+
+			u := entries[0]
+			if u.Sequence != "MGDVEKGKKIFV" || u.Gene != "CYCS" || strings.Join(u.GeneSynonyms, ",") != "CYC,HCS" {
+				t.Errorf("ReadUniProt() = %s %s %v", u.Sequence, u.Gene, u.GeneSynonyms)
+			}
+			if strings.Join(u.Keywords, ",") != "3D-structure,Acetylation,Apoptosis" || len(u.GOTerms) != 1 {
+				t.Errorf("ReadUniProt() keywords = %v, GO terms = %v", u.Keywords, u.GOTerms)
+			}
+			if len(u.SubcellularLocation) != 1 || u.SubcellularLocation[0] != "Mitochondrion intermembrane space" {
+				t.Errorf("ReadUniProt() location = %v", u.SubcellularLocation)
+			}
+			if len(u.Features) != 2 || u.Features[1].String() != "MOD_RES 2 N-acetylglycine; long note" || !u.Features[0].IsProcessing() {
+				t.Errorf("ReadUniProt() features = %v", u.Features)
+			}
+			if name == "flat" && u.Header() != header {
+				t.Errorf("Header() = %s, want %s", u.Header(), header)
+			}
+		})
+	}
+}
+
+func TestDatabaseStatsAndDiff(t *testing.T) {
+
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.fas")
+	updated := filepath.Join(dir, "new.fas")
+
+	oldContent := ">sp|P1|A_HUMAN Protein A OS=Homo sapiens OX=9606 GN=A PE=1 SV=1\nMPEPTIDEKAAAAAAAR\n" +
+		">sp|P2|B_HUMAN Protein B OS=Homo sapiens OX=9606 GN=B PE=2 SV=1\nMSEQENCEK\n" +
+		">sp|P3|C_HUMAN Protein C OS=Homo sapiens OX=9606 GN=C PE=1 SV=1\nMREMOVEDK\n" +
+		">rev_sp|P1|A_HUMAN Protein A OS=Homo sapiens OX=9606 GN=A PE=1 SV=1\nMRAAAAAAAKEDITPEP\n" +
+		">contam_sp|P00761|TRYP_PIG Trypsin OS=Sus scrofa OX=9823 PE=1 SV=1\nIVGGYTCAANSIPYQVSLNSGSHFCGGSLINSQWVVSAAHCYK\n"
+
+	newContent := ">sp|P1|A_HUMAN Protein A OS=Homo sapiens OX=9606 GN=A PE=1 SV=1\nMPEPTIDEKAAAAAAAR\n" +
+		">sp|P2|B_HUMAN Protein B OS=Homo sapiens OX=9606 GN=B PE=2 SV=2\nMSEQENCEKK\n" +
+		">sp|P4|D_HUMAN Protein D OS=Homo sapiens OX=9606 GN=D PE=1 SV=1\nMADDEDK\n"
+
+	if e := os.WriteFile(old, []byte(oldContent), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.WriteFile(updated, []byte(newContent), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	s := ComputeStats(LoadRecords(old, "rev_"), "rev_", []string{"trypsin"})
+	if s.Total != 5 || s.Targets != 3 || s.Decoys != 1 || s.Contaminants != 1 {
+		t.Errorf("ComputeStats() = %d total, %d targets, %d decoys, %d contaminants, want 5, 3, 1, 1", s.Total, s.Targets, s.Decoys, s.Contaminants)
+	}
+	if s.Organisms["Homo sapiens"] != 3 || s.Lengths[0] != 4 {
+		t.Errorf("ComputeStats() organisms = %v, lengths = %v", s.Organisms, s.Lengths)
+	}
+	if s.Peptides["trypsin"] == 0 {
+		t.Errorf("ComputeStats() found no tryptic peptides")
+	}
+
+	diff := CompareDatabases(LoadRecords(old, "rev_"), LoadRecords(updated, "rev_"), "rev_")
+	if len(diff.Added) != 1 || len(diff.Removed) != 2 || len(diff.SequenceChange) != 1 || diff.Unchanged != 1 {
+		t.Errorf("CompareDatabases() = %d added, %d removed, %d changed, %d unchanged, want 1, 2, 1, 1",
+			len(diff.Added), len(diff.Removed), len(diff.SequenceChange), diff.Unchanged)
+	}
+}
+
+func TestContaminantDetection(t *testing.T) {
+
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "keratins.fas")
+	db := filepath.Join(dir, "db.fas")
+
+	if e := os.WriteFile(lib, []byte(">sp|P04264|K2C1_HUMAN Keratin\nMSRQFSSRSGYRSGGGFSSGSAGIINYQR\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	content := ">sp|P04264|K2C1_HUMAN Keratin, type II cytoskeletal 1 OS=Homo sapiens OX=9606 GN=KRT1 PE=1 SV=6\nMSRQFSSRSGYRSGGGFSSGSAGIINYQR\n" +
+		">contam_sp|P00761|TRYP_PIG Trypsin OS=Sus scrofa OX=9823 PE=1 SV=1\nIVGGYTCAANSIPYQVSLNSGSHFCGGSLINSQWVVSAAHCYK\n" +
+		">sp|P1|A_HUMAN Protein A OS=Homo sapiens OX=9606 GN=A PE=1 SV=1\nMPEPTIDEK\n"
+	if e := os.WriteFile(db, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	LoadContaminants([]string{lib})
+
+	var got []bool
+	for _, r := range LoadRecords(db, "rev_") {
+		got = append(got, r.IsContaminant)
+	}
+
+	want := []bool{true, true, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("IsContaminant = %v, want %v", got, want)
+			break
+		}
+	}
+
+	if !IsContaminantSequence("msrqfssrsgyrsgggfssgsagiinyqr*") {
+		t.Errorf("IsContaminantSequence() = false, want true")
+	}
+
+	// at creation, one target with the contaminant sequence is replaced and the other one is tagged, or keeps
+	// its header when the contaminant tag is off
+	custom := filepath.Join(dir, "custom.fas")
+	content = ">sp|Q1|K1_MOUSE Keratin OS=Mus musculus OX=10090 PE=1 SV=1\nMSRQFSSRSGYRSGGGFSSGSAGIINYQR\n" +
+		">sp|Q2|K2_MOUSE Keratin OS=Mus musculus OX=10090 PE=1 SV=1\nMSRQFSSRSGYRSGGGFSSGSAGIINYQR\n" +
+		">sp|P1|A_HUMAN Protein A OS=Homo sapiens OX=9606 GN=A PE=1 SV=1\nMPEPTIDEK\n"
+	if e := os.WriteFile(custom, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, cTag := range []bool{true, false} {
+
+		base := New()
+		base.DownloadedFiles = []string{custom}
+		base.Create(dir, "", "trypsin", "rev_", "", "", "", "", lib, 0, false, true, cTag, nil)
+
+		if len(base.TaDeDB) != 3 {
+			t.Fatalf("Create() kept %d entries, want 3", len(base.TaDeDB))
+		}
+
+		for h, s := range base.TaDeDB {
+			if s == "MSRQFSSRSGYRSGGGFSSGSAGIINYQR" && strings.HasPrefix(h, ">contam_") != cTag {
+				t.Errorf("Create() with the contaminant tag %v wrote the header %s", cTag, h)
+			}
+		}
+
+		if _, ok := base.TaDeDB[">sp|P1|A_HUMAN Protein A OS=Homo sapiens OX=9606 GN=A PE=1 SV=1"]; !ok {
+			t.Errorf("Create() changed the header of a target without a contaminant sequence")
+		}
+	}
+}
@@ -7,22 +7,30 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/Nesvilab/philosopher/lib/cla"
 	"github.com/Nesvilab/philosopher/lib/msg"
 )
 
 // Record is the root of all database parsers
 type Record struct {
-	ID               string
-	OriginalHeader   string
-	PartHeader       string
-	EntryName        string
-	ProteinName      string
-	Organism         string
-	GeneNames        string
-	ProteinExistence string
-	Sequence         string
-	Class            dbtype
-	IsDecoy          bool
+	ID                  string
+	OriginalHeader      string
+	PartHeader          string
+	EntryName           string
+	ProteinName         string
+	Organism            string
+	GeneNames           string
+	ProteinExistence    string
+	Sequence            string
+	Class               dbtype
+	IsDecoy             bool
+	HeaderFormat        string
+	GeneSynonyms        []string
+	Keywords            []string
+	SubcellularLocation []string
+	GOTerms             []string
+	Features            []Feature
+	IsContaminant       bool
 }
 
 // ProcessHeader parses FASTA records looking for individial elements
@@ -44,7 +52,15 @@ func ProcessHeader(k, v string, class dbtype, tag string, verb bool) Record {
 		r.IsDecoy = true
 	}
 
+	r.IsContaminant = cla.IsContaminant(strings.TrimPrefix(k, tag))
+
 	r.Class = class
+
+	if class == custom {
+		processCustomHeader(&r, k, tag, verb)
+		return r
+	}
+
 	r.ID = getID(k, class, verb)
 	r.EntryName = getEntryName(k, class, verb)
 	r.ProteinName = getProteinName(k, class, verb)
@@ -95,7 +111,13 @@ func getProteinExistence(header string, class dbtype, verb bool) (match string)
 		match = header[reg[2]:reg[3]]
 	}
 
-	switch match {
+	return proteinExistence(match)
+}
+
+// proteinExistence translates the UniProt protein existence levels
+func proteinExistence(level string) string {
+
+	switch level {
 	case "1":
 		return "1:Experimental evidence at protein level"
 	case "2":
@@ -381,12 +403,37 @@ const (
 	tair
 	nextprot
 	generic
+	custom
 )
 
-// Classify determines what kind of database originated the given sequence
-func Classify(s, decoyTag string) dbtype {
+// String returns the name of the database flavour
+func (t dbtype) String() string {
+
+	switch t {
+	case uniprot:
+		return "uniprot"
+	case ncbi:
+		return "ncbi"
+	case cptac_ensembl:
+		return "cptac_ensembl"
+	case ensembl:
+		return "ensembl"
+	case uniref:
+		return "uniref"
+	case tair:
+		return "tair"
+	case nextprot:
+		return "nextprot"
+	case custom:
+		return "custom"
+	default:
+		return "generic"
+	}
+}
+
+// stripTags removes the decoy and contaminant tags so we can see better the seq header
+func stripTags(s, decoyTag string) string {
 
-	// remove the decoy and contamintant tags so we can see better the seq header
 	seq := s
 	if strings.HasPrefix(seq, decoyTag) {
 		seq = seq[len(decoyTag):]
@@ -397,6 +444,22 @@ func Classify(s, decoyTag string) dbtype {
 	if strings.HasPrefix(seq, decoyTag) {
 		seq = seq[len(decoyTag):]
 	}
+	if len(entrapmentTag) > 0 && strings.HasPrefix(seq, entrapmentTag) {
+		seq = seq[len(entrapmentTag):]
+	}
+
+	return seq
+}
+
+// Classify determines what kind of database originated the given sequence
+func Classify(s, decoyTag string) dbtype {
+
+	// remove the decoy and contamintant tags so we can see better the seq header
+	seq := stripTags(s, decoyTag)
+
+	if findHeaderFormat(seq) != nil {
+		return custom
+	}
 
 	if strings.HasPrefix(seq, "sp|") || strings.HasPrefix(seq, "tr|") || strings.HasPrefix(seq, "db|") {
 		return uniprot
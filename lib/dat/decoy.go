@@ -0,0 +1,216 @@
+package dat
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/sirupsen/logrus"
+)
+
+// decoy generation methods
+const (
+	DecoyReverse       = "reverse"
+	DecoyPseudoReverse = "pseudo-reverse"
+	DecoyShuffle       = "shuffle"
+)
+
+// minCollisionLength is the shortest peptide considered when comparing decoys and targets
+const minCollisionLength = 7
+
+// maxShuffleAttempts limits how many times a peptide is reshuffled to avoid a target collision
+const maxShuffleAttempts = 100
+
+// DecoyMaker builds decoy sequences from target sequences
+type DecoyMaker struct {
+	Method  string
+	Enzyme  bio.Enzyme
+	Seed    int64
+	rng     *rand.Rand
+	targets map[string]struct{}
+	// shuffleCollisions counts the peptides that still match a target after every shuffle attempt
+	shuffleCollisions int
+}
+
+// NewDecoyMaker creates a decoy builder for the given method and enzyme
+func NewDecoyMaker(method, enz string, seed int64) DecoyMaker {
+
+	var d DecoyMaker
+
+	if len(method) == 0 {
+		method = DecoyReverse
+	}
+
+	switch method {
+	case DecoyReverse, DecoyPseudoReverse, DecoyShuffle:
+		d.Method = method
+	default:
+		msg.Custom(errors.New("decoy method not supported, use reverse, pseudo-reverse or shuffle"), "fatal")
+	}
+
+	d.Enzyme.Synth(enz)
+	d.Seed = seed
+	d.rng = rand.New(rand.NewSource(seed))
+	d.targets = make(map[string]struct{})
+
+	return d
+}
+
+// AddTargets registers the target peptides used to check decoys for collisions
+func (d *DecoyMaker) AddTargets(seqs []string) {
+	dig := bio.Digestion{Enzyme: d.Enzyme, Specificity: bio.Specific, MinLength: minCollisionLength}
+	for _, s := range seqs {
+		for _, p := range dig.Digest(s) {
+			d.targets[p.Sequence] = struct{}{}
+		}
+	}
+}
+
+// Build returns the decoy version of the given target sequence
+func (d *DecoyMaker) Build(seq string) string {
+
+	switch d.Method {
+	case DecoyPseudoReverse:
+		return d.perPeptide(seq, pseudoReverse)
+	case DecoyShuffle:
+		return d.perPeptide(seq, d.shuffle)
+	default:
+		return reverseSeq(seq)
+	}
+}
+
+// Collisions counts the distinct decoy peptides that are identical to a target peptide
+func (d *DecoyMaker) Collisions(decoys []string) (int, int) {
+
+	var seen = make(map[string]struct{})
+	var collisions int
+
+	dig := bio.Digestion{Enzyme: d.Enzyme, Specificity: bio.Specific, MinLength: minCollisionLength}
+	for _, s := range decoys {
+		for _, p := range dig.Digest(s) {
+			if _, ok := seen[p.Sequence]; ok {
+				continue
+			}
+			seen[p.Sequence] = struct{}{}
+			if _, ok := d.targets[p.Sequence]; ok {
+				collisions++
+			}
+		}
+	}
+
+	return collisions, len(seen)
+}
+
+// ShuffleCollisions counts the shuffled peptides that match a target on every attempt
+func (d *DecoyMaker) ShuffleCollisions() int {
+	return d.shuffleCollisions
+}
+
+// perPeptide applies a peptide-level transformation while keeping the cleavage
+// residue of each peptide, and the initiator methionine, in place. The transformation
+// receives the residues kept around the body, so it can compare the full peptide
+func (d *DecoyMaker) perPeptide(seq string, f func(prefix, body, suffix string) string) string {
+
+	var b strings.Builder
+	b.Grow(len(seq))
+
+	sites := d.Enzyme.CleavageSites(seq)
+	for i := 0; i < len(sites)-1; i++ {
+
+		p := seq[sites[i]:sites[i+1]]
+
+		var lead string
+		if i == 0 && strings.HasPrefix(p, "M") {
+			lead = "M"
+			p = p[1:]
+		}
+
+		var prefix, suffix string
+		switch {
+		case len(p) == 0:
+		case len(p) > 1 && d.Enzyme.CutsBefore(p[0]) && d.Enzyme.CutsAfter(p[len(p)-1]):
+			prefix, p, suffix = p[:1], p[1:len(p)-1], p[len(p)-1:]
+		case d.Enzyme.CutsBefore(p[0]):
+			prefix, p = p[:1], p[1:]
+		case d.Enzyme.CutsAfter(p[len(p)-1]):
+			p, suffix = p[:len(p)-1], p[len(p)-1:]
+		}
+
+		b.WriteString(lead)
+		b.WriteString(prefix)
+		b.WriteString(f(lead+prefix, p, suffix))
+		b.WriteString(suffix)
+	}
+
+	return b.String()
+}
+
+// shuffle randomly permutes a peptide body, retrying when the peptide with its kept residues
+// is a known target. Bodies that collide on every attempt are counted
+func (d *DecoyMaker) shuffle(prefix, s, suffix string) string {
+
+	if len(s) < 2 {
+		return s
+	}
+
+	r := []byte(s)
+	for n := 0; n < maxShuffleAttempts; n++ {
+		d.rng.Shuffle(len(r), func(i, j int) { r[i], r[j] = r[j], r[i] })
+		if string(r) == s {
+			continue
+		}
+		if _, ok := d.targets[prefix+string(r)+suffix]; !ok {
+			return string(r)
+		}
+	}
+
+	d.shuffleCollisions++
+
+	return string(r)
+}
+
+// pseudoReverse reverses a peptide body
+func pseudoReverse(prefix, s, suffix string) string {
+	r := []byte(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// addDecoys builds the decoy entries for every target in the database and reports target collisions
+func (d *Base) addDecoys(tag string, maker DecoyMaker) {
+
+	var headers []string
+	var targets []string
+	for k, v := range d.TaDeDB {
+		headers = append(headers, k)
+		targets = append(targets, v)
+	}
+
+	// map iteration order is random, sorting keeps seeded decoys reproducible
+	sort.Strings(headers)
+
+	maker.AddTargets(targets)
+
+	var decoys []string
+	for _, h := range headers {
+		dh := ">" + tag + strings.TrimPrefix(h, ">")
+		decoy := maker.Build(d.TaDeDB[h])
+		d.TaDeDB[dh] = decoy
+		decoys = append(decoys, decoy)
+	}
+
+	if n := maker.ShuffleCollisions(); n > 0 {
+		msg.Custom(fmt.Errorf("%d shuffled peptides still match a target peptide after %d attempts", n, maxShuffleAttempts), "warning")
+	}
+
+	collisions, total := maker.Collisions(decoys)
+	d.DecoyCollisions = collisions
+
+	logrus.Info(fmt.Sprintf("Decoy method %s: %d of %d decoy peptides match a target peptide", maker.Method, collisions, total))
+}
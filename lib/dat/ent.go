@@ -0,0 +1,47 @@
+package dat
+
+import (
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/fas"
+)
+
+// entrapmentTag marks sequences from the entrapment proteome, it is stripped before the header is classified
+var entrapmentTag string
+
+// addEntrapment appends the entrapment proteome to the target sequences and updates the entrapment ratio
+func (d *Base) addEntrapment(file, tag string) {
+
+	targets := len(d.TaDeDB)
+
+	entrapment := fas.ParseFile(file)
+	for h, s := range entrapment {
+		d.TaDeDB[">"+tag+h] = s
+	}
+
+	if targets > 0 {
+		d.EntrapmentRatio = float64(len(entrapment)) / float64(targets)
+	}
+}
+
+// EntrapmentRatio returns the number of entrapment sequences for each target sequence in a FASTA file
+func EntrapmentRatio(file, decoyTag, entrapTag string) float64 {
+
+	var targets, entrapment int
+
+	for _, i := range fas.ParseFile2(file) {
+		if strings.HasPrefix(i.Header, decoyTag) {
+			continue
+		} else if strings.HasPrefix(i.Header, entrapTag) {
+			entrapment++
+		} else {
+			targets++
+		}
+	}
+
+	if targets == 0 {
+		return 0
+	}
+
+	return float64(entrapment) / float64(targets)
+}
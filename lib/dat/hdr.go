@@ -0,0 +1,235 @@
+package dat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/fas"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"gopkg.in/yaml.v2"
+)
+
+// HeaderFormat is a user-defined FASTA header layout. A header belongs to the format when
+// the pattern matches it, and the record fields are read from the named capture groups:
+// id, entry_name, protein_name, gene, organism and existence
+type HeaderFormat struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	re      *regexp.Regexp
+}
+
+// HeaderFormatFile is the layout of a header format configuration file
+type HeaderFormatFile struct {
+	Formats []HeaderFormat `yaml:"formats"`
+}
+
+var headerGroups = map[string]bool{"id": true, "entry_name": true, "protein_name": true, "gene": true, "organism": true, "existence": true}
+
+var headerFormats []HeaderFormat
+
+var forcedHeaderFormat *HeaderFormat
+
+// builtinHeaderFormats are formats shipped with philosopher that are not used for detection, so the
+// built-in parsers keep classifying those headers. They are used when selected with --header-format
+var builtinHeaderFormats = []HeaderFormat{
+	{
+		Name:    "gencode",
+		Pattern: `^(?P<id>ENSP\d+\.\d+)\|ENST\d+\.\d+\|ENSG\d+\.\d+\|[^|]*\|[^|]*\|(?P<entry_name>[^|]+)\|(?P<gene>[^|]+)\|\d+\|?`,
+	},
+}
+
+// RegisterHeaderFormat compiles and adds a header format to the registry, formats are tried in registration order
+func RegisterHeaderFormat(f HeaderFormat) {
+
+	re, e := regexp.Compile(f.Pattern)
+	if e != nil {
+		msg.Custom(fmt.Errorf("header format %s has an invalid pattern: %s", f.Name, e), "error")
+	}
+
+	if len(f.Name) == 0 {
+		msg.Custom(errors.New("header formats need a name"), "error")
+	}
+
+	for _, i := range re.SubexpNames() {
+		if len(i) > 0 && !headerGroups[i] {
+			msg.Custom(fmt.Errorf("header format %s has an unknown field %s", f.Name, i), "error")
+		}
+	}
+
+	f.re = re
+
+	for i := range headerFormats {
+		if headerFormats[i].Name == f.Name {
+			headerFormats[i] = f
+			return
+		}
+	}
+
+	// user formats take precedence over the ones already registered
+	headerFormats = append([]HeaderFormat{f}, headerFormats...)
+}
+
+// LoadHeaderFormats reads the header formats declared on a YAML file, built-in formats such as gencode
+// can be listed by name without a pattern
+func LoadHeaderFormats(file string) {
+
+	content, e := os.ReadFile(file)
+	if e != nil {
+		msg.ReadFile(e, "error")
+	}
+
+	var f HeaderFormatFile
+	e = yaml.Unmarshal(content, &f)
+	if e != nil {
+		msg.ReadFile(fmt.Errorf("cannot parse the header format file: %s", e), "error")
+	}
+
+	for i := len(f.Formats) - 1; i >= 0; i-- {
+
+		// a format listed only by name enables the built-in format with that name
+		if len(f.Formats[i].Pattern) == 0 {
+			for _, j := range builtinHeaderFormats {
+				if j.Name == f.Formats[i].Name {
+					f.Formats[i] = j
+				}
+			}
+		}
+
+		RegisterHeaderFormat(f.Formats[i])
+	}
+}
+
+// SetHeaderFormat forces every header to be parsed with the given format, an empty name restores the detection
+func SetHeaderFormat(name string) {
+
+	forcedHeaderFormat = nil
+
+	if len(name) == 0 {
+		return
+	}
+
+	for i := range headerFormats {
+		if headerFormats[i].Name == name {
+			forcedHeaderFormat = &headerFormats[i]
+			return
+		}
+	}
+
+	for _, i := range builtinHeaderFormats {
+		if i.Name == name {
+			RegisterHeaderFormat(i)
+			SetHeaderFormat(name)
+			return
+		}
+	}
+
+	msg.Custom(fmt.Errorf("header format %s is not defined", name), "error")
+}
+
+// findHeaderFormat returns the format used for the given header, or nil when a built-in parser applies
+func findHeaderFormat(header string) *HeaderFormat {
+
+	if forcedHeaderFormat != nil {
+		return forcedHeaderFormat
+	}
+
+	for i := range headerFormats {
+		if headerFormats[i].re.MatchString(header) {
+			return &headerFormats[i]
+		}
+	}
+
+	return nil
+}
+
+// fields returns the named groups captured on the header
+func (f HeaderFormat) fields(header string) map[string]string {
+
+	var fields = make(map[string]string)
+
+	match := f.re.FindStringSubmatch(header)
+	if match == nil {
+		return fields
+	}
+
+	for i, name := range f.re.SubexpNames() {
+		if len(name) > 0 {
+			fields[name] = strings.TrimSpace(match[i])
+		}
+	}
+
+	return fields
+}
+
+// processCustomHeader fills the record using a user-defined header format
+func processCustomHeader(r *Record, header, tag string, verb bool) {
+
+	f := findHeaderFormat(stripTags(header, tag))
+	if f == nil {
+		return
+	}
+
+	r.HeaderFormat = f.Name
+
+	fields := f.fields(stripTags(header, tag))
+	if len(fields) == 0 && verb {
+		m := fmt.Sprintf("[%s]\n%s", f.Name, header)
+		msg.ParsingFASTAHeader(errors.New(m), "info")
+	}
+
+	r.ID = fields["id"]
+	if len(r.ID) == 0 {
+		r.ID = r.PartHeader
+	}
+
+	r.EntryName = fields["entry_name"]
+	r.ProteinName = fields["protein_name"]
+	r.GeneNames = fields["gene"]
+	r.Organism = fields["organism"]
+	r.ProteinExistence = proteinExistence(fields["existence"])
+	if len(r.ProteinExistence) == 0 {
+		r.ProteinExistence = fields["existence"]
+	}
+}
+
+// WriteHeaderReport writes how every header of the given FASTA file was parsed
+func WriteHeaderReport(filename, tag, home string) string {
+
+	output := fmt.Sprintf("%s%sheaders.tsv", home, string(filepath.Separator))
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(e, "error")
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	defer w.Flush()
+
+	_, e = fmt.Fprintln(w, "Header\tFormat\tProtein ID\tEntry Name\tProtein Description\tGene\tOrganism\tProtein Existence")
+	if e != nil {
+		msg.WriteToFile(e, "error")
+	}
+
+	for _, i := range fas.ParseFile2(filename) {
+
+		r := ProcessHeader(i.Header, i.Seq, Classify(i.Header, tag), tag, false)
+
+		format := r.HeaderFormat
+		if len(format) == 0 {
+			format = r.Class.String()
+		}
+
+		_, e = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", i.Header, format, r.ID, r.EntryName, r.ProteinName, r.GeneNames, r.Organism, r.ProteinExistence)
+		if e != nil {
+			msg.WriteToFile(e, "error")
+		}
+	}
+
+	return output
+}
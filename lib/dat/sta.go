@@ -0,0 +1,388 @@
+package dat
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/cla"
+	"github.com/Nesvilab/philosopher/lib/fas"
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/sirupsen/logrus"
+)
+
+// statsDigestion are the digestion limits used for the theoretical peptide counts
+var statsDigestion = bio.Digestion{Specificity: bio.Specific, MissedCleavages: 2, MinLength: 7, MaxLength: 50}
+
+// lengthBins are the upper limits of the sequence length histogram, the last bin is open
+var lengthBins = []int{100, 200, 300, 400, 500, 750, 1000, 1500, 2000, 5000}
+
+// DatabaseStats summarizes the content of a protein database
+type DatabaseStats struct {
+	Total        int
+	Targets      int
+	Decoys       int
+	Contaminants int
+	Entrapments  int
+	Organisms    map[string]int
+	Existence    map[string]int
+	Formats      map[string]int
+	Lengths      []int
+	Peptides     map[string]int
+}
+
+// DatabaseDiff holds the differences between two protein databases, compared by accession and sequence
+type DatabaseDiff struct {
+	Added          []Record
+	Removed        []Record
+	SequenceChange [][2]Record
+	HeaderChange   [][2]Record
+	Unchanged      int
+}
+
+// LoadRecords reads the records of a db.bin file, a workspace or a FASTA file, an empty path
+// reads the current workspace
+func LoadRecords(path, tag string) []Record {
+
+	var d Base
+
+	if len(path) == 0 {
+		d.Restore()
+		return d.Records
+	}
+
+	info, e := os.Stat(path)
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	if info.IsDir() {
+		d.RestoreWithPath(path)
+		return d.Records
+	}
+
+	if strings.HasSuffix(path, ".bin") {
+		abs, _ := filepath.Abs(path)
+		d.restoreImpl(abs)
+		return d.Records
+	}
+
+	var records []Record
+	for _, i := range fas.ParseFile2(path) {
+		r := ProcessHeader(i.Header, i.Seq, Classify(i.Header, tag), tag, false)
+		r.detectContaminant()
+		records = append(records, r)
+	}
+
+	return records
+}
+
+// ComputeStats counts the database records by class, organism, existence level and length, and
+// the theoretical peptides of each enzyme
+func ComputeStats(records []Record, tag string, enzymes []string) DatabaseStats {
+
+	var s = DatabaseStats{
+		Organisms: make(map[string]int),
+		Existence: make(map[string]int),
+		Formats:   make(map[string]int),
+		Lengths:   make([]int, len(lengthBins)+1),
+		Peptides:  make(map[string]int),
+	}
+
+	var targets []string
+
+	for _, i := range records {
+
+		s.Total++
+
+		switch {
+		case i.IsDecoy || strings.HasPrefix(i.OriginalHeader, tag):
+			s.Decoys++
+			continue
+		case i.IsContaminant || cla.IsContaminant(i.OriginalHeader):
+			s.Contaminants++
+		case len(entrapmentTag) > 0 && strings.HasPrefix(i.OriginalHeader, entrapmentTag):
+			s.Entrapments++
+		default:
+			s.Targets++
+		}
+
+		s.Organisms[valueOrNA(i.Organism)]++
+		s.Existence[valueOrNA(i.ProteinExistence)]++
+
+		format := i.HeaderFormat
+		if len(format) == 0 {
+			format = i.Class.String()
+		}
+		s.Formats[format]++
+
+		s.Lengths[sort.SearchInts(lengthBins, len(i.Sequence))]++
+
+		targets = append(targets, i.Sequence)
+	}
+
+	for _, e := range enzymes {
+
+		enz, ok := bio.GetEnzyme(e)
+		if !ok {
+			msg.Custom(fmt.Errorf("enzyme %s is not defined", e), "warning")
+			continue
+		}
+
+		dig := statsDigestion
+		dig.Enzyme = enz
+
+		var peptides = make(map[string]struct{})
+		for _, i := range targets {
+			for _, p := range dig.Digest(i) {
+				peptides[p.Sequence] = struct{}{}
+			}
+		}
+
+		s.Peptides[enz.Name] = len(peptides)
+	}
+
+	return s
+}
+
+// Print shows the database statistics
+func (s DatabaseStats) Print() {
+
+	logrus.WithFields(logrus.Fields{
+		"total":        s.Total,
+		"targets":      s.Targets,
+		"decoys":       s.Decoys,
+		"contaminants": s.Contaminants,
+		"entrapments":  s.Entrapments,
+	}).Info("Database entries")
+
+	fmt.Println("\nOrganism\tEntries")
+	printCounts(s.Organisms)
+
+	fmt.Println("\nProtein Existence\tEntries")
+	printCounts(s.Existence)
+
+	fmt.Println("\nHeader Format\tEntries")
+	printCounts(s.Formats)
+
+	fmt.Println("\nSequence Length\tEntries")
+	for i, n := range s.Lengths {
+		var bin string
+		switch {
+		case i == 0:
+			bin = fmt.Sprintf("1-%d", lengthBins[0])
+		case i == len(lengthBins):
+			bin = fmt.Sprintf(">%d", lengthBins[i-1])
+		default:
+			bin = fmt.Sprintf("%d-%d", lengthBins[i-1]+1, lengthBins[i])
+		}
+		fmt.Printf("%s\t%d\n", bin, n)
+	}
+
+	fmt.Printf("\nEnzyme\tPeptides (%d-%d AA, up to %d missed cleavages)\n", statsDigestion.MinLength, statsDigestion.MaxLength, statsDigestion.MissedCleavages)
+	printCounts(s.Peptides)
+	fmt.Println()
+}
+
+// printCounts lists the counts from the largest to the smallest
+func printCounts(counts map[string]int) {
+
+	var keys []string
+	for k := range counts {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] == counts[keys[j]] {
+			return keys[i] < keys[j]
+		}
+		return counts[keys[i]] > counts[keys[j]]
+	})
+
+	for _, k := range keys {
+		fmt.Printf("%s\t%d\n", k, counts[k])
+	}
+}
+
+// CompareDatabases matches the target records of two databases by accession, and reports the
+// entries that were added, removed or changed their sequence or header
+func CompareDatabases(old, updated []Record, tag string) DatabaseDiff {
+
+	var diff DatabaseDiff
+
+	index := func(records []Record) (map[string]Record, []string) {
+		var m = make(map[string]Record)
+		var keys []string
+		for _, i := range records {
+			if i.IsDecoy || strings.HasPrefix(i.OriginalHeader, tag) {
+				continue
+			}
+			k := accession(i)
+			if _, ok := m[k]; !ok {
+				keys = append(keys, k)
+			}
+			m[k] = i
+		}
+		sort.Strings(keys)
+		return m, keys
+	}
+
+	oldMap, oldKeys := index(old)
+	newMap, newKeys := index(updated)
+
+	for _, k := range oldKeys {
+
+		o := oldMap[k]
+		n, ok := newMap[k]
+
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, o)
+		case sequenceHash(o.Sequence) != sequenceHash(n.Sequence):
+			diff.SequenceChange = append(diff.SequenceChange, [2]Record{o, n})
+		case o.OriginalHeader != n.OriginalHeader:
+			diff.HeaderChange = append(diff.HeaderChange, [2]Record{o, n})
+		default:
+			diff.Unchanged++
+		}
+	}
+
+	for _, k := range newKeys {
+		if _, ok := oldMap[k]; !ok {
+			diff.Added = append(diff.Added, newMap[k])
+		}
+	}
+
+	return diff
+}
+
+// Print shows the database comparison summary
+func (d DatabaseDiff) Print() {
+	logrus.WithFields(logrus.Fields{
+		"added":            len(d.Added),
+		"removed":          len(d.Removed),
+		"sequence changed": len(d.SequenceChange),
+		"header changed":   len(d.HeaderChange),
+		"unchanged":        d.Unchanged,
+	}).Info("Database comparison")
+}
+
+// WriteReport writes one line per entry that differs between the two databases
+func (d DatabaseDiff) WriteReport(home string) string {
+
+	output := fmt.Sprintf("%s%sdatabase_diff.tsv", home, string(filepath.Separator))
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(e, "error")
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	defer w.Flush()
+
+	line := func(status string, o, n Record) {
+		acc := accession(o)
+		if len(acc) == 0 {
+			acc = accession(n)
+		}
+		_, e := fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\n", acc, status, o.OriginalHeader, n.OriginalHeader, len(o.Sequence), len(n.Sequence))
+		if e != nil {
+			msg.WriteToFile(e, "error")
+		}
+	}
+
+	_, e = fmt.Fprintln(w, "Accession\tStatus\tOld Header\tNew Header\tOld Length\tNew Length")
+	if e != nil {
+		msg.WriteToFile(e, "error")
+	}
+
+	for _, i := range d.Added {
+		line("added", Record{}, i)
+	}
+
+	for _, i := range d.Removed {
+		line("removed", i, Record{})
+	}
+
+	for _, i := range d.SequenceChange {
+		line("sequence changed", i[0], i[1])
+	}
+
+	for _, i := range d.HeaderChange {
+		line("header changed", i[0], i[1])
+	}
+
+	return output
+}
+
+// accession returns the record identifier used to match entries between databases
+func accession(r Record) string {
+	if len(r.ID) > 0 {
+		return r.ID
+	}
+	return r.PartHeader
+}
+
+// sequenceHash returns the SHA-1 digest of a protein sequence
+func sequenceHash(seq string) string {
+	h := sha1.Sum([]byte(strings.ToUpper(seq)))
+	return hex.EncodeToString(h[:])
+}
+
+func valueOrNA(s string) string {
+	if len(s) == 0 {
+		return "NA"
+	}
+	return s
+}
+
+// inspect runs the statistics and comparison modes, which read databases without changing the workspace
+func inspect(m met.Data) {
+
+	if len(m.Database.EnzymeFile) > 0 {
+		bio.LoadEnzymes(m.Database.EnzymeFile)
+	}
+
+	if len(m.Database.HeaderFile) > 0 {
+		LoadHeaderFormats(m.Database.HeaderFile)
+	}
+
+	entrapmentTag = m.Database.EntrapTag
+
+	if len(m.Database.ContamFile) > 0 {
+		LoadContaminants(contaminantFiles(m.Database.ContamFile))
+	}
+
+	if m.Database.Stats {
+		records := LoadRecords(m.Database.Annot, m.Database.Tag)
+		ComputeStats(records, m.Database.Tag, strings.Split(m.Database.Enz, ",")).Print()
+	}
+
+	if len(m.Database.Diff) > 0 {
+
+		files := strings.Split(m.Database.Diff, ",")
+		if len(files) > 2 {
+			msg.Custom(errors.New("the comparison takes one or two databases"), "fatal")
+		}
+
+		// a single database is compared against the workspace
+		if len(files) == 1 {
+			files = append([]string{""}, files...)
+		}
+
+		logrus.Info("Comparing databases")
+		diff := CompareDatabases(LoadRecords(files[0], m.Database.Tag), LoadRecords(files[1], m.Database.Tag), m.Database.Tag)
+		diff.Print()
+
+		logrus.Info("Writing the comparison report to ", diff.WriteReport(m.Home))
+	}
+}
@@ -0,0 +1,649 @@
+package dat
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/sirupsen/logrus"
+)
+
+// Feature is a sequence annotation from a UniProt entry, positions are 1-based and inclusive
+type Feature struct {
+	Type        string
+	Start       int
+	End         int
+	Description string
+}
+
+// String formats the feature as type, position and description
+func (f Feature) String() string {
+
+	pos := strconv.Itoa(f.Start)
+	if f.End != f.Start {
+		pos = fmt.Sprintf("%d-%d", f.Start, f.End)
+	}
+
+	if len(f.Description) == 0 {
+		return fmt.Sprintf("%s %s", f.Type, pos)
+	}
+
+	return fmt.Sprintf("%s %s %s", f.Type, pos, f.Description)
+}
+
+// IsProcessing reports if the feature marks a processed region, such as signal peptides and propeptides
+func (f Feature) IsProcessing() bool {
+	switch f.Type {
+	case "INIT_MET", "SIGNAL", "TRANSIT", "PROPEP":
+		return true
+	}
+	return false
+}
+
+// IsPTM reports if the feature is a known post-translational modification site
+func (f Feature) IsPTM() bool {
+	switch f.Type {
+	case "MOD_RES", "CARBOHYD", "LIPID", "CROSSLNK", "DISULFID":
+		return true
+	}
+	return false
+}
+
+// UniProtEntry holds the fields of a UniProtKB entry that a FASTA header does not carry
+type UniProtEntry struct {
+	Accession           string
+	EntryName           string
+	Reviewed            bool
+	ProteinName         string
+	Organism            string
+	TaxonID             string
+	Gene                string
+	GeneSynonyms        []string
+	ProteinExistence    string
+	SequenceVersion     string
+	Keywords            []string
+	SubcellularLocation []string
+	GOTerms             []string
+	Features            []Feature
+	Sequence            string
+}
+
+// uniprotFeatures are the feature keys kept from the entries, with the names used on the XML format
+var uniprotFeatures = map[string]string{
+	"initiator methionine":          "INIT_MET",
+	"signal peptide":                "SIGNAL",
+	"transit peptide":               "TRANSIT",
+	"propeptide":                    "PROPEP",
+	"chain":                         "CHAIN",
+	"peptide":                       "PEPTIDE",
+	"modified residue":              "MOD_RES",
+	"lipid moiety-binding region":   "LIPID",
+	"glycosylation site":            "CARBOHYD",
+	"disulfide bond":                "DISULFID",
+	"cross-link":                    "CROSSLNK",
+	"sequence variant":              "VARIANT",
+	"non-terminal residue":          "NON_TER",
+	"non-standard amino acid":       "NON_STD",
+	"short sequence motif":          "MOTIF",
+	"topological domain":            "TOPO_DOM",
+	"transmembrane region":          "TRANSMEM",
+	"intramembrane region":          "INTRAMEM",
+	"site":                          "SITE",
+	"active site":                   "ACT_SITE",
+	"binding site":                  "BINDING",
+	"DNA-binding region":            "DNA_BIND",
+	"zinc finger region":            "ZN_FING",
+	"compositionally biased region": "COMPBIAS",
+}
+
+var uniprotExistence = map[string]string{
+	"evidence at protein level":    "1",
+	"evidence at transcript level": "2",
+	"inferred from homology":       "3",
+	"predicted":                    "4",
+	"uncertain":                    "5",
+}
+
+// uniprotAnnotations are the entries used to enrich the database records, indexed by accession
+var uniprotAnnotations map[string]*UniProtEntry
+
+var evidenceRegex = regexp.MustCompile(`\s*\{[^}]*\}`)
+
+// ReadUniProt parses a UniProtKB flat file (.dat) or XML file, gzipped files are supported
+func ReadUniProt(file string) []UniProtEntry {
+
+	f, e := os.Open(file)
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := strings.ToLower(file)
+
+	if strings.HasSuffix(name, ".gz") {
+		gz, e := gzip.NewReader(f)
+		if e != nil {
+			msg.ReadFile(e, "fatal")
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+
+	if strings.HasSuffix(name, ".xml") {
+		return parseUniProtXML(r)
+	}
+
+	return parseUniProtDat(r)
+}
+
+// LoadUniProtAnnotations reads a local UniProtKB file and uses its entries to enrich the database records
+func LoadUniProtAnnotations(file string) {
+
+	entries := ReadUniProt(file)
+
+	uniprotAnnotations = make(map[string]*UniProtEntry, len(entries))
+	for i := range entries {
+		uniprotAnnotations[entries[i].Accession] = &entries[i]
+	}
+
+	logrus.Info(fmt.Sprintf("Loaded %d UniProt annotations", len(entries)))
+}
+
+// Ingest converts a local UniProtKB file into a FASTA file, the offline alternative to Fetch
+func (d *Base) Ingest(file, temp string, rev bool) {
+
+	base := strings.TrimSuffix(filepath.Base(file), ".gz")
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	d.UniProtDB = fmt.Sprintf("%s%s%s.fas", temp, string(filepath.Separator), base)
+
+	output, e := os.Create(d.UniProtDB)
+	if e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+	defer output.Close()
+
+	w := bufio.NewWriter(output)
+	defer w.Flush()
+
+	var n int
+	for _, i := range ReadUniProt(file) {
+
+		if rev && !i.Reviewed {
+			continue
+		}
+
+		_, e = fmt.Fprintf(w, ">%s\n%s\n", i.Header(), i.Sequence)
+		if e != nil {
+			msg.WriteToFile(e, "fatal")
+		}
+		n++
+	}
+
+	logrus.Info(fmt.Sprintf("Ingested %d entries from %s", n, filepath.Base(file)))
+
+	d.DownloadedFiles = append(d.DownloadedFiles, d.UniProtDB)
+}
+
+// Header builds the FASTA header UniProt uses for the entry
+func (u UniProtEntry) Header() string {
+
+	db := "tr"
+	if u.Reviewed {
+		db = "sp"
+	}
+
+	header := fmt.Sprintf("%s|%s|%s %s", db, u.Accession, u.EntryName, u.ProteinName)
+
+	if len(u.Organism) > 0 {
+		header += " OS=" + u.Organism
+	}
+	if len(u.TaxonID) > 0 {
+		header += " OX=" + u.TaxonID
+	}
+	if len(u.Gene) > 0 {
+		header += " GN=" + u.Gene
+	}
+	if len(u.ProteinExistence) > 0 {
+		header += " PE=" + u.ProteinExistence
+	}
+	if len(u.SequenceVersion) > 0 {
+		header += " SV=" + u.SequenceVersion
+	}
+
+	return header
+}
+
+// annotate copies the UniProt annotations of the matching entry into the record
+func (r *Record) annotate() {
+
+	if uniprotAnnotations == nil {
+		return
+	}
+
+	u, ok := uniprotAnnotations[r.ID]
+	if !ok {
+		return
+	}
+
+	r.GeneSynonyms = u.GeneSynonyms
+	r.Keywords = u.Keywords
+	r.SubcellularLocation = u.SubcellularLocation
+	r.GOTerms = u.GOTerms
+	r.Features = u.Features
+}
+
+// parseUniProtDat reads the entries of a UniProtKB flat file
+func parseUniProtDat(r io.Reader) []UniProtEntry {
+
+	var entries []UniProtEntry
+	var u UniProtEntry
+	var seq strings.Builder
+	var location strings.Builder
+	var inLocation bool
+	var inDE bool
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	for scanner.Scan() {
+
+		line := scanner.Text()
+
+		if line == "//" {
+			for i := range u.Features {
+				u.Features[i].Description = strings.TrimSuffix(u.Features[i].Description, "\"")
+			}
+			u.Sequence = seq.String()
+			u.SubcellularLocation = splitLocations(location.String())
+			entries = append(entries, u)
+			u = UniProtEntry{}
+			seq.Reset()
+			location.Reset()
+			inLocation = false
+			inDE = false
+			continue
+		}
+
+		if len(line) < 2 {
+			continue
+		}
+
+		code := line[:2]
+		value := ""
+		if len(line) > 5 {
+			value = line[5:]
+		}
+
+		if code != "CC" {
+			inLocation = false
+		}
+
+		switch code {
+		case "ID":
+			fields := strings.Fields(value)
+			if len(fields) > 1 {
+				u.EntryName = fields[0]
+				u.Reviewed = strings.HasPrefix(fields[1], "Reviewed")
+			}
+		case "AC":
+			if len(u.Accession) == 0 {
+				u.Accession = strings.TrimSpace(strings.Split(value, ";")[0])
+			}
+		case "DT":
+			if idx := strings.Index(value, "sequence version "); idx != -1 {
+				u.SequenceVersion = strings.TrimSuffix(strings.TrimSpace(value[idx+len("sequence version "):]), ".")
+			}
+		case "DE":
+			// the first full name belongs to the recommended (or submitted) name
+			if strings.HasPrefix(value, "RecName:") || strings.HasPrefix(value, "SubName:") {
+				inDE = true
+			} else if !strings.HasPrefix(value, " ") {
+				inDE = false
+			}
+			if idx := strings.Index(value, "Full="); idx != -1 && inDE && len(u.ProteinName) == 0 {
+				u.ProteinName = cleanValue(value[idx+len("Full="):])
+			}
+		case "GN":
+			for _, i := range strings.Split(value, ";") {
+				i = strings.TrimSpace(i)
+				if strings.HasPrefix(i, "Name=") && len(u.Gene) == 0 {
+					u.Gene = cleanValue(i[len("Name="):])
+				} else if strings.HasPrefix(i, "Synonyms=") {
+					for _, j := range strings.Split(i[len("Synonyms="):], ",") {
+						u.GeneSynonyms = append(u.GeneSynonyms, cleanValue(j))
+					}
+				}
+			}
+		case "OS":
+			if len(u.Organism) == 0 {
+				u.Organism = strings.TrimSuffix(strings.TrimSpace(value), ".")
+			} else {
+				u.Organism += " " + strings.TrimSuffix(strings.TrimSpace(value), ".")
+			}
+		case "OX":
+			if idx := strings.Index(value, "NCBI_TaxID="); idx != -1 {
+				u.TaxonID = strings.TrimRight(strings.Fields(value[idx+len("NCBI_TaxID="):])[0], ";")
+			}
+		case "CC":
+			if strings.HasPrefix(value, "-!- ") {
+				inLocation = strings.HasPrefix(value, "-!- SUBCELLULAR LOCATION:")
+				if inLocation {
+					location.WriteString(strings.TrimPrefix(value, "-!- SUBCELLULAR LOCATION:"))
+				}
+			} else if inLocation {
+				location.WriteString(" " + strings.TrimSpace(value))
+			}
+		case "DR":
+			fields := strings.Split(value, ";")
+			if len(fields) > 2 && fields[0] == "GO" {
+				u.GOTerms = append(u.GOTerms, fmt.Sprintf("%s %s", strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2])))
+			}
+		case "PE":
+			u.ProteinExistence = strings.TrimSpace(strings.Split(value, ":")[0])
+		case "KW":
+			for _, i := range strings.Split(strings.TrimSuffix(strings.TrimSpace(value), "."), ";") {
+				if i = cleanValue(i); len(i) > 0 {
+					u.Keywords = append(u.Keywords, i)
+				}
+			}
+		case "FT":
+			parseFeatureLine(&u, value)
+		case "  ":
+			seq.WriteString(strings.ReplaceAll(value, " ", ""))
+		}
+	}
+
+	if e := scanner.Err(); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	return entries
+}
+
+// parseFeatureLine reads both the current (1..10, /note="") and the legacy (1 10 note.) feature layouts
+func parseFeatureLine(u *UniProtEntry, value string) {
+
+	// qualifier lines continue the last feature, notes keep the closing quote until the entry ends
+	if strings.HasPrefix(value, " ") {
+
+		if len(u.Features) == 0 {
+			return
+		}
+
+		f := &u.Features[len(u.Features)-1]
+		v := strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(v, "/note=\""):
+			f.Description = v[len("/note=\""):]
+		case strings.HasPrefix(v, "/"):
+		case len(f.Description) > 0 && !strings.HasSuffix(f.Description, "\""):
+			f.Description += " " + v
+		}
+
+		return
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return
+	}
+
+	var known bool
+	for _, i := range uniprotFeatures {
+		if i == fields[0] {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return
+	}
+
+	var f = Feature{Type: fields[0]}
+	var rest []string
+
+	if strings.Contains(fields[1], "..") {
+		pos := strings.SplitN(fields[1], "..", 2)
+		f.Start = featurePosition(pos[0])
+		f.End = featurePosition(pos[1])
+		rest = fields[2:]
+	} else if len(fields) > 2 && featurePosition(fields[2]) > 0 {
+		f.Start = featurePosition(fields[1])
+		f.End = featurePosition(fields[2])
+		rest = fields[3:]
+	} else {
+		f.Start = featurePosition(fields[1])
+		f.End = f.Start
+		rest = fields[2:]
+	}
+
+	f.Description = strings.TrimSuffix(strings.Join(rest, " "), ".")
+	u.Features = append(u.Features, f)
+}
+
+// featurePosition reads a feature position, uncertain positions (?, <, >) are kept when a number is present
+func featurePosition(s string) int {
+	p, _ := strconv.Atoi(strings.Trim(s, "<>?"))
+	return p
+}
+
+// cleanValue removes the evidence tags and trailing punctuation of a flat file value
+func cleanValue(s string) string {
+	s = evidenceRegex.ReplaceAllString(s, "")
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), ";."))
+}
+
+// splitLocations reads the location terms of a subcellular location comment
+func splitLocations(s string) []string {
+
+	if idx := strings.Index(s, "Note="); idx != -1 {
+		s = s[:idx]
+	}
+
+	s = evidenceRegex.ReplaceAllString(s, "")
+
+	var locations []string
+	var seen = make(map[string]struct{})
+
+	for _, i := range strings.Split(s, ".") {
+
+		// isoform specific locations are prefixed by [Isoform X]:
+		if idx := strings.Index(i, "]:"); idx != -1 {
+			i = i[idx+2:]
+		}
+
+		for _, j := range strings.Split(i, ";") {
+			j = strings.TrimSpace(j)
+			if _, ok := seen[j]; ok || len(j) == 0 {
+				continue
+			}
+			seen[j] = struct{}{}
+			locations = append(locations, j)
+		}
+	}
+
+	return locations
+}
+
+// xmlEntry is the subset of the UniProtKB XML entry used by the database
+type xmlEntry struct {
+	Dataset     string        `xml:"dataset,attr"`
+	Accessions  []string      `xml:"accession"`
+	Name        string        `xml:"name"`
+	FullName    string        `xml:"protein>recommendedName>fullName"`
+	SubName     string        `xml:"protein>submittedName>fullName"`
+	GeneNames   []xmlNameType `xml:"gene>name"`
+	Organism    []xmlNameType `xml:"organism>name"`
+	OrganismRef []xmlDBRef    `xml:"organism>dbReference"`
+	Comments    []xmlComment  `xml:"comment"`
+	DBRefs      []xmlDBRef    `xml:"dbReference"`
+	Existence   xmlNameType   `xml:"proteinExistence"`
+	Keywords    []string      `xml:"keyword"`
+	Features    []xmlFeature  `xml:"feature"`
+	Sequence    xmlSequence   `xml:"sequence"`
+}
+
+type xmlNameType struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlComment struct {
+	Type      string   `xml:"type,attr"`
+	Locations []string `xml:"subcellularLocation>location"`
+}
+
+type xmlDBRef struct {
+	Type       string `xml:"type,attr"`
+	ID         string `xml:"id,attr"`
+	Properties []struct {
+		Type  string `xml:"type,attr"`
+		Value string `xml:"value,attr"`
+	} `xml:"property"`
+}
+
+type xmlFeature struct {
+	Type        string `xml:"type,attr"`
+	Description string `xml:"description,attr"`
+	Begin       struct {
+		Position string `xml:"position,attr"`
+	} `xml:"location>begin"`
+	End struct {
+		Position string `xml:"position,attr"`
+	} `xml:"location>end"`
+	Position struct {
+		Position string `xml:"position,attr"`
+	} `xml:"location>position"`
+}
+
+type xmlSequence struct {
+	Version string `xml:"version,attr"`
+	Value   string `xml:",chardata"`
+}
+
+// parseUniProtXML streams the entries of a UniProtKB XML file
+func parseUniProtXML(r io.Reader) []UniProtEntry {
+
+	var entries []UniProtEntry
+
+	decoder := xml.NewDecoder(r)
+
+	for {
+
+		t, e := decoder.Token()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			msg.ReadFile(fmt.Errorf("cannot parse the UniProt XML file: %s", e), "fatal")
+		}
+
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "entry" {
+			continue
+		}
+
+		var x xmlEntry
+		if e := decoder.DecodeElement(&x, &se); e != nil {
+			msg.ReadFile(fmt.Errorf("cannot parse the UniProt XML file: %s", e), "fatal")
+		}
+
+		entries = append(entries, x.entry())
+	}
+
+	return entries
+}
+
+// entry converts the XML layout into a UniProt entry
+func (x xmlEntry) entry() UniProtEntry {
+
+	var u UniProtEntry
+
+	if len(x.Accessions) > 0 {
+		u.Accession = x.Accessions[0]
+	}
+
+	u.EntryName = x.Name
+	u.Reviewed = strings.EqualFold(x.Dataset, "Swiss-Prot")
+
+	u.ProteinName = x.FullName
+	if len(u.ProteinName) == 0 {
+		u.ProteinName = x.SubName
+	}
+
+	for _, i := range x.GeneNames {
+		if i.Type == "primary" && len(u.Gene) == 0 {
+			u.Gene = i.Value
+		} else if i.Type == "synonym" {
+			u.GeneSynonyms = append(u.GeneSynonyms, i.Value)
+		}
+	}
+
+	for _, i := range x.Organism {
+		if i.Type == "scientific" {
+			u.Organism = i.Value
+		}
+	}
+
+	for _, i := range x.OrganismRef {
+		if i.Type == "NCBI Taxonomy" {
+			u.TaxonID = i.ID
+		}
+	}
+
+	for _, i := range x.Comments {
+		if i.Type == "subcellular location" {
+			u.SubcellularLocation = append(u.SubcellularLocation, i.Locations...)
+		}
+	}
+
+	for _, i := range x.DBRefs {
+		if i.Type != "GO" {
+			continue
+		}
+		for _, j := range i.Properties {
+			if j.Type == "term" {
+				u.GOTerms = append(u.GOTerms, fmt.Sprintf("%s %s", i.ID, j.Value))
+			}
+		}
+	}
+
+	u.ProteinExistence = uniprotExistence[x.Existence.Type]
+	u.Keywords = x.Keywords
+
+	for _, i := range x.Features {
+
+		key, ok := uniprotFeatures[i.Type]
+		if !ok {
+			continue
+		}
+
+		f := Feature{Type: key, Description: i.Description}
+		if len(i.Position.Position) > 0 {
+			f.Start = featurePosition(i.Position.Position)
+			f.End = f.Start
+		} else {
+			f.Start = featurePosition(i.Begin.Position)
+			f.End = featurePosition(i.End.Position)
+		}
+
+		u.Features = append(u.Features, f)
+	}
+
+	u.SequenceVersion = x.Sequence.Version
+	u.Sequence = strings.Join(strings.Fields(x.Sequence.Value), "")
+
+	return u
+}
@@ -0,0 +1,287 @@
+package dat
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/sirupsen/logrus"
+)
+
+// VariantPrefix marks the protein entries created from a variant table
+const VariantPrefix = "var|"
+
+var (
+	substitutionRegex = regexp.MustCompile(`^([A-Z])(\d+)([A-Z*])$`)
+	deletionRegex     = regexp.MustCompile(`^([A-Z])(\d+)(?:_([A-Z])(\d+))?del$`)
+	insertionRegex    = regexp.MustCompile(`^([A-Z])(\d+)_([A-Z])(\d+)ins([A-Z]+)$`)
+	delinsRegex       = regexp.MustCompile(`^([A-Z])(\d+)(?:_([A-Z])(\d+))?delins([A-Z]+)$`)
+)
+
+func init() {
+	RegisterHeaderFormat(HeaderFormat{
+		Name:    "variant",
+		Pattern: `^var\|(?P<id>[^|]+)\|(?P<entry_name>\S+) (?P<protein_name>.+?)(?: GN=(?P<gene>\S+))? SRC=\S+ VAR=\S+ SMP=\S+$`,
+	})
+}
+
+// Variant is a single row from a variant table
+type Variant struct {
+	Protein  string
+	Change   string
+	Sample   string
+	Sequence string
+}
+
+// variantEntry is a deduplicated variant protein
+type variantEntry struct {
+	Source   string
+	Gene     string
+	Changes  []string
+	Samples  []string
+	Sequence string
+	ORF      int
+}
+
+// ReadVariants parses a tab-delimited table with the protein, variant, sample and (for novel ORFs) sequence columns
+func ReadVariants(file string) []Variant {
+
+	f, e := os.Open(file)
+	if e != nil {
+		msg.ReadFile(e, "error")
+	}
+	defer f.Close()
+
+	var variants []Variant
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(strings.ToLower(line), "protein\t") {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) < 2 {
+			msg.Custom(fmt.Errorf("malformed variant line: %s", line), "warning")
+			continue
+		}
+
+		var v Variant
+		v.Protein = strings.TrimSpace(parts[0])
+		v.Change = strings.TrimPrefix(strings.TrimSpace(parts[1]), "p.")
+		v.Sample = "NA"
+
+		if len(parts) > 2 && len(strings.TrimSpace(parts[2])) > 0 {
+			v.Sample = strings.ReplaceAll(strings.TrimSpace(parts[2]), " ", "_")
+		}
+
+		if len(parts) > 3 {
+			v.Sequence = strings.ToUpper(strings.TrimSpace(parts[3]))
+		}
+
+		variants = append(variants, v)
+	}
+
+	return variants
+}
+
+// ApplyVariant returns the protein sequence after the given change
+func ApplyVariant(seq, change string) (string, error) {
+
+	position := func(aa, pos string) (int, error) {
+		p, _ := strconv.Atoi(pos)
+		if p < 1 || p > len(seq) {
+			return 0, fmt.Errorf("position %d is outside the protein", p)
+		}
+		if seq[p-1] != aa[0] {
+			return 0, fmt.Errorf("reference residue %s does not match %c at position %d", aa, seq[p-1], p)
+		}
+		return p - 1, nil
+	}
+
+	span := func(m []string) (int, int, error) {
+		start, e := position(m[1], m[2])
+		if e != nil {
+			return 0, 0, e
+		}
+		end := start
+		if len(m[3]) > 0 {
+			end, e = position(m[3], m[4])
+			if e != nil {
+				return 0, 0, e
+			}
+		}
+		if end < start {
+			return 0, 0, errors.New("the variant range is inverted")
+		}
+		return start, end, nil
+	}
+
+	if m := substitutionRegex.FindStringSubmatch(change); m != nil {
+		p, e := position(m[1], m[2])
+		if e != nil {
+			return "", e
+		}
+		// a stop gain truncates the protein
+		if m[3] == "*" {
+			return seq[:p], nil
+		}
+		return seq[:p] + m[3] + seq[p+1:], nil
+	}
+
+	if m := delinsRegex.FindStringSubmatch(change); m != nil {
+		start, end, e := span(m)
+		if e != nil {
+			return "", e
+		}
+		return seq[:start] + m[5] + seq[end+1:], nil
+	}
+
+	if m := deletionRegex.FindStringSubmatch(change); m != nil {
+		start, end, e := span(m)
+		if e != nil {
+			return "", e
+		}
+		return seq[:start] + seq[end+1:], nil
+	}
+
+	if m := insertionRegex.FindStringSubmatch(change); m != nil {
+		start, end, e := span(m)
+		if e != nil {
+			return "", e
+		}
+		if end != start+1 {
+			return "", errors.New("insertions must be flanked by adjacent residues")
+		}
+		return seq[:end] + m[5] + seq[end:], nil
+	}
+
+	return "", fmt.Errorf("variant notation %s is not supported", change)
+}
+
+// addVariants builds the variant protein entries from a variant table
+func (d *Base) addVariants(file, tag string) {
+
+	type source struct {
+		seq  string
+		gene string
+	}
+
+	// the variant table may refer to proteins by accession or by the first header field
+	var proteins = make(map[string]source)
+	var known = make(map[string]struct{})
+	for h, s := range d.TaDeDB {
+		header := strings.TrimPrefix(h, ">")
+		class := Classify(header, tag)
+		r := ProcessHeader(header, s, class, tag, false)
+		proteins[r.PartHeader] = source{s, r.GeneNames}
+		if len(r.ID) > 0 {
+			proteins[r.ID] = source{s, r.GeneNames}
+		}
+		known[s] = struct{}{}
+	}
+
+	var entries = make(map[string]*variantEntry)
+	var orfs = make(map[string]int)
+	var skipped int
+
+	for _, v := range ReadVariants(file) {
+
+		var seq, gene string
+
+		if strings.EqualFold(v.Change, "orf") {
+			if len(v.Sequence) == 0 {
+				msg.Custom(fmt.Errorf("novel ORF %s has no sequence", v.Protein), "warning")
+				skipped++
+				continue
+			}
+			seq = strings.TrimSuffix(v.Sequence, "*")
+			v.Change = "ORF"
+		} else {
+			src, ok := proteins[v.Protein]
+			if !ok {
+				msg.Custom(fmt.Errorf("variant %s refers to protein %s, which is not in the database", v.Change, v.Protein), "warning")
+				skipped++
+				continue
+			}
+
+			var e error
+			seq, e = ApplyVariant(src.seq, v.Change)
+			if e != nil {
+				msg.Custom(fmt.Errorf("skipping variant %s on %s: %s", v.Change, v.Protein, e), "warning")
+				skipped++
+				continue
+			}
+			gene = src.gene
+		}
+
+		// variants that reproduce a known protein add nothing to the search space
+		if _, ok := known[seq]; ok || len(seq) == 0 {
+			skipped++
+			continue
+		}
+
+		entry, ok := entries[seq]
+		if !ok {
+			entry = &variantEntry{Source: v.Protein, Gene: gene, Sequence: seq}
+			entries[seq] = entry
+
+			// novel ORFs are numbered by source in the order of the table, so each one gets its own ID
+			if v.Change == "ORF" {
+				orfs[v.Protein]++
+				entry.ORF = orfs[v.Protein]
+			}
+		}
+
+		if !contains(entry.Changes, v.Change) {
+			entry.Changes = append(entry.Changes, v.Change)
+		}
+		if !contains(entry.Samples, v.Sample) {
+			entry.Samples = append(entry.Samples, v.Sample)
+		}
+	}
+
+	for _, i := range entries {
+		sort.Strings(i.Samples)
+		d.TaDeDB[">"+i.header()] = i.Sequence
+	}
+
+	logrus.Info(fmt.Sprintf("Added %d variant protein entries, %d variants skipped", len(entries), skipped))
+}
+
+// header builds a traceable FASTA header with the source protein, the variants and the samples
+func (v variantEntry) header() string {
+
+	accession := strings.NewReplacer("|", "_", " ", "_").Replace(v.Source)
+	id := fmt.Sprintf("%s_%s", accession, strings.ReplaceAll(v.Changes[0], "*", "X"))
+
+	desc := fmt.Sprintf("%s variant of %s", strings.Join(v.Changes, ";"), accession)
+	if v.Changes[0] == "ORF" {
+		id = fmt.Sprintf("%s_ORF%d", accession, v.ORF)
+		desc = "novel ORF " + accession
+	}
+
+	gene := ""
+	if len(v.Gene) > 0 {
+		gene = " GN=" + v.Gene
+	}
+
+	return fmt.Sprintf("%s%s|%s %s%s SRC=%s VAR=%s SMP=%s", VariantPrefix, id, id, desc, gene, accession, strings.Join(v.Changes, ";"), strings.Join(v.Samples, ";"))
+}
+
+func contains(list []string, s string) bool {
+	for _, i := range list {
+		if i == s {
+			return true
+		}
+	}
+	return false
+}
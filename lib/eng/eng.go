@@ -0,0 +1,143 @@
+// Package eng defines the interface of the database search engines and keeps the engines the pipeline can run
+package eng
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Nesvilab/philosopher/lib/met"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Engine is a database search engine, each engine registers itself with its name
+type Engine interface {
+	// Name is the engine name on the reports
+	Name() string
+	// Version reports the engine version, running the engine binary when needed
+	Version(m met.Data) (string, error)
+	// Prepare stores the search parameters on the meta data
+	Prepare(m *met.Data, s Search) error
+	// Extension is the extension of the spectra files the engine searches
+	Extension(m met.Data) string
+	// Run searches the spectra files
+	Run(m met.Data, files []string) met.Data
+	// Outputs lists the identification files, pepXML or pin, the search writes for the spectra files
+	Outputs(m met.Data, files []string) []string
+}
+
+// Search holds the pipeline search settings, the parameters are free-form and decoded by each engine
+type Search struct {
+	Database string
+	DecoyTag string
+	Params   map[string]interface{}
+}
+
+var engines = make(map[string]Engine)
+var lock sync.RWMutex
+
+// Register adds a search engine, engines are found by their lower case name
+func Register(e Engine) {
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	key := strings.ToLower(e.Name())
+	if _, ok := engines[key]; ok {
+		panic(fmt.Sprintf("the search engine %s is registered twice", e.Name()))
+	}
+
+	engines[key] = e
+}
+
+// Get returns the search engine registered with the name
+func Get(name string) (Engine, bool) {
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	e, ok := engines[strings.ToLower(name)]
+
+	return e, ok
+}
+
+// Names lists the registered search engines
+func Names() []string {
+
+	lock.RLock()
+	defer lock.RUnlock()
+
+	var names []string
+	for i := range engines {
+		names = append(names, i)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Decode copies the free-form parameters into an engine parameter structure, the keys are the yaml names
+// of its fields
+func Decode(params map[string]interface{}, dest interface{}) error {
+
+	if len(params) == 0 {
+		return nil
+	}
+
+	b, e := yaml.Marshal(params)
+	if e != nil {
+		return e
+	}
+
+	return yaml.UnmarshalStrict(b, dest)
+}
+
+// Encode converts an engine parameter structure into free-form parameters
+func Encode(src interface{}) (map[string]interface{}, error) {
+
+	var params = make(map[string]interface{})
+
+	b, e := yaml.Marshal(src)
+	if e != nil {
+		return nil, e
+	}
+
+	e = yaml.Unmarshal(b, &params)
+
+	return params, e
+}
+
+// Version runs an engine binary and returns the first line of its output mentioning the version
+func Version(name string, args ...string) (string, error) {
+
+	out, e := exec.Command(name, args...).CombinedOutput()
+	if len(out) == 0 && e != nil {
+		return "", e
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+
+	for _, i := range lines {
+		if strings.Contains(strings.ToLower(i), "version") {
+			return strings.TrimSpace(i), nil
+		}
+	}
+
+	return strings.TrimSpace(lines[0]), nil
+}
+
+// ReplaceExtension lists the files named after the spectra files with a new extension
+func ReplaceExtension(files []string, ext string) []string {
+
+	var outputs []string
+
+	for _, i := range files {
+		outputs = append(outputs, strings.TrimSuffix(i, filepath.Ext(i))+ext)
+	}
+
+	return outputs
+}
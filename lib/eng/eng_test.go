@@ -0,0 +1,97 @@
+package eng
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/met"
+)
+
+type fake struct{}
+
+func (fake) Name() string                                { return "Fake" }
+func (fake) Version(m met.Data) (string, error)          { return "fake 1.0", nil }
+func (fake) Prepare(m *met.Data, s Search) error         { return Decode(s.Params, &m.Comet) }
+func (fake) Extension(m met.Data) string                 { return m.Comet.RawExtension }
+func (fake) Run(m met.Data, files []string) met.Data     { return m }
+func (fake) Outputs(m met.Data, files []string) []string { return ReplaceExtension(files, ".pep.xml") }
+
+func TestRegister(t *testing.T) {
+
+	Register(fake{})
+	defer delete(engines, "fake")
+
+	e, ok := Get("FAKE")
+	if !ok {
+		t.Fatalf("the engine was not found, got %v", Names())
+	}
+
+	var m met.Data
+	if err := e.Prepare(&m, Search{Params: map[string]interface{}{"raw": "mzML", "noindex": true}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Extension(m) != "mzML" || !m.Comet.NoIndex {
+		t.Errorf("the parameters are incorrect, got %+v", m.Comet)
+	}
+
+	if got := e.Outputs(m, []string{"/data/a.mzML"}); !reflect.DeepEqual(got, []string{"/data/a.pep.xml"}) {
+		t.Errorf("the outputs are incorrect, got %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering the engine twice should panic")
+		}
+	}()
+	Register(fake{})
+}
+
+func TestDecode(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		params  map[string]interface{}
+		dest    interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"comet", map[string]interface{}{"param": "comet.params", "raw": "mzML"}, &met.Comet{}, &met.Comet{Param: "comet.params", RawExtension: "mzML"}, false},
+		{"sage", map[string]interface{}{"path": "sage", "static_mods": map[interface{}]interface{}{"C": 57.0215}, "precursor_tol": []interface{}{-20, 20}}, &met.Sage{}, &met.Sage{BinPath: "sage", StaticMods: map[string]float64{"C": 57.0215}, PrecursorTol: []float64{-20, 20}}, false},
+		{"unknown", map[string]interface{}{"params": "comet.params"}, &met.Comet{}, nil, true},
+		{"empty", nil, &met.Comet{}, &met.Comet{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			e := Decode(tt.params, tt.dest)
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(tt.dest, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", tt.dest, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+
+	src := met.MSFragger{JarPath: "MSFragger.jar", Extension: "mzML", Threads: 8}
+
+	params, e := Encode(src)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var got met.MSFragger
+	if e := Decode(params, &got); e != nil {
+		t.Fatal(e)
+	}
+
+	if got.JarPath != src.JarPath || got.Extension != src.Extension || got.Threads != src.Threads {
+		t.Errorf("the parameters changed on the way back, got %+v, want %+v", got, src)
+	}
+}
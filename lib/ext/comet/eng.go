@@ -0,0 +1,50 @@
+package comet
+
+import (
+	"github.com/Nesvilab/philosopher/lib/eng"
+	"github.com/Nesvilab/philosopher/lib/met"
+)
+
+func init() {
+	eng.Register(engine{})
+}
+
+// engine runs Comet on the pipeline
+type engine struct{}
+
+// Name of the engine
+func (engine) Name() string {
+	return "Comet"
+}
+
+// Version runs the deployed binary, Comet prints its version with the usage
+func (engine) Version(m met.Data) (string, error) {
+
+	var cmt = New(m.Temp)
+	cmt.Deploy(m.Arch)
+
+	return eng.Version(cmt.DefaultBin)
+}
+
+// Prepare decodes the parameters into the Comet options
+func (engine) Prepare(m *met.Data, s eng.Search) error {
+
+	m.Comet = met.Comet{}
+
+	return eng.Decode(s.Params, &m.Comet)
+}
+
+// Extension of the spectra files
+func (engine) Extension(m met.Data) string {
+	return m.Comet.RawExtension
+}
+
+// Run searches the spectra files
+func (engine) Run(m met.Data, files []string) met.Data {
+	return Run(m, files)
+}
+
+// Outputs are the pepXML files written next to the spectra files
+func (engine) Outputs(m met.Data, files []string) []string {
+	return eng.ReplaceExtension(files, ".pep.xml")
+}
@@ -0,0 +1,79 @@
+package msfragger
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/eng"
+	"github.com/Nesvilab/philosopher/lib/met"
+)
+
+func init() {
+	eng.Register(engine{})
+}
+
+// engine runs MSFragger on the pipeline
+type engine struct{}
+
+// Name of the engine
+func (engine) Name() string {
+	return "MSFragger"
+}
+
+// Version runs the jar without arguments, MSFragger prints its version with the usage
+func (engine) Version(m met.Data) (string, error) {
+
+	jarPath, _ := filepath.Abs(m.MSFragger.JarPath)
+
+	return eng.Version("java", "-jar", jarPath)
+}
+
+// Prepare decodes the parameters into the MSFragger options, the database and decoy tag are the pipeline ones
+func (engine) Prepare(m *met.Data, s eng.Search) error {
+
+	m.MSFragger = met.MSFragger{}
+
+	if e := eng.Decode(s.Params, &m.MSFragger); e != nil {
+		return e
+	}
+
+	m.MSFragger.DatabaseName = s.Database
+	m.MSFragger.DecoyPrefix = s.DecoyTag
+
+	// cleavage rules written on the parameters take precedence over the ones of the registered enzyme
+	SetEnzymes(m, func(option string) bool {
+		v, ok := s.Params[option]
+		return ok && v != nil && len(fmt.Sprint(v)) > 0
+	})
+
+	return nil
+}
+
+// Extension of the spectra files
+func (engine) Extension(m met.Data) string {
+	return m.MSFragger.Extension
+}
+
+// Run searches the spectra files
+func (engine) Run(m met.Data, files []string) met.Data {
+	return Run(m, files)
+}
+
+// Outputs are the pepXML and pin files written next to the spectra files, following the output format
+func (engine) Outputs(m met.Data, files []string) []string {
+
+	var outputs []string
+
+	format := strings.ToLower(m.MSFragger.OutputFormat)
+
+	if len(format) == 0 || strings.Contains(format, "pepxml") {
+		outputs = append(outputs, eng.ReplaceExtension(files, ".pepXML")...)
+	}
+
+	if strings.Contains(format, "pin") {
+		outputs = append(outputs, eng.ReplaceExtension(files, ".pin")...)
+	}
+
+	return outputs
+}
@@ -5,7 +5,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
+	"github.com/Nesvilab/philosopher/lib/bio"
 	"github.com/Nesvilab/philosopher/lib/met"
 	"github.com/Nesvilab/philosopher/lib/msg"
 )
@@ -106,6 +108,57 @@ func (c *MSFragger) ExecutewithParameter(params met.MSFragger, cmdArgs []string)
 
 }
 
+// SetEnzymes fills the cleavage rules of registered enzymes, a combined enzyme name (trypsin+lys_n)
+// or an enzyme with two rules takes both MSFragger enzyme slots. The rules of a slot are kept when
+// any of its cut, nocut or sense options was set explicitly, changed reports those options by name
+func SetEnzymes(m *met.Data, changed func(option string) bool) {
+
+	if len(m.Database.EnzymeFile) > 0 {
+		bio.LoadEnzymes(m.Database.EnzymeFile)
+	}
+
+	params := &m.MSFragger
+
+	enz, ok := bio.GetEnzyme(params.SearchEnzymeName1)
+	if !ok {
+		return
+	}
+
+	if len(enz.Rules) > 2 {
+		msg.Custom(fmt.Errorf("enzyme %s has more cleavage rules than MSFragger supports", enz.Name), "error")
+	}
+
+	explicit := func(slot int) bool {
+		for _, i := range []string{"cut", "nocut", "sense"} {
+			if changed(fmt.Sprintf("search_enzyme_%s_%d", i, slot)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !explicit(1) {
+		params.SearchEnzymeCut1 = enz.Rules[0].Cut
+		params.SearchEnzymeNocut1 = enz.Rules[0].NoCut
+		params.SearchEnzymeSense1 = enz.Rules[0].Sense
+	}
+
+	if len(enz.Rules) == 2 && !explicit(2) {
+
+		// a single enzyme with two rules gets a second name, MSFragger needs distinct enzyme names
+		names := strings.Split(enz.Name, "+")
+		if len(names) == 1 {
+			names = append(names, enz.Name+"_2")
+		}
+
+		params.SearchEnzymeName1 = names[0]
+		params.SearchEnzymeName2 = names[1]
+		params.SearchEnzymeCut2 = enz.Rules[1].Cut
+		params.SearchEnzymeNocut2 = enz.Rules[1].NoCut
+		params.SearchEnzymeSense2 = enz.Rules[1].Sense
+	}
+}
+
 func appendParams(params met.MSFragger) *exec.Cmd {
 
 	mem := fmt.Sprintf("-Xmx%dG", params.Memory)
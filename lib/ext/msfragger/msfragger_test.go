@@ -0,0 +1,70 @@
+package msfragger
+
+import (
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/met"
+)
+
+func TestSetEnzymes(t *testing.T) {
+
+	bio.RegisterEnzyme(bio.Enzyme{Name: "two_rules", Rules: []bio.CleavageRule{{Cut: "W", Sense: "C"}, {Cut: "D", Sense: "N"}}})
+
+	tests := []struct {
+		name    string
+		enzyme  string
+		changed []string
+		want    met.MSFragger
+	}{
+		{
+			name:   "registered enzyme",
+			enzyme: "lys_c",
+			want:   met.MSFragger{SearchEnzymeName1: "lys_c", SearchEnzymeCut1: "K", SearchEnzymeNocut1: "P", SearchEnzymeSense1: "C"},
+		},
+		{
+			name:    "explicit rules are kept",
+			enzyme:  "Trypsin",
+			changed: []string{"search_enzyme_cut_1"},
+			want:    met.MSFragger{SearchEnzymeName1: "Trypsin", SearchEnzymeCut1: "KRX", SearchEnzymeNocut1: "", SearchEnzymeSense1: "C"},
+		},
+		{
+			name:   "combined enzymes",
+			enzyme: "trypsin+lys_n",
+			want: met.MSFragger{SearchEnzymeName1: "trypsin", SearchEnzymeCut1: "KR", SearchEnzymeNocut1: "P", SearchEnzymeSense1: "C",
+				SearchEnzymeName2: "lys_n", SearchEnzymeCut2: "K", SearchEnzymeSense2: "N"},
+		},
+		{
+			name:   "one enzyme with two rules",
+			enzyme: "two_rules",
+			want: met.MSFragger{SearchEnzymeName1: "two_rules", SearchEnzymeCut1: "W", SearchEnzymeSense1: "C",
+				SearchEnzymeName2: "two_rules_2", SearchEnzymeCut2: "D", SearchEnzymeSense2: "N"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var m met.Data
+			m.MSFragger.SearchEnzymeName1 = tt.enzyme
+			m.MSFragger.SearchEnzymeCut1 = "KRX"
+			m.MSFragger.SearchEnzymeSense1 = "C"
+
+			SetEnzymes(&m, func(option string) bool {
+				for _, i := range tt.changed {
+					if i == option {
+						return true
+					}
+				}
+				return false
+			})
+
+			got := m.MSFragger
+			if got.SearchEnzymeName1 != tt.want.SearchEnzymeName1 || got.SearchEnzymeCut1 != tt.want.SearchEnzymeCut1 || got.SearchEnzymeNocut1 != tt.want.SearchEnzymeNocut1 ||
+				got.SearchEnzymeSense1 != tt.want.SearchEnzymeSense1 || got.SearchEnzymeName2 != tt.want.SearchEnzymeName2 || got.SearchEnzymeCut2 != tt.want.SearchEnzymeCut2 ||
+				got.SearchEnzymeNocut2 != tt.want.SearchEnzymeNocut2 || got.SearchEnzymeSense2 != tt.want.SearchEnzymeSense2 {
+				t.Errorf("SetEnzymes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
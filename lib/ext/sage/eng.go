@@ -0,0 +1,119 @@
+package sage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Nesvilab/philosopher/lib/eng"
+	"github.com/Nesvilab/philosopher/lib/met"
+)
+
+func init() {
+	eng.Register(engine{})
+}
+
+// engine runs Sage on the pipeline
+type engine struct{}
+
+// Name of the engine
+func (engine) Name() string {
+	return "Sage"
+}
+
+// Version of the Sage binary
+func (engine) Version(m met.Data) (string, error) {
+	return eng.Version(m.Sage.BinPath, "--version")
+}
+
+// Prepare decodes the parameters into the Sage options, the paths are made absolute because Sage runs
+// inside each data set folder
+func (engine) Prepare(m *met.Data, s eng.Search) error {
+
+	m.Sage = met.Sage{}
+
+	if e := eng.Decode(s.Params, &m.Sage); e != nil {
+		return e
+	}
+
+	m.Sage.DecoyPrefix = s.DecoyTag
+
+	if len(m.Sage.DatabaseName) == 0 {
+		m.Sage.DatabaseName = s.Database
+	}
+
+	if len(m.Sage.DatabaseName) > 0 {
+		m.Sage.DatabaseName, _ = filepath.Abs(m.Sage.DatabaseName)
+	}
+
+	if len(m.Sage.BinPath) > 0 {
+		m.Sage.BinPath, _ = filepath.Abs(m.Sage.BinPath)
+	}
+
+	if len(m.Sage.Extension) == 0 {
+		m.Sage.Extension = "mzML"
+	}
+
+	return nil
+}
+
+// Extension of the spectra files
+func (engine) Extension(m met.Data) string {
+	return m.Sage.Extension
+}
+
+// Run searches the files of each folder apart, Sage writes one results table for all the files it searches
+func (engine) Run(m met.Data, files []string) met.Data {
+
+	dir, _ := os.Getwd()
+
+	for _, i := range folders(files) {
+
+		var group []string
+		for _, j := range files {
+			if file, _ := filepath.Abs(j); filepath.Dir(file) == i {
+				group = append(group, file)
+			}
+		}
+
+		os.Chdir(i)
+		m = Run(m, group)
+		os.Chdir(dir)
+	}
+
+	return m
+}
+
+// Outputs are the results table and the pin file of each folder
+func (engine) Outputs(m met.Data, files []string) []string {
+
+	var outputs []string
+
+	for _, i := range folders(files) {
+		outputs = append(outputs, filepath.Join(i, "results.sage.tsv"), filepath.Join(i, "results.sage.pin"))
+	}
+
+	return outputs
+}
+
+// folders lists the folders of the spectra files
+func folders(files []string) []string {
+
+	var dirs []string
+	var seen = make(map[string]struct{})
+
+	for _, i := range files {
+
+		file, _ := filepath.Abs(i)
+		dir := filepath.Dir(file)
+
+		if _, ok := seen[dir]; !ok {
+			seen[dir] = struct{}{}
+			dirs = append(dirs, dir)
+		}
+	}
+
+	sort.Strings(dirs)
+
+	return dirs
+}
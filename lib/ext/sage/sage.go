@@ -0,0 +1,252 @@
+package sage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/sys"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Sage represents the tool configuration
+type Sage struct {
+	DefaultBin   string
+	DefaultParam string
+}
+
+// config is the layout of the Sage JSON parameter file
+type config struct {
+	Database          database             `json:"database"`
+	PrecursorTol      map[string][]float64 `json:"precursor_tol,omitempty"`
+	FragmentTol       map[string][]float64 `json:"fragment_tol,omitempty"`
+	IsotopeErrors     []int                `json:"isotope_errors,omitempty"`
+	Deisotope         bool                 `json:"deisotope"`
+	Chimera           bool                 `json:"chimera"`
+	WideWindow        bool                 `json:"wide_window"`
+	PredictRT         bool                 `json:"predict_rt"`
+	MinPeaks          int                  `json:"min_peaks,omitempty"`
+	MaxPeaks          int                  `json:"max_peaks,omitempty"`
+	MinMatchedPeaks   int                  `json:"min_matched_peaks,omitempty"`
+	MaxFragmentCharge int                  `json:"max_fragment_charge,omitempty"`
+	ReportPSMs        int                  `json:"report_psms,omitempty"`
+	OutputDirectory   string               `json:"output_directory"`
+	MzMLPaths         []string             `json:"mzml_paths"`
+}
+
+type database struct {
+	Enzyme          enzyme               `json:"enzyme"`
+	MinPeptideMass  float64              `json:"peptide_min_mass,omitempty"`
+	MaxPeptideMass  float64              `json:"peptide_max_mass,omitempty"`
+	StaticMods      map[string]float64   `json:"static_mods,omitempty"`
+	VariableMods    map[string][]float64 `json:"variable_mods,omitempty"`
+	MaxVariableMods int                  `json:"max_variable_mods,omitempty"`
+	DecoyTag        string               `json:"decoy_tag,omitempty"`
+	GenerateDecoys  bool                 `json:"generate_decoys"`
+	Fasta           string               `json:"fasta"`
+}
+
+type enzyme struct {
+	MissedCleavages int     `json:"missed_cleavages,omitempty"`
+	MinLength       int     `json:"min_len,omitempty"`
+	MaxLength       int     `json:"max_len,omitempty"`
+	CleaveAt        *string `json:"cleave_at,omitempty"`
+	Restrict        *string `json:"restrict,omitempty"`
+	CTerminal       *bool   `json:"c_terminal,omitempty"`
+	SemiEnzymatic   bool    `json:"semi_enzymatic"`
+}
+
+// New constructor, the parameter file is written on the folder where Sage writes its results
+func New(dir string) Sage {
+
+	var self Sage
+
+	self.DefaultBin = ""
+	self.DefaultParam = filepath.Join(dir, "sage.json")
+
+	return self
+}
+
+// Run is the Sage main entry point
+func Run(m met.Data, args []string) met.Data {
+
+	var sge = New(".")
+
+	if len(m.Sage.BinPath) == 0 {
+		msg.ExecutingBinary(errors.New("the path to the Sage binary is missing"), "error")
+	}
+
+	if len(args) == 0 {
+		msg.NoSpectraFound(errors.New("there are no spectra files to search with Sage"), "error")
+	}
+
+	sge.DefaultBin, _ = filepath.Abs(m.Sage.BinPath)
+
+	// collect and store the mz files
+	m.Sage.RawFiles = args
+
+	if len(m.Database.EnzymeFile) > 0 {
+		bio.LoadEnzymes(m.Database.EnzymeFile)
+	}
+
+	param, e := Config(m.Sage, args)
+	if e != nil {
+		msg.Custom(e, "error")
+	}
+
+	e = os.WriteFile(sge.DefaultParam, param, sys.FilePermission())
+	if e != nil {
+		msg.WriteFile(e, "error")
+	}
+	m.Sage.ParamFile = param
+
+	sge.Execute()
+
+	return m
+}
+
+// Config writes the Sage JSON parameters, the spectra files and the output folder are part of them
+func Config(params met.Sage, files []string) ([]byte, error) {
+
+	var c config
+
+	if len(params.DatabaseName) == 0 {
+		return nil, errors.New("the protein database for the Sage search is missing")
+	}
+
+	fasta, _ := filepath.Abs(params.DatabaseName)
+
+	c.Database = database{
+		MinPeptideMass:  params.MinPeptideMass,
+		MaxPeptideMass:  params.MaxPeptideMass,
+		StaticMods:      params.StaticMods,
+		VariableMods:    params.VariableMods,
+		MaxVariableMods: params.MaxVariableMods,
+		DecoyTag:        params.DecoyPrefix,
+		GenerateDecoys:  params.GenerateDecoys,
+		Fasta:           fasta,
+	}
+
+	c.Database.Enzyme = enzyme{
+		MissedCleavages: params.MissedCleavages,
+		MinLength:       params.MinLength,
+		MaxLength:       params.MaxLength,
+		SemiEnzymatic:   params.SemiEnzymatic,
+	}
+
+	if e := setEnzyme(&c.Database.Enzyme, params.Enzyme); e != nil {
+		return nil, e
+	}
+
+	var e error
+	if c.PrecursorTol, e = tolerance(params.PrecursorTol, params.PrecursorTolUnits); e != nil {
+		return nil, fmt.Errorf("precursor tolerance: %w", e)
+	}
+
+	if c.FragmentTol, e = tolerance(params.FragmentTol, params.FragmentTolUnits); e != nil {
+		return nil, fmt.Errorf("fragment tolerance: %w", e)
+	}
+
+	c.IsotopeErrors = params.IsotopeErrors
+	c.Deisotope = params.Deisotope
+	c.Chimera = params.Chimera
+	c.WideWindow = params.WideWindow
+	c.PredictRT = !params.NoPredictRT
+	c.MinPeaks = params.MinPeaks
+	c.MaxPeaks = params.MaxPeaks
+	c.MinMatchedPeaks = params.MinMatchedPeaks
+	c.MaxFragmentCharge = params.MaxFragmentCharge
+	c.ReportPSMs = params.ReportPSMs
+	c.OutputDirectory = "."
+
+	for _, i := range files {
+		file, _ := filepath.Abs(i)
+		c.MzMLPaths = append(c.MzMLPaths, file)
+	}
+
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// setEnzyme fills the cleavage rule of a registered enzyme, an empty name keeps the Sage default
+func setEnzyme(enz *enzyme, name string) error {
+
+	if len(name) == 0 {
+		return nil
+	}
+
+	e, ok := bio.GetEnzyme(name)
+	if !ok {
+		return fmt.Errorf("the enzyme %s is not registered", name)
+	}
+
+	if len(e.Rules) > 1 {
+		return fmt.Errorf("enzyme %s has more cleavage rules than Sage supports", e.Name)
+	}
+
+	// Sage restricts the cleavage with a single residue, the field is left out when there is none
+	if len(e.Rules[0].NoCut) > 1 {
+		return fmt.Errorf("enzyme %s does not cut before %s, Sage supports a single restriction residue", e.Name, e.Rules[0].NoCut)
+	}
+
+	cut, nocut := e.Rules[0].Cut, e.Rules[0].NoCut
+	cterm := !strings.EqualFold(e.Rules[0].Sense, "N")
+
+	enz.CleaveAt = &cut
+	if len(nocut) > 0 {
+		enz.Restrict = &nocut
+	}
+	enz.CTerminal = &cterm
+
+	return nil
+}
+
+// tolerance writes a mass window as Sage expects it, as in {"ppm": [-10, 10]}
+func tolerance(window []float64, units string) (map[string][]float64, error) {
+
+	if len(window) == 0 {
+		return nil, nil
+	}
+
+	if len(window) != 2 {
+		return nil, fmt.Errorf("the window needs a lower and an upper value, got %v", window)
+	}
+
+	units = strings.ToLower(units)
+	if len(units) == 0 {
+		units = "ppm"
+	}
+
+	if units != "ppm" && units != "da" {
+		return nil, fmt.Errorf("the units must be ppm or da, got %s", units)
+	}
+
+	return map[string][]float64{units: window}, nil
+}
+
+// Execute runs Sage on the current folder, the PSMs are written as results.sage.tsv and results.sage.pin
+func (c *Sage) Execute() {
+
+	logrus.Info("Running Sage with ", c.DefaultParam)
+
+	cmd := exec.Command(c.DefaultBin, c.DefaultParam, "--write-pin")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	e := cmd.Start()
+	if e != nil {
+		msg.ExecutingBinary(e, "error")
+	}
+
+	e = cmd.Wait()
+	if e != nil {
+		msg.ExecutingBinary(e, "error")
+	}
+}
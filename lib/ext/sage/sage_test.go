@@ -0,0 +1,81 @@
+package sage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/met"
+)
+
+func TestConfig(t *testing.T) {
+
+	bio.RegisterEnzyme(bio.Enzyme{Name: "two_nocut", Rules: []bio.CleavageRule{{Cut: "K", NoCut: "PD", Sense: "C"}}})
+
+	fasta, _ := filepath.Abs("db.fas")
+	spectra, _ := filepath.Abs("run.mzML")
+
+	tests := []struct {
+		name    string
+		params  met.Sage
+		enzyme  map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:   "restricted enzyme",
+			params: met.Sage{DatabaseName: "db.fas", Enzyme: "trypsin", MissedCleavages: 2},
+			enzyme: map[string]interface{}{"missed_cleavages": 2.0, "cleave_at": "KR", "restrict": "P", "c_terminal": true, "semi_enzymatic": false},
+		},
+		{
+			name:   "enzyme without restriction",
+			params: met.Sage{DatabaseName: "db.fas", Enzyme: "lys_n"},
+			enzyme: map[string]interface{}{"cleave_at": "K", "c_terminal": false, "semi_enzymatic": false},
+		},
+		{
+			name:   "default enzyme",
+			params: met.Sage{DatabaseName: "db.fas", SemiEnzymatic: true},
+			enzyme: map[string]interface{}{"semi_enzymatic": true},
+		},
+		{
+			name:    "more than one restriction residue",
+			params:  met.Sage{DatabaseName: "db.fas", Enzyme: "two_nocut"},
+			wantErr: true,
+		},
+		{
+			name:    "missing database",
+			params:  met.Sage{Enzyme: "trypsin"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			b, e := Config(tt.params, []string{"run.mzML"})
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("Config() error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			var got map[string]interface{}
+			if e := json.Unmarshal(b, &got); e != nil {
+				t.Fatal(e)
+			}
+
+			database := got["database"].(map[string]interface{})
+
+			if !reflect.DeepEqual(database["enzyme"], tt.enzyme) {
+				t.Errorf("Config() enzyme = %v, want %v", database["enzyme"], tt.enzyme)
+			}
+
+			if database["fasta"] != fasta || !reflect.DeepEqual(got["mzml_paths"], []interface{}{spectra}) || got["output_directory"] != "." {
+				t.Errorf("Config() paths are incorrect, got %s", b)
+			}
+		})
+	}
+}
@@ -486,6 +486,18 @@ func ProtXMLFilter(p id.ProtXML, targetFDR, pepProb, protProb float64, isPicked,
 
 	sort.Sort(&list)
 
+	var scores = make([]float64, len(list))
+	var isDecoy = make([]bool, len(list))
+	for i := range list {
+		scores[i] = list[i].TopPepProb
+		isDecoy[i] = cla.IsDecoyProtein(list[i], p.DecoyTag)
+	}
+
+	for i, j := range EstimateErrorRates(scores, isDecoy) {
+		list[i].QValue = j.QValue
+		list[i].PEP = j.PEP
+	}
+
 	// from botttom to top, classify every protein block with a given fdr score
 	// the score is only calculates to the first (last) protein in each block
 	// proteins with the same score, get the same fdr value.
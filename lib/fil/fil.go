@@ -63,6 +63,11 @@ func Run(f met.Data) met.Data {
 
 	logrus.Info("Processing peptide identification files")
 
+	// the MaxQuant protein groups take the place of the protXML
+	if len(f.Filter.MaxQuant) > 0 && len(f.Filter.Pox) == 0 {
+		f.Filter.Pox = id.MaxQuantProteinGroups(f.Filter.MaxQuant)
+	}
+
 	// if no method is selected, force the 2D to be default
 	if len(f.Filter.Pox) > 0 && !f.Filter.TwoD && !f.Filter.Seq {
 		f.Filter.TwoD = true
@@ -73,19 +78,39 @@ func Run(f met.Data) met.Data {
 	var protXML id.ProtXML
 	go func() {
 		defer wg.Done()
-		if len(f.Filter.Pox) > 0 {
+		if len(f.Filter.Pox) > 0 && len(f.Filter.MaxQuant) == 0 {
 			protXML = ReadProtXMLInput(f.Filter.Pox, f.Filter.Tag, f.Filter.Weight, f.Filter.MinPepLen)
 		}
 	}()
-	pepid, searchEngine := id.ReadPepXMLInput(f.Filter.Pex, f.Filter.Tag, f.Temp, f.Filter.Model)
+	var pepid id.PepIDListPtrs
+	var searchEngine string
+	if len(f.Filter.Percolator) > 0 {
+		pepid, searchEngine = id.ReadPercolatorInput(f.Filter.Percolator, f.Filter.Tag)
+	} else if len(f.Filter.MzID) > 0 {
+		pepid, searchEngine = id.ReadMzIdentMLInput(f.Filter.MzID, f.Filter.Tag)
+	} else if len(f.Filter.Sage) > 0 {
+		pepid, searchEngine = id.ReadSageInput(f.Filter.Sage, f.Filter.Tag)
+	} else if len(f.Filter.MaxQuant) > 0 {
+		pepid, protXML, searchEngine = id.ReadMaxQuantInput(f.Filter.MaxQuant, f.Filter.Tag, f.Filter.Weight, f.Filter.MinPepLen)
+	} else {
+		pepid, searchEngine = id.ReadPepXMLInput(f.Filter.Pex, f.Filter.Tag, f.Temp, f.Filter.Model)
+	}
 	wg.Wait()
 
 	f.SearchEngine = searchEngine
 
+	if f.Filter.Rescore {
+		Rescore(pepid, f.Filter.Tag)
+		id.UpdatePepXML(pepid)
+	}
+
 	psmT, pepT, ionT := processPeptideIdentifications(pepid, f.Filter.Tag, f.Filter.Mods, f.Filter.PsmFDR, f.Filter.PepFDR, f.Filter.IonFDR, f.Filter.Delta, f.Filter.Group)
 	_ = psmT
 	_ = pepT
 	_ = ionT
+
+	rates := estimateIdentificationErrorRates(pepid, f.Filter.Tag, f.Filter.Rescore)
+
 	if _, err := os.Stat(sys.ProBin()); err == nil {
 
 		pro.Restore()
@@ -198,6 +223,9 @@ func Run(f met.Data) met.Data {
 	e = e.SyncPSMToPeptides(f.Filter.Tag)
 	e = e.SyncPSMToPeptideIons(f.Filter.Tag)
 
+	assignErrorRates(&e, rates)
+	f.Filter.ErrorRates = true
+
 	var countPSM, countPep, countIon, coutProtein int
 	for _, i := range e.PSM {
 		if !i.IsDecoy {
@@ -229,6 +257,11 @@ func Run(f met.Data) met.Data {
 		"ions":     countIon,
 		"proteins": coutProtein,
 	}).Info("Final report numbers after FDR filtering, and post-processing")
+
+	if len(f.Database.EntrapTag) > 0 && f.Database.EntrapmentRatio > 0 {
+		e.EntrapmentReport(f.Database.EntrapTag, f.Database.EntrapmentRatio)
+	}
+
 	logrus.Info("Saving")
 	e.SerializeGranular()
 
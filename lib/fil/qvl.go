@@ -0,0 +1,234 @@
+package fil
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Nesvilab/philosopher/lib/cla"
+	"github.com/Nesvilab/philosopher/lib/id"
+	"github.com/Nesvilab/philosopher/lib/rep"
+)
+
+// ErrorRates holds the target-decoy error estimates of an identification
+type ErrorRates struct {
+	QValue float64
+	PEP    float64
+}
+
+// identificationErrorRates holds the error estimates of the PSMs, ions and peptides, keyed as
+// the report evidences are
+type identificationErrorRates struct {
+	psm     map[string]ErrorRates
+	ion     map[string]ErrorRates
+	peptide map[string]ErrorRates
+}
+
+// EstimateErrorRates calculates the q-values and the posterior error probabilities of a list of
+// identifications, higher scores are better. The q-value is the lowest decoy/target ratio of any
+// threshold that accepts the identification, and the PEP comes from an isotonic regression of
+// the decoy fraction along the scores. Identifications with the same score get the same values
+func EstimateErrorRates(scores []float64, decoys []bool) []ErrorRates {
+
+	rates := make([]ErrorRates, len(scores))
+	if len(scores) == 0 {
+		return rates
+	}
+
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	// every block groups the identifications with the same score
+	type block struct {
+		start, end int
+		fdr        float64
+		fraction   float64
+		weight     float64
+	}
+
+	var blocks []block
+	var targets, decoyCount float64
+
+	for i := 0; i < len(order); {
+
+		b := block{start: i}
+		var blockDecoys float64
+
+		for i < len(order) && scores[order[i]] == scores[order[b.start]] {
+			if decoys[order[i]] {
+				blockDecoys++
+			} else {
+				targets++
+			}
+			i++
+		}
+
+		decoyCount += blockDecoys
+
+		b.end = i
+		b.fdr = 1
+		if targets > 0 {
+			b.fdr = math.Min(decoyCount/targets, 1)
+		}
+		b.weight = float64(b.end - b.start)
+		b.fraction = blockDecoys / b.weight
+
+		blocks = append(blocks, b)
+	}
+
+	// the q-values are made monotonic from the bottom of the list
+	for i := len(blocks) - 2; i >= 0; i-- {
+		if blocks[i+1].fdr < blocks[i].fdr {
+			blocks[i].fdr = blocks[i+1].fdr
+		}
+	}
+
+	fractions := make([]float64, len(blocks))
+	weights := make([]float64, len(blocks))
+	for i := range blocks {
+		fractions[i] = blocks[i].fraction
+		weights[i] = blocks[i].weight
+	}
+
+	fractions = isotonicRegression(fractions, weights)
+
+	for i, b := range blocks {
+
+		// with as many decoys as incorrect targets, the fraction of decoys d gives a
+		// fraction of incorrect targets of d / (1 - d)
+		pep := 1.0
+		if fractions[i] < 0.5 {
+			pep = fractions[i] / (1 - fractions[i])
+		}
+
+		for j := b.start; j < b.end; j++ {
+			rates[order[j]] = ErrorRates{QValue: b.fdr, PEP: pep}
+		}
+	}
+
+	return rates
+}
+
+// isotonicRegression fits a non-decreasing sequence to the values with the pool adjacent
+// violators algorithm
+func isotonicRegression(values, weights []float64) []float64 {
+
+	type pool struct {
+		value  float64
+		weight float64
+		size   int
+	}
+
+	var pools []pool
+
+	for i := range values {
+
+		pools = append(pools, pool{values[i], weights[i], 1})
+
+		for len(pools) > 1 && pools[len(pools)-2].value > pools[len(pools)-1].value {
+
+			last := pools[len(pools)-1]
+			prev := pools[len(pools)-2]
+
+			weight := prev.weight + last.weight
+			pools[len(pools)-2] = pool{(prev.value*prev.weight + last.value*last.weight) / weight, weight, prev.size + last.size}
+			pools = pools[:len(pools)-1]
+		}
+	}
+
+	fitted := make([]float64, 0, len(values))
+	for _, i := range pools {
+		for j := 0; j < i.size; j++ {
+			fitted = append(fitted, i.value)
+		}
+	}
+
+	return fitted
+}
+
+// estimateIdentificationErrorRates calculates the error rates at the PSM, ion and peptide levels
+// using all the identifications before filtering, each level is scored by its best PSM. Rescored
+// PSMs are ranked by the rescoring model score instead of the probability
+func estimateIdentificationErrorRates(p id.PepIDListPtrs, decoyTag string, rescored bool) identificationErrorRates {
+
+	var r identificationErrorRates
+
+	score := func(i *id.PeptideIdentification) float64 {
+		if rescored {
+			return i.Rescore
+		}
+		return i.Probability
+	}
+
+	r.psm = errorRatesByKey(p, decoyTag, score, func(i *id.PeptideIdentification) string {
+		return i.SpectrumFileName().Str()
+	})
+
+	r.ion = errorRatesByKey(p, decoyTag, score, func(i *id.PeptideIdentification) string {
+		mass := math.Round(i.CalcNeutralPepMass*1e4) * 1e-4
+		return id.IonFormType{Peptide: i.Peptide, CalcNeutralPepMass: float32(mass), AssumedCharge: i.AssumedCharge}.Str()
+	})
+
+	r.peptide = errorRatesByKey(p, decoyTag, score, func(i *id.PeptideIdentification) string {
+		return i.Peptide
+	})
+
+	return r
+}
+
+// errorRatesByKey estimates the error rates of the identifications grouped by key
+func errorRatesByKey(p id.PepIDListPtrs, decoyTag string, score func(*id.PeptideIdentification) float64, key func(*id.PeptideIdentification) string) map[string]ErrorRates {
+
+	var best = make(map[string]*id.PeptideIdentification)
+
+	for _, i := range p {
+		k := key(i)
+		if v, ok := best[k]; !ok || score(i) > score(v) {
+			best[k] = i
+		}
+	}
+
+	var keys []string
+	var scores []float64
+	var decoys []bool
+
+	for k, v := range best {
+		keys = append(keys, k)
+		scores = append(scores, score(v))
+		decoys = append(decoys, cla.IsDecoyPSM(*v, decoyTag))
+	}
+
+	rates := EstimateErrorRates(scores, decoys)
+
+	var m = make(map[string]ErrorRates, len(keys))
+	for i := range keys {
+		m[keys[i]] = rates[i]
+	}
+
+	return m
+}
+
+// assignErrorRates copies the error rates to the report evidences
+func assignErrorRates(e *rep.Evidence, r identificationErrorRates) {
+
+	for i := range e.PSM {
+		v := r.psm[e.PSM[i].SpectrumFileName().Str()]
+		e.PSM[i].QValue = v.QValue
+		e.PSM[i].PEP = v.PEP
+	}
+
+	for i := range e.Ions {
+		v := r.ion[e.Ions[i].IonForm().Str()]
+		e.Ions[i].QValue = v.QValue
+		e.Ions[i].PEP = v.PEP
+	}
+
+	for i := range e.Peptides {
+		v := r.peptide[e.Peptides[i].Sequence]
+		e.Peptides[i].QValue = v.QValue
+		e.Peptides[i].PEP = v.PEP
+	}
+}
@@ -0,0 +1,55 @@
+package fil
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateErrorRates(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		scores []float64
+		decoys []bool
+		want   []ErrorRates
+	}{
+		{
+			name:   "monotonic q-values and PEP",
+			scores: []float64{0.5, 0.9, 0.7, 0.8, 0.6},
+			decoys: []bool{true, false, true, false, false},
+			want:   []ErrorRates{{2.0 / 3, 1}, {0, 0}, {1.0 / 3, 1}, {0, 0}, {1.0 / 3, 1}},
+		},
+		{
+			name:   "ties share the values",
+			scores: []float64{0.9, 0.5, 0.9},
+			decoys: []bool{true, false, false},
+			want:   []ErrorRates{{0.5, 0.5}, {0.5, 0.5}, {0.5, 0.5}},
+		},
+		{
+			name:   "decoys only",
+			scores: []float64{0.9, 0.8},
+			decoys: []bool{true, true},
+			want:   []ErrorRates{{1, 1}, {1, 1}},
+		},
+		{
+			name: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got := EstimateErrorRates(tt.scores, tt.decoys)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("EstimateErrorRates() got %d values, want %d", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if math.Abs(got[i].QValue-tt.want[i].QValue) > 1e-9 || math.Abs(got[i].PEP-tt.want[i].PEP) > 1e-9 {
+					t.Errorf("EstimateErrorRates()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
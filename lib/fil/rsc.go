@@ -0,0 +1,446 @@
+package fil
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/Nesvilab/philosopher/lib/cla"
+	"github.com/Nesvilab/philosopher/lib/id"
+	"github.com/Nesvilab/philosopher/lib/msg"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// rescoreFolds is the number of cross-validation folds, each fold is scored by a model trained on the others
+	rescoreFolds = 3
+	// rescoreIterations is the number of times the positive training set is selected again
+	rescoreIterations = 5
+	// rescoreTrainFDR is the q-value of the targets used as positive examples
+	rescoreTrainFDR = 0.01
+	// rescoreRidge is the L2 penalty of the model weights
+	rescoreRidge = 1.0
+	// rescoreSeed keeps the folds the same between runs
+	rescoreSeed = 1
+)
+
+// rescoreFeatures names the PSM features of the rescoring model
+var rescoreFeatures = []string{
+	"Hyperscore",
+	"Nextscore",
+	"Expectation",
+	"DeltaCN",
+	"Xcorr",
+	"Massdiff",
+	"Charge 1",
+	"Charge 2",
+	"Charge 3",
+	"Charge 4+",
+	"Missed Cleavages",
+	"Peptide Length",
+	"Retention Time",
+}
+
+// Rescore trains a linear model that separates the targets from the decoys with semi-supervised, cross-validated
+// iterations, as Percolator does. The PSMs receive the model score, and a probability of 1 - PEP that takes the
+// place of the search engine or PeptideProphet probability on the filter
+func Rescore(p id.PepIDListPtrs, decoyTag string) {
+
+	var decoys = make([]bool, len(p))
+	var targets, decoyCount int
+
+	for i := range p {
+		decoys[i] = cla.IsDecoyPSM(*p[i], decoyTag)
+		if decoys[i] {
+			decoyCount++
+		} else {
+			targets++
+		}
+	}
+
+	if decoyCount == 0 || targets == 0 {
+		msg.Custom(errors.New("the rescoring needs both target and decoy PSMs, the PSMs keep their probabilities"), "warning")
+		return
+	}
+
+	logrus.Info("Rescoring PSMs")
+
+	x := rescoreFeatureMatrix(p)
+
+	var before = make([]float64, len(p))
+	for i := range p {
+		before[i] = p[i].Probability
+	}
+
+	folds := rescoreFoldAssignment(p)
+	scores := make([]float64, len(p))
+
+	for fold := 0; fold < rescoreFolds; fold++ {
+
+		var train, test []int
+		for i := range p {
+			if folds[i] == fold {
+				test = append(test, i)
+			} else {
+				train = append(train, i)
+			}
+		}
+
+		if len(test) == 0 {
+			continue
+		}
+
+		w := trainRescoreModel(x, decoys, train)
+
+		testScores := make([]float64, len(test))
+		testDecoys := make([]bool, len(test))
+		for i, j := range test {
+			testScores[i] = dot(w, x[j])
+			testDecoys[i] = decoys[j]
+		}
+
+		// the folds are scored by different models, the scores are normalized before they are merged
+		normalizeRescoreScores(testScores, testDecoys)
+
+		for i, j := range test {
+			scores[j] = testScores[i]
+		}
+	}
+
+	rates := EstimateErrorRates(scores, decoys)
+
+	for i := range p {
+		p[i].Rescore = scores[i]
+		p[i].Probability = 1 - rates[i].PEP
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"before": countAtFDR(before, decoys, rescoreTrainFDR),
+		"after":  countAtFDR(scores, decoys, rescoreTrainFDR),
+	}).Info("Target PSMs at 1% FDR")
+}
+
+// rescoreFeatureMatrix builds the standardized features of every PSM, the last column is the model intercept
+func rescoreFeatureMatrix(p id.PepIDListPtrs) [][]float64 {
+
+	n := len(rescoreFeatures)
+	x := make([][]float64, len(p))
+
+	for i, j := range p {
+
+		row := make([]float64, n+1)
+
+		row[0] = j.Hyperscore
+		row[1] = j.Nextscore
+		row[2] = -math.Log10(math.Max(j.Expectation, 1e-300))
+		row[3] = j.DeltaCN
+		row[4] = j.Xcorr
+		row[5] = math.Abs(j.Massdiff)
+
+		switch {
+		case j.AssumedCharge <= 1:
+			row[6] = 1
+		case j.AssumedCharge == 2:
+			row[7] = 1
+		case j.AssumedCharge == 3:
+			row[8] = 1
+		default:
+			row[9] = 1
+		}
+
+		row[10] = float64(j.NumberofMissedCleavages)
+		row[11] = float64(j.PeptideLength)
+		row[12] = j.RetentionTime
+		row[n] = 1
+
+		x[i] = row
+	}
+
+	// features without variance, as the XCorr of MSFragger searches, are left out of the model
+	for f := 0; f < n; f++ {
+
+		var mean, sd float64
+		for i := range x {
+			mean += x[i][f]
+		}
+		mean /= float64(len(x))
+
+		for i := range x {
+			sd += (x[i][f] - mean) * (x[i][f] - mean)
+		}
+		sd = math.Sqrt(sd / float64(len(x)))
+
+		for i := range x {
+			if sd > 0 {
+				x[i][f] = (x[i][f] - mean) / sd
+			} else {
+				x[i][f] = 0
+			}
+		}
+	}
+
+	return x
+}
+
+// rescoreFoldAssignment splits the PSMs into folds, the PSMs of the same spectrum stay together
+func rescoreFoldAssignment(p id.PepIDListPtrs) []int {
+
+	var r = rand.New(rand.NewSource(rescoreSeed))
+	var spectra = make(map[string]int)
+	var folds = make([]int, len(p))
+
+	for i := range p {
+		key := p[i].SpectrumFileName().Str()
+		fold, ok := spectra[key]
+		if !ok {
+			fold = r.Intn(rescoreFolds)
+			spectra[key] = fold
+		}
+		folds[i] = fold
+	}
+
+	return folds
+}
+
+// trainRescoreModel starts from the single best feature and retrains the model on the confident targets and
+// all decoys of the training PSMs
+func trainRescoreModel(x [][]float64, decoys []bool, train []int) []float64 {
+
+	n := len(rescoreFeatures)
+	trainDecoys := make([]bool, len(train))
+	for i, j := range train {
+		trainDecoys[i] = decoys[j]
+	}
+
+	w := make([]float64, n+1)
+	var best int = -1
+
+	for f := 0; f < n; f++ {
+		for _, sign := range []float64{1, -1} {
+
+			scores := make([]float64, len(train))
+			for i, j := range train {
+				scores[i] = sign * x[j][f]
+			}
+
+			if c := countAtFDR(scores, trainDecoys, rescoreTrainFDR); c > best {
+				best = c
+				for k := range w {
+					w[k] = 0
+				}
+				w[f] = sign
+			}
+		}
+	}
+
+	for it := 0; it < rescoreIterations; it++ {
+
+		scores := make([]float64, len(train))
+		for i, j := range train {
+			scores[i] = dot(w, x[j])
+		}
+
+		rates := EstimateErrorRates(scores, trainDecoys)
+
+		var rows [][]float64
+		var labels []bool
+		var positives int
+
+		for i, j := range train {
+			if trainDecoys[i] {
+				rows = append(rows, x[j])
+				labels = append(labels, false)
+			} else if rates[i].QValue <= rescoreTrainFDR {
+				rows = append(rows, x[j])
+				labels = append(labels, true)
+				positives++
+			}
+		}
+
+		if positives == 0 {
+			break
+		}
+
+		w = fitLogistic(rows, labels, w)
+	}
+
+	return w
+}
+
+// fitLogistic fits a ridge regularized logistic regression with Newton iterations, the intercept is the last
+// weight and it is not penalized
+func fitLogistic(x [][]float64, y []bool, start []float64) []float64 {
+
+	n := len(start)
+	w := make([]float64, n)
+	copy(w, start)
+
+	for step := 0; step < 25; step++ {
+
+		g := make([]float64, n)
+		h := make([][]float64, n)
+		for i := range h {
+			h[i] = make([]float64, n)
+		}
+
+		for i := range x {
+
+			prob := 1 / (1 + math.Exp(-dot(w, x[i])))
+			r := prob * (1 - prob)
+
+			label := 0.0
+			if y[i] {
+				label = 1
+			}
+
+			for j := 0; j < n; j++ {
+				g[j] += (prob - label) * x[i][j]
+				for k := j; k < n; k++ {
+					h[j][k] += r * x[i][j] * x[i][k]
+				}
+			}
+		}
+
+		for j := 0; j < n; j++ {
+			for k := 0; k < j; k++ {
+				h[j][k] = h[k][j]
+			}
+			if j < n-1 {
+				g[j] += rescoreRidge * w[j]
+				h[j][j] += rescoreRidge
+			}
+		}
+
+		delta, ok := solve(h, g)
+		if !ok {
+			break
+		}
+
+		var change float64
+		for j := range w {
+			w[j] -= delta[j]
+			change = math.Max(change, math.Abs(delta[j]))
+		}
+
+		if change < 1e-6 {
+			break
+		}
+	}
+
+	return w
+}
+
+// normalizeRescoreScores moves the scores so the 1% FDR threshold is zero and the median decoy is minus one.
+// A fold without targets at 1% FDR takes its best decoy as the threshold, and a fold without decoys takes the
+// median of all its scores, so no fold is merged with raw scores
+func normalizeRescoreScores(scores []float64, decoys []bool) {
+
+	if len(scores) == 0 {
+		return
+	}
+
+	rates := EstimateErrorRates(scores, decoys)
+
+	var threshold = math.Inf(1)
+	var decoyScores []float64
+
+	for i := range scores {
+		if decoys[i] {
+			decoyScores = append(decoyScores, scores[i])
+		} else if rates[i].QValue <= rescoreTrainFDR && scores[i] < threshold {
+			threshold = scores[i]
+		}
+	}
+
+	if len(decoyScores) == 0 {
+		decoyScores = append(decoyScores, scores...)
+	}
+
+	sort.Float64s(decoyScores)
+	median := decoyScores[len(decoyScores)/2]
+
+	if math.IsInf(threshold, 1) {
+		threshold = decoyScores[len(decoyScores)-1]
+	}
+
+	scale := threshold - median
+	if scale <= 0 {
+		scale = 1
+	}
+
+	for i := range scores {
+		scores[i] = (scores[i] - threshold) / scale
+	}
+}
+
+// countAtFDR counts the targets with a q-value at or below the FDR
+func countAtFDR(scores []float64, decoys []bool, fdr float64) int {
+
+	var count int
+
+	for i, j := range EstimateErrorRates(scores, decoys) {
+		if !decoys[i] && j.QValue <= fdr {
+			count++
+		}
+	}
+
+	return count
+}
+
+// solve returns the solution of the linear system a x = b by Gaussian elimination with partial pivoting
+func solve(a [][]float64, b []float64) ([]float64, bool) {
+
+	n := len(b)
+	m := make([][]float64, n)
+	for i := range a {
+		m[i] = append(append([]float64{}, a[i]...), b[i])
+	}
+
+	for c := 0; c < n; c++ {
+
+		pivot := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(m[r][c]) > math.Abs(m[pivot][c]) {
+				pivot = r
+			}
+		}
+
+		if math.Abs(m[pivot][c]) < 1e-12 {
+			return nil, false
+		}
+
+		m[c], m[pivot] = m[pivot], m[c]
+
+		for r := c + 1; r < n; r++ {
+			f := m[r][c] / m[c][c]
+			for k := c; k <= n; k++ {
+				m[r][k] -= f * m[c][k]
+			}
+		}
+	}
+
+	x := make([]float64, n)
+	for r := n - 1; r >= 0; r-- {
+		s := m[r][n]
+		for k := r + 1; k < n; k++ {
+			s -= m[r][k] * x[k]
+		}
+		x[r] = s / m[r][r]
+	}
+
+	return x, true
+}
+
+// dot is the inner product of two vectors of the same length
+func dot(a, b []float64) float64 {
+
+	var s float64
+
+	for i := range a {
+		s += a[i] * b[i]
+	}
+
+	return s
+}
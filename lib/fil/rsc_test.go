@@ -0,0 +1,177 @@
+package fil
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/id"
+)
+
+// rescorePSMs simulates a search where the correct targets have better scores and mass errors, the
+// hyperscore alone mixes them with the incorrect targets and the decoys
+func rescorePSMs() (id.PepIDListPtrs, []bool) {
+
+	r := rand.New(rand.NewSource(7))
+
+	var p id.PepIDListPtrs
+	var decoys []bool
+
+	for i := 0; i < 3000; i++ {
+
+		psm := &id.PeptideIdentification{
+			Spectrum:      fmt.Sprintf("run.%05d.%05d.2", i, i),
+			SpectrumFile:  "interact.pep.xml",
+			Protein:       "sp|P1|A",
+			AssumedCharge: uint8(2 + i%2),
+			PeptideLength: uint8(7 + i%10),
+			Expectation:   1,
+		}
+
+		switch {
+		case i%3 == 0:
+			psm.Protein = "rev_" + psm.Protein
+			psm.Hyperscore = 20 + 6*r.NormFloat64()
+			psm.Massdiff = 0.05 * (2*r.Float64() - 1)
+		case i%3 == 1 && i%2 == 0:
+			psm.Hyperscore = 20 + 6*r.NormFloat64()
+			psm.Massdiff = 0.05 * (2*r.Float64() - 1)
+		default:
+			psm.Hyperscore = 26 + 6*r.NormFloat64()
+			psm.Massdiff = 0.002 * r.NormFloat64()
+		}
+
+		psm.Nextscore = psm.Hyperscore - 5*r.Float64()
+		psm.Probability = psm.Hyperscore / 100
+
+		p = append(p, psm)
+		decoys = append(decoys, i%3 == 0)
+	}
+
+	return p, decoys
+}
+
+func TestRescore(t *testing.T) {
+
+	p, decoys := rescorePSMs()
+
+	var hyperscores []float64
+	for _, i := range p {
+		hyperscores = append(hyperscores, i.Hyperscore)
+	}
+
+	before := countAtFDR(hyperscores, decoys, 0.01)
+
+	Rescore(p, "rev_")
+
+	var scores []float64
+	for _, i := range p {
+		if i.Probability < 0 || i.Probability > 1 {
+			t.Fatalf("the probability of %s is out of range, got %f", i.Spectrum, i.Probability)
+		}
+		scores = append(scores, i.Rescore)
+	}
+
+	after := countAtFDR(scores, decoys, 0.01)
+
+	if after <= before {
+		t.Errorf("the rescoring did not improve the identifications, got %d, had %d", after, before)
+	}
+}
+
+func TestRescoreTwoD(t *testing.T) {
+
+	p, _ := rescorePSMs()
+
+	// the input is kept as it was read, before the PSMs are rescored
+	pepXML := id.PepXML4Serialiazation{DecoyTag: "rev_", PeptideIdentification: p}
+	pepXML.Serialize()
+
+	Rescore(p, "rev_")
+	id.UpdatePepXML(p)
+
+	var rescored = make(map[string]*id.PeptideIdentification)
+	for _, i := range p {
+		rescored[i.Spectrum] = i
+	}
+
+	var pepxml id.PepXML
+	pepxml.Restore()
+
+	twoDFDRFilter(pepxml.PeptideIdentification, id.ProtIDList{{ProteinName: "sp|P1|A"}}, 0.01, 0.01, 0.01, "rev_")
+
+	var psm id.PepIDList
+	psm.Restore("psm")
+
+	if len(psm) == 0 {
+		t.Fatal("the 2D filter did not report any PSM")
+	}
+
+	for _, i := range psm {
+		want := rescored[i.Spectrum]
+		if i.Probability == i.Hyperscore/100 || i.Rescore != want.Rescore || i.Probability != want.Probability {
+			t.Fatalf("the PSM %s was filtered without its rescoring, got %f (%f), want %f (%f)", i.Spectrum, i.Probability, i.Rescore, want.Probability, want.Rescore)
+		}
+	}
+}
+
+func TestNormalizeRescoreScores(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		scores []float64
+		decoys []bool
+		want   []float64
+	}{
+		{"targets at 1% FDR", []float64{10, 9, 3, 2, 1}, []bool{false, false, true, true, true}, []float64{1.0 / 7, 0, -6.0 / 7, -1, -8.0 / 7}},
+		{"no target at 1% FDR", []float64{5, 4, 3, 2, 1}, []bool{true, false, true, false, true}, []float64{0, -0.5, -1, -1.5, -2}},
+		{"no decoys", []float64{3, 2, 1}, []bool{false, false, false}, []float64{2, 1, 0}},
+		{"empty fold", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got := append([]float64{}, tt.scores...)
+			normalizeRescoreScores(got, tt.decoys)
+
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Fatalf("normalizeRescoreScores() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSolve(t *testing.T) {
+
+	tests := []struct {
+		name string
+		a    [][]float64
+		b    []float64
+		want []float64
+		ok   bool
+	}{
+		{"identity", [][]float64{{1, 0}, {0, 1}}, []float64{2, 3}, []float64{2, 3}, true},
+		{"pivoting", [][]float64{{0, 2}, {1, 1}}, []float64{4, 3}, []float64{1, 2}, true},
+		{"singular", [][]float64{{1, 2}, {2, 4}}, []float64{1, 2}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, ok := solve(tt.a, tt.b)
+			if ok != tt.ok {
+				t.Fatalf("solve() ok = %v, want %v", ok, tt.ok)
+			}
+
+			for i := range tt.want {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("solve() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -473,6 +473,12 @@ type Scan struct {
 	//PrecursorMzs []float64
 	Fragment []Fragment
 	Time     float64
+	// Reaction lists the precursor selections leading to this scan, the last one is the closest
+	Reaction []Reaction
+	// Trailer holds the trailer extra values, like the ion injection time and the master scan number
+	Trailer Trailer
+	// FilterString is the scan filter rebuilt from the scan event
+	FilterString string
 }
 
 // Analyzer is the mass analyzer
@@ -504,6 +510,9 @@ type RawData struct {
 	Detector        []string
 	Scanevents      ScanEvents
 	Scanindex       ScanIndex
+	trailerHeader   *GenericDataHeader
+	trailerAddr     uint64
+	trailerSize     int
 }
 
 // ProcessRaw calls other low level functions and fill out RawData struct
@@ -557,6 +566,9 @@ func (rd *RawData) ProcessRaw(f string) {
 	rd.Scanevents = scanevents
 	rd.Scanindex = scanindex
 
+	// the trailer extra is optional, scans have no trailer values when the header is not found
+	rd.locateTrailer(rh)
+
 }
 
 // ScanEventData ...
@@ -720,6 +732,10 @@ func (rd *RawData) Scan(sn int) (scan Scan) {
 		scan.Fragment = append(scan.Fragment, frag)
 	}
 
+	scan.Reaction = rd.Scanevents[sn-1].Reaction
+	scan.Trailer = rd.Trailer(sn)
+	scan.FilterString = rd.FilterString(sn)
+
 	//scan.Fragment = frag
 
 	// scan.PrecursorMzs = make([]float64, len(rd.Scanevents[sn-1].Reaction))
@@ -0,0 +1,347 @@
+package fin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxGenericFields and maxLogEntries bound the counts read from the file, larger values mean the
+// stream does not have the expected layout
+const (
+	maxGenericFields = 1000
+	maxLogEntries    = 1 << 20
+)
+
+// analyzerNames, ionizationNames and scanTypeNames decode the scan event preamble for the filter strings
+var analyzerNames = map[uint8]string{0: "ITMS", 1: "TQMS", 2: "SQMS", 3: "TOFMS", 4: "FTMS", 5: "Sector"}
+var ionizationNames = map[uint8]string{0: "EI", 1: "CI", 2: "FAB", 3: "ESI", 4: "APCI", 5: "NSI", 6: "TSP", 7: "FD", 8: "MALDI", 9: "GD"}
+var scanTypeNames = map[uint8]string{0: "Full", 1: "Z", 2: "SIM", 3: "SRM", 4: "CRM", 6: "Q1MS", 7: "Q3MS"}
+
+// GenericDataDescriptor describes one field of a generic record, like the trailer extra
+type GenericDataDescriptor struct {
+	Type   uint32
+	Length uint32
+	Label  PascalString
+}
+
+// GenericDataHeader lists the fields of the generic records
+type GenericDataHeader struct {
+	N           uint32
+	Descriptors []GenericDataDescriptor
+}
+
+// Trailer holds the trailer extra values of a scan, indexed by label without the trailing colon
+type Trailer map[string]string
+
+// ErrorLogEntry is a message of the instrument error log
+type ErrorLogEntry struct {
+	Time    float32
+	Message PascalString
+}
+
+// ErrorLog lists the instrument errors, it starts at the error log address of the run header
+type ErrorLog []ErrorLogEntry
+
+// ScanEventTemplate is the scan event of a segment of the acquisition method
+type ScanEventTemplate struct {
+	Preamble          [132]uint8
+	ControllerType    uint32
+	ControllerNumber  uint32
+	FractionCollector FractionCollector
+	Unknown           [3]uint32
+}
+
+// ScanEventHierarchy lists the scan event templates of every segment
+type ScanEventHierarchy [][]ScanEventTemplate
+
+// trailerStream holds the structures written after the run header, from the error log up to
+// the header of the trailer extra records
+type trailerStream struct {
+	ErrorLog  ErrorLog
+	Hierarchy ScanEventHierarchy
+	Header    GenericDataHeader
+}
+
+func (data *ErrorLog) Read(r io.Reader, v Version) {
+	var n uint32
+	binaryread(r, &n)
+	if n > maxLogEntries {
+		return
+	}
+	*data = make(ErrorLog, n)
+	for i := range *data {
+		binaryread(r, &(*data)[i].Time)
+		binaryread(r, &(*data)[i].Message)
+	}
+}
+
+func (data *ScanEventTemplate) Read(r io.Reader, v Version) {
+	switch {
+	case v < 57:
+		binaryread(r, data.Preamble[:41])
+	case v >= 57 && v < 62:
+		binaryread(r, data.Preamble[:80])
+	case v >= 62 && v < 63:
+		binaryread(r, data.Preamble[:120])
+	case v >= 63 && v < 66:
+		binaryread(r, data.Preamble[:128])
+	default:
+		binaryread(r, &data.Preamble)
+	}
+	binaryread(r, &data.ControllerType)
+	binaryread(r, &data.ControllerNumber)
+	binaryread(r, &data.FractionCollector)
+	binaryread(r, &data.Unknown)
+}
+
+func (data *ScanEventHierarchy) Read(r io.Reader, v Version) {
+	var nsegs uint32
+	binaryread(r, &nsegs)
+	if nsegs > maxLogEntries {
+		return
+	}
+	*data = make(ScanEventHierarchy, nsegs)
+	for i := range *data {
+		var n uint32
+		binaryread(r, &n)
+		if n > maxLogEntries {
+			return
+		}
+		(*data)[i] = make([]ScanEventTemplate, n)
+		for j := range (*data)[i] {
+			(*data)[i][j].Read(r, v)
+		}
+	}
+}
+
+func (data *GenericDataHeader) Read(r io.Reader, v Version) {
+	binaryread(r, &data.N)
+	if data.N > maxGenericFields {
+		return
+	}
+	data.Descriptors = make([]GenericDataDescriptor, data.N)
+	for i := range data.Descriptors {
+		binaryread(r, &data.Descriptors[i].Type)
+		binaryread(r, &data.Descriptors[i].Length)
+		binaryread(r, &data.Descriptors[i].Label)
+	}
+}
+
+func (data *trailerStream) Read(r io.Reader, v Version) {
+	data.ErrorLog.Read(r, v)
+	data.Hierarchy.Read(r, v)
+	data.Header.Read(r, v)
+}
+
+// valid reports if every field of the header has a known type and the header has a trailer label
+func (data GenericDataHeader) valid() bool {
+
+	if data.N == 0 || int(data.N) != len(data.Descriptors) {
+		return false
+	}
+
+	var labeled bool
+	for _, i := range data.Descriptors {
+		if i.size() < 0 {
+			return false
+		}
+		if strings.TrimSpace(i.Label.String()) == "Charge State:" || strings.TrimSpace(i.Label.String()) == "Ion Injection Time (ms):" {
+			labeled = true
+		}
+	}
+
+	return labeled
+}
+
+// size is the number of bytes the field takes on a record, or -1 for unknown types
+func (d GenericDataDescriptor) size() int {
+	switch d.Type {
+	case 0:
+		return 0
+	case 1, 2, 3, 4, 5:
+		return 1
+	case 6, 7:
+		return 2
+	case 8, 9, 10:
+		return 4
+	case 11:
+		return 8
+	case 12:
+		return int(d.Length)
+	case 13:
+		return 2 * int(d.Length)
+	}
+	return -1
+}
+
+// recordSize is the number of bytes of each generic record
+func (data GenericDataHeader) recordSize() int {
+	var n int
+	for _, i := range data.Descriptors {
+		n += i.size()
+	}
+	return n
+}
+
+// Decode reads the values of a generic record
+func (data GenericDataHeader) Decode(record []byte) Trailer {
+
+	var t = make(Trailer)
+	var pos int
+
+	for _, d := range data.Descriptors {
+
+		size := d.size()
+		if size < 0 || pos+size > len(record) {
+			break
+		}
+		b := record[pos : pos+size]
+		pos += size
+
+		label := strings.TrimSuffix(strings.TrimSpace(d.Label.String()), ":")
+		if len(label) == 0 || size == 0 {
+			continue
+		}
+
+		var value string
+		switch d.Type {
+		case 1:
+			value = strconv.Itoa(int(int8(b[0])))
+		case 2, 3, 4, 5:
+			value = strconv.Itoa(int(b[0]))
+		case 6:
+			value = strconv.Itoa(int(int16(binary.LittleEndian.Uint16(b))))
+		case 7:
+			value = strconv.Itoa(int(binary.LittleEndian.Uint16(b)))
+		case 8:
+			value = strconv.Itoa(int(int32(binary.LittleEndian.Uint32(b))))
+		case 9:
+			value = strconv.FormatUint(uint64(binary.LittleEndian.Uint32(b)), 10)
+		case 10:
+			value = strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))), 'f', -1, 32)
+		case 11:
+			value = strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)), 'f', -1, 64)
+		case 12:
+			value = string(bytes.TrimRight(b, "\x00"))
+		case 13:
+			u := make([]uint16, len(b)/2)
+			for i := range u {
+				u[i] = binary.LittleEndian.Uint16(b[2*i:])
+			}
+			value = strings.TrimRight(string(utf16.Decode(u)), "\x00")
+		}
+
+		t[label] = strings.TrimSpace(value)
+	}
+
+	return t
+}
+
+// Float returns a numeric trailer value, or zero when it is missing
+func (t Trailer) Float(label string) float64 {
+	v, _ := strconv.ParseFloat(t[label], 64)
+	return v
+}
+
+// locateTrailer reads the trailer extra header, it follows the error log and the scan event hierarchy
+// on the run header stream. The trailer extra records are written at the scan parameters address,
+// one per scan. Malformed streams leave the scans without trailer values
+func (rd *RawData) locateTrailer(rh RunHeader) {
+
+	if rh.ErrorlogAddr == 0 || rh.ScanparamsAddr == 0 {
+		return
+	}
+
+	defer func() {
+		if recover() != nil {
+			rd.trailerHeader = nil
+		}
+	}()
+
+	var ts trailerStream
+	readAt(rd.File, rh.ErrorlogAddr, rd.Version, &ts)
+
+	if !ts.Header.valid() {
+		return
+	}
+
+	rd.trailerHeader = &ts.Header
+	rd.trailerAddr = rh.ScanparamsAddr
+	rd.trailerSize = ts.Header.recordSize()
+}
+
+// Trailer returns the trailer extra values of the scan number in argument
+func (rd *RawData) Trailer(sn int) Trailer {
+
+	if rd.trailerHeader == nil || rd.trailerSize <= 0 || sn < 1 || sn > rd.NScans() {
+		return Trailer{}
+	}
+
+	record := make([]byte, rd.trailerSize)
+	if _, e := rd.File.ReadAt(record, int64(rd.trailerAddr)+int64(sn-1)*int64(rd.trailerSize)); e != nil {
+		return Trailer{}
+	}
+
+	return rd.trailerHeader.Decode(record)
+}
+
+// FilterString rebuilds the Xcalibur scan filter from the scan event, the activation types
+// are not decoded and only the precursor and energy are shown
+func (rd *RawData) FilterString(sn int) string {
+
+	if sn < 1 || sn > rd.NScans() {
+		return ""
+	}
+
+	ev := rd.Scanevents[sn-1]
+	idx := rd.Scanindex[sn-1]
+
+	var parts []string
+
+	if v, ok := analyzerNames[ev.Preamble[40]]; ok {
+		parts = append(parts, v)
+	}
+
+	if ev.Preamble[4] == 0 {
+		parts = append(parts, "-")
+	} else {
+		parts = append(parts, "+")
+	}
+
+	if ev.Preamble[5] == 0 {
+		parts = append(parts, "c")
+	} else {
+		parts = append(parts, "p")
+	}
+
+	if v, ok := ionizationNames[ev.Preamble[11]]; ok {
+		parts = append(parts, v)
+	}
+
+	if ev.Preamble[10] == 1 {
+		parts = append(parts, "d")
+	}
+
+	if v, ok := scanTypeNames[ev.Preamble[7]]; ok {
+		parts = append(parts, v)
+	}
+
+	if ev.Preamble[6] > 1 {
+		parts = append(parts, fmt.Sprintf("ms%d", ev.Preamble[6]))
+		for _, r := range ev.Reaction {
+			parts = append(parts, fmt.Sprintf("%.4f@%.2f", r.Precursormz, r.Energy))
+		}
+	} else {
+		parts = append(parts, "ms")
+	}
+
+	parts = append(parts, fmt.Sprintf("[%.4f-%.4f]", idx.Lowmz, idx.Highmz))
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,200 @@
+package fin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+)
+
+// pascal encodes a string as a PascalString of the RAW file
+func pascal(s string) PascalString {
+	t := utf16.Encode([]rune(s))
+	return PascalString{Length: int32(len(t)), Text: t}
+}
+
+// writeHeader encodes a generic data header as it is written on the RAW file
+func writeHeader(b *bytes.Buffer, h GenericDataHeader) {
+	binary.Write(b, binary.LittleEndian, h.N)
+	for _, i := range h.Descriptors {
+		binary.Write(b, binary.LittleEndian, i.Type)
+		binary.Write(b, binary.LittleEndian, i.Length)
+		binary.Write(b, binary.LittleEndian, i.Label.Length)
+		binary.Write(b, binary.LittleEndian, i.Label.Text)
+	}
+}
+
+func trailerHeader() GenericDataHeader {
+	return GenericDataHeader{N: 5, Descriptors: []GenericDataDescriptor{
+		{Type: 0, Label: pascal("Status:")},
+		{Type: 10, Label: pascal("Ion Injection Time (ms):")},
+		{Type: 6, Label: pascal("Charge State:")},
+		{Type: 11, Label: pascal("Monoisotopic M/Z:")},
+		{Type: 12, Length: 6, Label: pascal("Scan Description:")},
+	}}
+}
+
+func trailerRecord(injection float32, charge int16, mz float64, desc string) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, injection)
+	binary.Write(&b, binary.LittleEndian, charge)
+	binary.Write(&b, binary.LittleEndian, mz)
+	text := make([]byte, 6)
+	copy(text, desc)
+	b.Write(text)
+	return b.Bytes()
+}
+
+func TestGenericDataHeader_Decode(t *testing.T) {
+
+	h := trailerHeader()
+
+	if h.recordSize() != 20 {
+		t.Fatalf("recordSize() = %d, want 20", h.recordSize())
+	}
+
+	tests := []struct {
+		name   string
+		record []byte
+		want   Trailer
+	}{
+		{"full record", trailerRecord(22.5, 2, 400.25, "top"), Trailer{"Ion Injection Time (ms)": "22.5", "Charge State": "2", "Monoisotopic M/Z": "400.25", "Scan Description": "top"}},
+		{"negative charge", trailerRecord(1, -3, 0, ""), Trailer{"Ion Injection Time (ms)": "1", "Charge State": "-3", "Monoisotopic M/Z": "0", "Scan Description": ""}},
+		{"truncated record", trailerRecord(5, 1, 300, "x")[:6], Trailer{"Ion Injection Time (ms)": "5", "Charge State": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got := h.Decode(tt.record)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Decode() = %v, want %v", got, tt.want)
+			}
+
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("Decode()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	if v := h.Decode(trailerRecord(22.5, 2, 400.25, "")).Float("Monoisotopic M/Z"); math.Abs(v-400.25) > 1e-9 {
+		t.Errorf("Float() = %f, want 400.25", v)
+	}
+}
+
+func TestLocateTrailer(t *testing.T) {
+
+	var ver Version = 63
+
+	// the run header stream from the error log: two log messages, one segment with one scan
+	// event template and the trailer extra header, followed by one record per scan
+	var b bytes.Buffer
+	b.Write(make([]byte, 16))
+
+	errorlog := uint64(b.Len())
+	binary.Write(&b, binary.LittleEndian, uint32(2))
+	for _, i := range []string{"Vacuum", "Spray"} {
+		p := pascal(i)
+		binary.Write(&b, binary.LittleEndian, float32(1.5))
+		binary.Write(&b, binary.LittleEndian, p.Length)
+		binary.Write(&b, binary.LittleEndian, p.Text)
+	}
+
+	binary.Write(&b, binary.LittleEndian, uint32(1))
+	binary.Write(&b, binary.LittleEndian, uint32(1))
+	b.Write(make([]byte, 128+4+4+16+12))
+
+	writeHeader(&b, trailerHeader())
+
+	params := uint64(b.Len())
+	b.Write(trailerRecord(10, 2, 500.5, "a"))
+	b.Write(trailerRecord(20, 3, 600.5, "b"))
+
+	tests := []struct {
+		name     string
+		errorlog uint64
+		found    bool
+	}{
+		{"sequential stream", errorlog, true},
+		{"misplaced error log", errorlog + 4, false},
+		{"no error log", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			f := filepath.Join(t.TempDir(), "test.raw")
+			if e := os.WriteFile(f, b.Bytes(), 0644); e != nil {
+				t.Fatal(e)
+			}
+
+			file, e := os.Open(f)
+			if e != nil {
+				t.Fatal(e)
+			}
+			defer file.Close()
+
+			rd := RawData{File: file, Version: ver, ScanCount: 2, Scanindex: make(ScanIndex, 2)}
+			rd.locateTrailer(RunHeader{ErrorlogAddr: tt.errorlog, ScanparamsAddr: params})
+
+			if (rd.trailerHeader != nil) != tt.found {
+				t.Fatalf("locateTrailer() found = %v, want %v", rd.trailerHeader != nil, tt.found)
+			}
+
+			if !tt.found {
+				if len(rd.Trailer(1)) != 0 {
+					t.Errorf("Trailer() = %v, want no values", rd.Trailer(1))
+				}
+				return
+			}
+
+			if got := rd.Trailer(2); got["Charge State"] != "3" || got["Monoisotopic M/Z"] != "600.5" || got["Scan Description"] != "b" {
+				t.Errorf("Trailer(2) = %v", got)
+			}
+		})
+	}
+}
+
+func TestRawData_FilterString(t *testing.T) {
+
+	ms1 := ScanEvent{}
+	ms1.Preamble[40] = 4
+	ms1.Preamble[4] = 1
+	ms1.Preamble[11] = 5
+	ms1.Preamble[6] = 1
+
+	ms2 := ScanEvent{Reaction: []Reaction{{Precursormz: 400.2, Energy: 30}}}
+	ms2.Preamble[40] = 0
+	ms2.Preamble[4] = 1
+	ms2.Preamble[5] = 0
+	ms2.Preamble[11] = 5
+	ms2.Preamble[10] = 1
+	ms2.Preamble[6] = 2
+
+	rd := RawData{
+		ScanCount:  2,
+		Scanevents: ScanEvents{ms1, ms2},
+		Scanindex:  ScanIndex{{Lowmz: 350, Highmz: 1800}, {Lowmz: 110, Highmz: 2000}},
+	}
+
+	tests := []struct {
+		sn   int
+		want string
+	}{
+		{1, "FTMS + c NSI Full ms [350.0000-1800.0000]"},
+		{2, "ITMS + c NSI d Full ms2 400.2000@30.00 [110.0000-2000.0000]"},
+		{3, ""},
+	}
+
+	for _, tt := range tests {
+		if got := rd.FilterString(tt.sn); got != tt.want {
+			t.Errorf("FilterString(%d) = %q, want %q", tt.sn, got, tt.want)
+		}
+	}
+}
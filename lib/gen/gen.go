@@ -0,0 +1,310 @@
+// Package gen (Genome)
+package gen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/dat"
+	"github.com/Nesvilab/philosopher/lib/fas"
+	"github.com/Nesvilab/philosopher/lib/met"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/rep"
+	"github.com/sirupsen/logrus"
+)
+
+// Locus is the genomic placement of a peptide, blocks are sorted by genomic position
+type Locus struct {
+	Chrom  string
+	Strand string
+	Blocks []Segment
+}
+
+// Translation is a protein sequence joined to its coding transcript
+type Translation struct {
+	Sequence   string
+	Transcript *Transcript
+}
+
+// Run is the main entry point for the genome mapping
+func Run(m met.Data) met.Data {
+
+	if len(m.Genome.Annotation) == 0 || len(m.Genome.Fasta) == 0 {
+		msg.InputNotFound(errors.New("provide an annotation GTF/GFF file and a transcript translation FASTA file"), "fatal")
+	}
+
+	m.Genome.Annotation, _ = filepath.Abs(m.Genome.Annotation)
+	m.Genome.Fasta, _ = filepath.Abs(m.Genome.Fasta)
+
+	logrus.Info("Parsing the annotation file")
+	annot := ParseAnnotation(m.Genome.Annotation)
+
+	logrus.Info("Joining translations and transcripts")
+	proteins := JoinTranslations(fas.ParseFile2(m.Genome.Fasta), annot, m.Genome.Tag)
+
+	var peptides rep.PeptideEvidenceList
+	rep.RestorePeptide(&peptides)
+
+	if len(peptides) == 0 {
+		msg.Custom(errors.New("no peptides found, run the filter command before mapping peptides to the genome"), "fatal")
+	}
+
+	var mapped int
+	for i := range peptides {
+		peptides[i].GenomicLoci = nil
+		if peptides[i].IsDecoy {
+			continue
+		}
+		for _, l := range MapPeptide(peptides[i], proteins) {
+			peptides[i].GenomicLoci = append(peptides[i].GenomicLoci, l.String())
+		}
+		if len(peptides[i].GenomicLoci) > 0 {
+			mapped++
+		}
+	}
+
+	output := fmt.Sprintf("%s%speptide.bed", m.Home, string(filepath.Separator))
+	WriteBED(output, peptides, proteins)
+
+	rep.SerializePeptides(&peptides)
+
+	logrus.Info(fmt.Sprintf("Mapped %d of %d peptides to the genome", mapped, len(peptides)))
+
+	return m
+}
+
+// JoinTranslations links each FASTA protein to its transcript, proteins are indexed by
+// the same header fields the reports use to name proteins
+func JoinTranslations(entries []fas.FastaEntry, annot Annotation, tag string) map[string]Translation {
+
+	var proteins = make(map[string]Translation)
+	var missing int
+
+	for _, i := range entries {
+
+		t := findTranscript(i.Header, annot)
+		if t == nil {
+			missing++
+			continue
+		}
+
+		r := dat.ProcessHeader(i.Header, i.Seq, dat.Classify(i.Header, tag), tag, false)
+
+		p := Translation{Sequence: strings.TrimSuffix(strings.ToUpper(i.Seq), "*"), Transcript: t}
+		proteins[r.PartHeader] = p
+		if len(r.ID) > 0 {
+			if _, ok := proteins[r.ID]; !ok {
+				proteins[r.ID] = p
+			}
+		}
+	}
+
+	if missing > 0 {
+		logrus.Warning(fmt.Sprintf("%d proteins have no matching transcript on the annotation", missing))
+	}
+
+	return proteins
+}
+
+// findTranscript looks for an annotated transcript ID among the header fields
+func findTranscript(header string, annot Annotation) *Transcript {
+
+	fields := strings.FieldsFunc(header, func(r rune) bool {
+		return r == '|' || r == ' ' || r == ':' || r == '='
+	})
+
+	for _, i := range fields {
+		if t, ok := annot[i]; ok {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// MapPeptide returns the genomic loci of the peptide on every mapped protein
+func MapPeptide(p rep.PeptideEvidence, proteins map[string]Translation) []Locus {
+
+	var names = []string{p.Protein}
+	for k := range p.MappedProteins {
+		if k != p.Protein {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names[1:])
+
+	var loci []Locus
+	var seen = make(map[string]struct{})
+
+	for _, n := range names {
+
+		t, ok := proteins[n]
+		if !ok {
+			continue
+		}
+
+		for offset := 0; ; {
+
+			idx := strings.Index(t.Sequence[offset:], p.Sequence)
+			if idx == -1 {
+				break
+			}
+
+			start := offset + idx
+			offset = start + 1
+
+			l, ok := t.Transcript.Locate(start, start+len(p.Sequence))
+			if !ok {
+				continue
+			}
+
+			// paralogs and isoforms often share the same genomic locus
+			if _, ok := seen[l.String()]; ok {
+				continue
+			}
+			seen[l.String()] = struct{}{}
+			loci = append(loci, l)
+		}
+	}
+
+	return loci
+}
+
+// Locate converts a 0-based, half-open residue interval of the Translation into genomic blocks,
+// intervals crossing exon junctions produce one block per exon
+func (t Transcript) Locate(start, end int) (Locus, bool) {
+
+	var l = Locus{Chrom: t.Chrom, Strand: t.Strand}
+
+	if len(t.CDS) == 0 || start < 0 || end <= start {
+		return l, false
+	}
+
+	// the phase of the first segment skips the bases of an incomplete 5' codon
+	ntStart := t.CDS[0].Phase + 3*start
+	ntEnd := t.CDS[0].Phase + 3*end
+
+	var covered int
+	var cumulative int
+
+	for _, s := range t.CDS {
+
+		length := s.End - s.Start
+
+		from := ntStart
+		if cumulative > from {
+			from = cumulative
+		}
+
+		to := ntEnd
+		if cumulative+length < to {
+			to = cumulative + length
+		}
+
+		if from < to {
+			var b Segment
+			if t.Strand == "-" {
+				b = Segment{Start: s.End - (to - cumulative), End: s.End - (from - cumulative)}
+			} else {
+				b = Segment{Start: s.Start + (from - cumulative), End: s.Start + (to - cumulative)}
+			}
+			l.Blocks = append(l.Blocks, b)
+			covered += to - from
+		}
+
+		cumulative += length
+		if cumulative >= ntEnd {
+			break
+		}
+	}
+
+	if covered != ntEnd-ntStart {
+		return l, false
+	}
+
+	sort.Slice(l.Blocks, func(i, j int) bool { return l.Blocks[i].Start < l.Blocks[j].Start })
+
+	return l, true
+}
+
+// String formats the locus with 1-based coordinates, as shown by genome browsers
+func (l Locus) String() string {
+
+	var blocks []string
+	for _, b := range l.Blocks {
+		blocks = append(blocks, fmt.Sprintf("%d-%d", b.Start+1, b.End))
+	}
+
+	return fmt.Sprintf("%s:%s(%s)", l.Chrom, strings.Join(blocks, ","), l.Strand)
+}
+
+// BED12 formats the locus as a BED12 line
+func (l Locus) BED12(name string, score int) string {
+
+	chromStart := l.Blocks[0].Start
+	chromEnd := l.Blocks[len(l.Blocks)-1].End
+
+	var sizes []string
+	var starts []string
+	for _, b := range l.Blocks {
+		sizes = append(sizes, strconv.Itoa(b.End-b.Start))
+		starts = append(starts, strconv.Itoa(b.Start-chromStart))
+	}
+
+	return fmt.Sprintf("%s\t%d\t%d\t%s\t%d\t%s\t%d\t%d\t0\t%d\t%s\t%s",
+		l.Chrom,
+		chromStart,
+		chromEnd,
+		name,
+		score,
+		l.Strand,
+		chromStart,
+		chromEnd,
+		len(l.Blocks),
+		strings.Join(sizes, ","),
+		strings.Join(starts, ","),
+	)
+}
+
+// WriteBED writes the peptide loci as a BED12 track
+func WriteBED(output string, peptides rep.PeptideEvidenceList, proteins map[string]Translation) {
+
+	file, e := os.Create(output)
+	if e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	defer w.Flush()
+
+	_, e = fmt.Fprintln(w, "track name=\"peptides\" description=\"Philosopher peptide loci\" useScore=1")
+	if e != nil {
+		msg.WriteToFile(e, "fatal")
+	}
+
+	for _, i := range peptides {
+
+		if i.IsDecoy {
+			continue
+		}
+
+		score := int(i.Probability * 1000)
+		if score > 1000 {
+			score = 1000
+		}
+
+		for _, l := range MapPeptide(i, proteins) {
+			_, e = fmt.Fprintln(w, l.BED12(i.Sequence, score))
+			if e != nil {
+				msg.WriteToFile(e, "fatal")
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package gen
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/fas"
+	"github.com/Nesvilab/philosopher/lib/rep"
+)
+
+func TestMapPeptide(t *testing.T) {
+
+	// the plus strand transcript has two CDS exons of 6 and 9 bases, PE and PTI, so EPT crosses the junction
+	// the minus strand transcript has the same layout, listed in genomic order on a GFF3 file
+	gtf := "chr1\ttest\tCDS\t101\t106\t.\t+\t0\tgene_id \"G1\"; transcript_id \"ENST1.1\"; gene_name \"ONE\";\n" +
+		"chr1\ttest\tCDS\t201\t209\t.\t+\t0\tgene_id \"G1\"; transcript_id \"ENST1.1\"; gene_name \"ONE\";\n"
+	gff := "##gff-version 3\n" +
+		"chr2\ttest\tCDS\t301\t309\t.\t-\t0\tID=CDS:P2;Parent=transcript:ENST2\n" +
+		"chr2\ttest\tCDS\t401\t406\t.\t-\t0\tID=CDS:P2;Parent=transcript:ENST2\n"
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.gtf": gtf, "b.gff3": gff} {
+		if e := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	entries := []fas.FastaEntry{
+		{Header: "ENSP1.1|ENST1.1|ENSG1.1|-|-|ONE-201|ONE|5", Seq: "PEPTI"},
+		{Header: "ENSP2 pep chromosome:GRCh38:2:301:406:-1 transcript:ENST2", Seq: "PEPTI"},
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		protein string
+		peptide string
+		want    string
+		bed     string
+	}{
+		{
+			name:    "Testing a peptide inside a single plus strand exon",
+			file:    "a.gtf",
+			protein: "ENSP1.1|ENST1.1|ENSG1.1|-|-|ONE-201|ONE|5",
+			peptide: "PE",
+			want:    "chr1:101-106(+)",
+		},
+		{
+			name:    "Testing a spliced peptide on the plus strand",
+			file:    "a.gtf",
+			protein: "ENSP1.1|ENST1.1|ENSG1.1|-|-|ONE-201|ONE|5",
+			peptide: "EPT",
+			want:    "chr1:104-106,201-206(+)",
+			bed:     "chr1\t103\t206\tEPT\t900\t+\t103\t206\t0\t2\t3,6\t0,97",
+		},
+		{
+			name:    "Testing a spliced peptide on the minus strand",
+			file:    "b.gff3",
+			protein: "ENSP2",
+			peptide: "EPT",
+			want:    "chr2:304-309,401-403(-)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			annot := ParseAnnotation(filepath.Join(dir, tt.file))
+			proteins := JoinTranslations(entries, annot, "rev_")
+
+			loci := MapPeptide(rep.PeptideEvidence{Sequence: tt.peptide, Protein: tt.protein}, proteins)
+			if len(loci) != 1 || loci[0].String() != tt.want {
+				t.Fatalf("MapPeptide() = %v, want %v", loci, tt.want)
+			}
+			if len(tt.bed) > 0 && loci[0].BED12(tt.peptide, 900) != tt.bed {
+				t.Errorf("BED12() = %v, want %v", loci[0].BED12(tt.peptide, 900), tt.bed)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package gen
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+)
+
+// Segment is a genomic interval using 0-based, half-open coordinates
+type Segment struct {
+	Start int
+	End   int
+	Phase int
+}
+
+// Transcript is a coding transcript with the CDS segments sorted in translation order
+type Transcript struct {
+	ID     string
+	Gene   string
+	Chrom  string
+	Strand string
+	CDS    []Segment
+}
+
+// Annotation maps transcript IDs, with and without version, to coding transcripts
+type Annotation map[string]*Transcript
+
+// ParseAnnotation reads the CDS features from a GTF or GFF3 file, gzipped files are supported
+func ParseAnnotation(file string) Annotation {
+
+	f, e := os.Open(file)
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(file, ".gz") {
+		gz, e := gzip.NewReader(f)
+		if e != nil {
+			msg.ReadFile(e, "fatal")
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var annot = make(Annotation)
+	var transcripts []*Transcript
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+
+	for scanner.Scan() {
+
+		line := scanner.Text()
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		parts := strings.Split(line, "\t")
+		if len(parts) < 9 || parts[2] != "CDS" {
+			continue
+		}
+
+		start, e1 := strconv.Atoi(parts[3])
+		end, e2 := strconv.Atoi(parts[4])
+		if e1 != nil || e2 != nil || end < start {
+			msg.Custom(fmt.Errorf("malformed annotation line: %s", line), "warning")
+			continue
+		}
+
+		phase, _ := strconv.Atoi(parts[7])
+
+		attr := parseAttributes(parts[8])
+		id := attr["transcript_id"]
+		if len(id) == 0 {
+			id = attr["Parent"]
+		}
+
+		// Ensembl GFF3 files prefix the parent with the feature type
+		if idx := strings.Index(id, ":"); idx != -1 {
+			id = id[idx+1:]
+		}
+
+		if len(id) == 0 {
+			continue
+		}
+
+		t, ok := annot[id]
+		if !ok {
+			t = &Transcript{ID: id, Chrom: parts[0], Strand: parts[6]}
+			annot[id] = t
+			transcripts = append(transcripts, t)
+		}
+
+		if len(t.Gene) == 0 {
+			t.Gene = attr["gene_name"]
+		}
+
+		t.CDS = append(t.CDS, Segment{Start: start - 1, End: end, Phase: phase})
+	}
+
+	if e := scanner.Err(); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	if len(transcripts) == 0 {
+		msg.Custom(errors.New("no CDS features found on the annotation file"), "fatal")
+	}
+
+	for _, t := range transcripts {
+
+		sort.Slice(t.CDS, func(i, j int) bool {
+			if t.Strand == "-" {
+				return t.CDS[i].Start > t.CDS[j].Start
+			}
+			return t.CDS[i].Start < t.CDS[j].Start
+		})
+
+		// versionless IDs help joining annotations and FASTA files from different releases
+		if idx := strings.LastIndex(t.ID, "."); idx != -1 {
+			if _, ok := annot[t.ID[:idx]]; !ok {
+				annot[t.ID[:idx]] = t
+			}
+		}
+	}
+
+	return annot
+}
+
+// parseAttributes reads both the GTF (key "value";) and the GFF3 (key=value;) attribute layouts
+func parseAttributes(s string) map[string]string {
+
+	var attr = make(map[string]string)
+
+	for _, i := range strings.Split(s, ";") {
+
+		i = strings.TrimSpace(i)
+		if len(i) == 0 {
+			continue
+		}
+
+		if idx := strings.Index(i, "="); idx != -1 && !strings.Contains(i[:idx], " ") {
+			attr[i[:idx]] = i[idx+1:]
+			continue
+		}
+
+		if idx := strings.Index(i, " "); idx != -1 {
+			attr[i[:idx]] = strings.Trim(strings.TrimSpace(i[idx+1:]), "\"")
+		}
+	}
+
+	return attr
+}
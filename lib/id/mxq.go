@@ -0,0 +1,744 @@
+package id
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/mod"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxQuantReverse is the prefix MaxQuant adds to the reversed protein sequences
+const maxQuantReverse = "REV__"
+
+// maxQuantMassTolerance is the largest difference between the peptide mass and the MaxQuant mass
+// before the PSM is reported as inconsistent
+const maxQuantMassTolerance = 0.01
+
+// the site is the last term in parentheses, as in Oxidation (M) or Acetyl (Protein N-term)
+var maxQuantSiteRG = regexp.MustCompile(`^(.+?)\s*\(([^()]*)\)$`)
+
+// maxQuantModification is a modification name used by MaxQuant and its Unimod counterpart
+type maxQuantModification struct {
+	Accession string
+	Name      string
+	Shift     float64
+}
+
+// maxQuantModifications maps the MaxQuant modification names and their two-letter abbreviations, the
+// remaining names are searched on Unimod
+var maxQuantModifications = map[string]maxQuantModification{
+	"oxidation":       {"UNIMOD:35", "Oxidation", 15.994915},
+	"ox":              {"UNIMOD:35", "Oxidation", 15.994915},
+	"acetyl":          {"UNIMOD:1", "Acetyl", 42.010565},
+	"ac":              {"UNIMOD:1", "Acetyl", 42.010565},
+	"phospho":         {"UNIMOD:21", "Phospho", 79.966331},
+	"ph":              {"UNIMOD:21", "Phospho", 79.966331},
+	"deamidation":     {"UNIMOD:7", "Deamidated", 0.984016},
+	"de":              {"UNIMOD:7", "Deamidated", 0.984016},
+	"carbamidomethyl": {"UNIMOD:4", "Carbamidomethyl", 57.021464},
+	"ca":              {"UNIMOD:4", "Carbamidomethyl", 57.021464},
+	"gln->pyro-glu":   {"UNIMOD:28", "Gln->pyro-Glu", -17.026549},
+	"gl":              {"UNIMOD:28", "Gln->pyro-Glu", -17.026549},
+	"glu->pyro-glu":   {"UNIMOD:27", "Glu->pyro-Glu", -18.010565},
+	"glygly":          {"UNIMOD:121", "GG", 114.042927},
+	"gg":              {"UNIMOD:121", "GG", 114.042927},
+	"methyl":          {"UNIMOD:34", "Methyl", 14.015650},
+	"me":              {"UNIMOD:34", "Methyl", 14.015650},
+	"dimethyl":        {"UNIMOD:36", "Dimethyl", 28.031300},
+	"di":              {"UNIMOD:36", "Dimethyl", 28.031300},
+	"trimethyl":       {"UNIMOD:37", "Trimethyl", 42.046950},
+	"tr":              {"UNIMOD:37", "Trimethyl", 42.046950},
+	"carbamyl":        {"UNIMOD:5", "Carbamyl", 43.005814},
+	"amidated":        {"UNIMOD:2", "Amidated", -0.984016},
+}
+
+// maxQuantFixed is a fixed modification from the MaxQuant parameters
+type maxQuantFixed struct {
+	Name     string
+	Sites    string
+	NTerm    bool
+	CTerm    bool
+	Shift    float64
+	Unimod   [2]string
+	Resolved bool
+}
+
+// maxQuantIon holds the PSM values the protein groups need
+type maxQuantIon struct {
+	PeptideIonIdentification
+	Groups []int
+}
+
+// ReadMaxQuantInput reads a MaxQuant txt folder and organizes the PSMs from msms.txt, or evidence.txt when
+// msms.txt is missing, into a PSM list. The protein groups are returned as a protXML when the folder has
+// the proteinGroups.txt file
+func ReadMaxQuantInput(dir, decoyTag string, weight float64, minPepLen int) (PepIDListPtrs, ProtXML, string) {
+
+	if info, e := os.Stat(dir); e != nil {
+		msg.InputNotFound(e, "error")
+	} else if !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	p, protXML := ReadMaxQuant(dir, decoyTag, minPepLen)
+
+	var pepXML PepXML4Serialiazation
+	pepXML.FileName = p.FileName
+	pepXML.SearchEngine = p.SearchEngine
+	pepXML.DecoyTag = decoyTag
+	pepXML.Prophet = p.Prophet
+	pepXML.Modifications = p.Modifications
+	pepXML.PeptideIdentification = ToPepIDListPtrs(p.PeptideIdentification)
+
+	pepXML.prepare()
+
+	if len(protXML.Groups) > 0 {
+		protXML.MarkUniquePeptides(weight)
+		protXML.PromoteProteinIDs()
+		protXML.Serialize()
+	}
+
+	return pepXML.PeptideIdentification, protXML, p.SearchEngine
+}
+
+// MaxQuantProteinGroups returns the proteinGroups.txt path of a MaxQuant txt folder, or an empty string
+func MaxQuantProteinGroups(dir string) string {
+
+	if len(dir) == 0 {
+		return ""
+	}
+
+	if info, e := os.Stat(dir); e == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+
+	f := filepath.Join(dir, "proteinGroups.txt")
+	if _, e := os.Stat(f); e != nil {
+		return ""
+	}
+
+	return f
+}
+
+// ReadMaxQuant parses the MaxQuant PSMs and protein groups. The probability of each PSM is 1 - PEP, and the
+// probability of each protein group is the highest probability of its PSMs
+func ReadMaxQuant(dir, decoyTag string, minPepLen int) (PepXML, ProtXML) {
+
+	var p PepXML
+	var protXML ProtXML
+
+	f := filepath.Join(dir, "msms.txt")
+	scanColumn := "Scan number"
+	if _, e := os.Stat(f); e != nil {
+		f = filepath.Join(dir, "evidence.txt")
+		scanColumn = "MS/MS scan number"
+	}
+
+	if _, e := os.Stat(f); e != nil {
+		msg.InputNotFound(fmt.Errorf("the MaxQuant folder %s has no msms.txt or evidence.txt file", dir), "error")
+	}
+
+	fixed, version := readMaxQuantParameters(filepath.Join(dir, "parameters.txt"))
+
+	p.FileName = filepath.Base(f)
+	p.SearchEngine = "MaxQuant"
+	p.Prophet = "maxquant"
+	p.DecoyTag = decoyTag
+	p.Modifications.Index = make(map[string]mod.Modification)
+
+	if len(version) > 0 {
+		logrus.Info("Parsing MaxQuant ", version, " results")
+	}
+	logrus.Info("Parsing ", f)
+
+	var unknown = make(map[string][]string)
+	var mismatches []string
+	var ions []maxQuantIon
+
+	e := readTSV(f, func(line int, row map[string]string) error {
+
+		// evidence rows without a fragment spectrum are quantified by matching between runs
+		if len(row[scanColumn]) == 0 {
+			return nil
+		}
+
+		peptide, names, e := ParseMaxQuantSequence(row["Modified sequence"])
+		if e != nil {
+			return e
+		}
+
+		if len(names) > 0 {
+			for _, i := range names {
+				unknown[i] = append(unknown[i], row["Modified sequence"])
+			}
+			return nil
+		}
+
+		for _, i := range fixed {
+			i.apply(&peptide)
+		}
+
+		psm, e := maxQuantPSM(row, scanColumn, peptide, decoyTag, p.Modifications)
+		if e != nil {
+			return e
+		}
+
+		mass, _ := peptide.Mass()
+		if math.Abs(mass-psm.CalcNeutralPepMass) > maxQuantMassTolerance {
+			mismatches = append(mismatches, fmt.Sprintf("%s (%.4f, MaxQuant %.4f)", row["Modified sequence"], mass, psm.CalcNeutralPepMass))
+		}
+
+		psm.SpectrumFile = p.FileName
+		psm.Index = uint32(len(p.PeptideIdentification))
+
+		p.PeptideIdentification = append(p.PeptideIdentification, psm)
+		ions = append(ions, maxQuantPeptideIon(psm, row["Protein group IDs"]))
+
+		return nil
+	})
+	if e != nil {
+		msg.Custom(fmt.Errorf("%s: %w", filepath.Base(f), e), "error")
+	}
+
+	if len(unknown) > 0 {
+		msg.Custom(unknownModificationsError(unknown), "error")
+	}
+
+	if len(mismatches) > 0 {
+		msg.Custom(fmt.Errorf("%d PSMs have a peptide mass different from the MaxQuant mass, check the fixed modifications and labels on parameters.txt, the first is %s", len(mismatches), mismatches[0]), "warning")
+	}
+
+	if len(p.PeptideIdentification) == 0 {
+		msg.NoPSMFound(fmt.Errorf("no PSMs found on %s", f), "error")
+	}
+
+	if groups := MaxQuantProteinGroups(dir); len(groups) > 0 {
+		protXML = readMaxQuantProteinGroups(groups, decoyTag, ions, minPepLen)
+	}
+
+	return p, protXML
+}
+
+// ParseMaxQuantSequence reads the MaxQuant modified sequences, as _(Acetyl (Protein N-term))M(Oxidation (M))PEPTIDE_
+// or the older _(ac)M(ox)PEPTIDE_ notation. The second value lists the modification names that are not recognized
+func ParseMaxQuantSequence(s string) (ModifiedPeptide, []string, error) {
+
+	var p = ModifiedPeptide{Shifts: make(map[int]float64), Unimod: make(map[int][2]string)}
+	var unknown []string
+	var sequence strings.Builder
+	var cterm [][2]string
+	var closed bool
+
+	for i := 0; i < len(s); {
+
+		c := s[i]
+
+		switch {
+		case c == '_':
+			closed = sequence.Len() > 0
+			i++
+
+		case c >= 'A' && c <= 'Z' && !closed:
+			sequence.WriteByte(c)
+			i++
+
+		case c == '(' || c == '[':
+			end := closingParenthesis(s, i)
+			if end < 0 {
+				return p, nil, fmt.Errorf("the modified sequence %s has unbalanced parentheses", s)
+			}
+
+			name := s[i+1 : end]
+			i = end + 1
+
+			shift, unimod, ok := maxQuantModificationMass(name)
+			if !ok {
+				unknown = append(unknown, name)
+				continue
+			}
+
+			position := sequence.Len()
+
+			switch {
+			case closed || strings.Contains(strings.ToLower(name), "c-term"):
+				p.CTerm += shift
+				cterm = append(cterm, unimod)
+			case position == 0:
+				p.NTerm += shift
+				p.Unimod[0] = unimod
+			default:
+				p.Shifts[position] += shift
+				p.Unimod[position] = unimod
+			}
+
+		default:
+			return p, nil, fmt.Errorf("unexpected character %q on the modified sequence %s", c, s)
+		}
+	}
+
+	p.Sequence = sequence.String()
+	if len(p.Sequence) == 0 {
+		return p, nil, fmt.Errorf("the modified sequence %s has no residues", s)
+	}
+
+	for _, i := range cterm {
+		p.Unimod[len(p.Sequence)+1] = i
+	}
+
+	return p, unknown, nil
+}
+
+// closingParenthesis finds the parenthesis closing the one at start, the modification names have nested ones
+func closingParenthesis(s string, start int) int {
+
+	var depth int
+
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// maxQuantModificationMass finds the mass shift and the Unimod accession and name of a MaxQuant modification
+func maxQuantModificationMass(name string) (float64, [2]string, bool) {
+
+	base := name
+	if match := maxQuantSiteRG.FindStringSubmatch(name); match != nil {
+		base = match[1]
+	}
+
+	for _, i := range []string{name, base} {
+		if m, ok := maxQuantModifications[strings.ToLower(i)]; ok {
+			return m.Shift, [2]string{m.Accession, m.Name}, true
+		}
+	}
+
+	if term, ok := unimodTerm(base); ok {
+		return term.MonoIsotopicMass, [2]string{term.ID, term.Name}, true
+	}
+
+	return 0, [2]string{}, false
+}
+
+// unknownModificationsError lists the modifications that cannot be converted, with the number of PSMs
+// having them and one of their sequences
+func unknownModificationsError(unknown map[string][]string) error {
+
+	var names []string
+	for i := range unknown {
+		names = append(names, i)
+	}
+	sort.Strings(names)
+
+	var list []string
+	for _, i := range names {
+		list = append(list, fmt.Sprintf("%s on %d PSMs, as in %s", i, len(unknown[i]), unknown[i][0]))
+	}
+
+	return fmt.Errorf("cannot find the mass of the MaxQuant modifications %s; the names must match a Unimod modification", strings.Join(list, "; "))
+}
+
+// maxQuantPSM maps a msms.txt or evidence.txt row to a PSM
+func maxQuantPSM(row map[string]string, scanColumn string, peptide ModifiedPeptide, decoyTag string, mods mod.Modifications) (PeptideIdentification, error) {
+
+	var psm PeptideIdentification
+	psm.AlternativeProteins = make(map[string]string)
+
+	scan, e := strconv.Atoi(strings.Split(row[scanColumn], ";")[0])
+	if e != nil {
+		return psm, fmt.Errorf("the scan number %s is not valid", row[scanColumn])
+	}
+
+	charge, e := strconv.ParseUint(row["Charge"], 10, 8)
+	if e != nil || charge == 0 {
+		return psm, fmt.Errorf("the charge %s is not valid", row["Charge"])
+	}
+
+	if len(row["Raw file"]) == 0 {
+		return psm, fmt.Errorf("the scan %d has no raw file", scan)
+	}
+
+	psm.Spectrum = fmt.Sprintf("%s.%05d.%05d.%d", row["Raw file"], scan, scan, charge)
+	psm.AssumedCharge = uint8(charge)
+	psm.HitRank = 1
+	psm.NumberOfEnzymaticTermini = 2
+
+	for i, j := range strings.Split(row["Proteins"], ";") {
+
+		protein := strings.TrimSpace(j)
+		if len(protein) == 0 {
+			continue
+		}
+
+		// MaxQuant marks the reversed sequences with its own prefix
+		if strings.HasPrefix(protein, maxQuantReverse) && !strings.HasPrefix(protein, decoyTag) {
+			protein = decoyTag + strings.TrimPrefix(protein, maxQuantReverse)
+		}
+
+		if i == 0 {
+			psm.Protein = protein
+		} else if protein != psm.Protein {
+			psm.AlternativeProteins[protein] = peptide.PrevAA + "#" + peptide.NextAA
+		}
+	}
+
+	if len(psm.Protein) == 0 {
+		return psm, fmt.Errorf("the scan %d of %s has no proteins", scan, row["Raw file"])
+	}
+
+	psm.Peptide = peptide.Sequence
+	psm.PeptideLength = uint8(len(peptide.Sequence))
+
+	if mc, e := strconv.Atoi(row["Missed cleavages"]); e == nil {
+		psm.NumberofMissedCleavages = uint8(mc)
+	}
+
+	psm.CalcNeutralPepMass, _ = peptide.Mass()
+	if mass, e := strconv.ParseFloat(row["Mass"], 64); e == nil {
+		psm.CalcNeutralPepMass = mass
+	}
+
+	psm.PrecursorNeutralMass = psm.CalcNeutralPepMass
+	if mz, e := strconv.ParseFloat(row["m/z"], 64); e == nil {
+		psm.PrecursorNeutralMass = (mz - bio.Proton) * float64(charge)
+	}
+	psm.UncalibratedPrecursorNeutralMass = psm.PrecursorNeutralMass
+	psm.Massdiff = uti.ToFixed(psm.PrecursorNeutralMass-psm.CalcNeutralPepMass, 4)
+
+	// MaxQuant reports the retention times in minutes
+	if rt, e := strconv.ParseFloat(row["Retention time"], 64); e == nil {
+		psm.RetentionTime = rt * 60
+	}
+
+	if score, e := strconv.ParseFloat(row["Score"], 64); e == nil {
+		psm.Hyperscore = score
+		if delta, e := strconv.ParseFloat(row["Delta score"], 64); e == nil {
+			psm.Nextscore = score - delta
+		}
+	}
+
+	for _, i := range []string{"Precursor Intensity", "Intensity"} {
+		if intensity, e := strconv.ParseFloat(row[i], 64); e == nil {
+			psm.Intensity = intensity
+			break
+		}
+	}
+
+	pep, e := strconv.ParseFloat(row["PEP"], 64)
+	if e != nil {
+		return psm, fmt.Errorf("the PEP %s of scan %d is not valid", row["PEP"], scan)
+	}
+	psm.Probability = 1 - math.Min(math.Max(pep, 0), 1)
+
+	psm.mapModsFromPepXML(peptide.modificationInfo(mods), mods)
+
+	return psm, nil
+}
+
+// maxQuantPeptideIon keeps the PSM values used by the protein groups
+func maxQuantPeptideIon(psm PeptideIdentification, groups string) maxQuantIon {
+
+	var ion maxQuantIon
+
+	ion.PeptideSequence = psm.Peptide
+	ion.ModifiedPeptide = psm.ModifiedPeptide
+	ion.PeptideLength = len(psm.Peptide)
+	ion.Charge = psm.AssumedCharge
+	ion.InitialProbability = psm.Probability
+	ion.CalcNeutralPepMass = psm.CalcNeutralPepMass
+	ion.NumberOfEnzymaticTermini = psm.NumberOfEnzymaticTermini
+	ion.Razor = -1
+
+	ion.PeptideParentProtein = append(ion.PeptideParentProtein, psm.Protein)
+	for i := range psm.AlternativeProteins {
+		ion.PeptideParentProtein = append(ion.PeptideParentProtein, i)
+	}
+	sort.Strings(ion.PeptideParentProtein[1:])
+
+	for _, i := range strings.Split(groups, ";") {
+		if id, e := strconv.Atoi(strings.TrimSpace(i)); e == nil {
+			ion.Groups = append(ion.Groups, id)
+		}
+	}
+
+	return ion
+}
+
+// readMaxQuantProteinGroups maps each row of proteinGroups.txt to a protein group, the peptide ions are
+// the PSMs linked to the group
+func readMaxQuantProteinGroups(f, decoyTag string, ions []maxQuantIon, minPepLen int) ProtXML {
+
+	var protXML ProtXML
+	protXML.FileName = filepath.Base(f)
+	protXML.DecoyTag = decoyTag
+
+	var members = make(map[int][]maxQuantIon)
+	for _, i := range ions {
+		for _, j := range i.Groups {
+			members[j] = append(members[j], i)
+		}
+	}
+
+	logrus.Info("Parsing ", f)
+
+	e := readTSV(f, func(line int, row map[string]string) error {
+
+		id, e := strconv.Atoi(row["id"])
+		if e != nil {
+			return fmt.Errorf("the protein group id %s is not valid", row["id"])
+		}
+
+		if len(members[id]) == 0 {
+			return nil
+		}
+
+		var names []string
+		for _, i := range strings.Split(row["Protein IDs"], ";") {
+			if strings.HasPrefix(i, maxQuantReverse) && !strings.HasPrefix(i, decoyTag) {
+				i = decoyTag + strings.TrimPrefix(i, maxQuantReverse)
+			}
+			if len(i) > 0 {
+				names = append(names, i)
+			}
+		}
+
+		if len(names) == 0 {
+			return fmt.Errorf("the protein group %d has no proteins", id)
+		}
+
+		var pt ProteinIdentification
+		pt.GroupNumber = uint32(len(protXML.Groups) + 1)
+		pt.ProteinName = names[0]
+		pt.IndistinguishableProtein = names[1:]
+		pt.OriginalHeader = pt.ProteinName
+
+		if header := strings.Split(row["Fasta headers"], ";")[0]; len(header) > 0 {
+			pt.OriginalHeader = header
+			if fields := strings.SplitN(header, " ", 2); len(fields) > 1 {
+				pt.Description = fields[1]
+			}
+		}
+
+		if length, e := strconv.Atoi(strings.Split(row["Sequence length"], ";")[0]); e == nil {
+			pt.Length = length
+		}
+
+		if coverage, e := strconv.ParseFloat(row["Sequence coverage [%]"], 32); e == nil {
+			pt.PercentCoverage = float32(coverage)
+		}
+
+		pt.PeptideIons = maxQuantGroupIons(members[id])
+
+		var stripped = make(map[string]struct{})
+		for _, i := range pt.PeptideIons {
+
+			if _, ok := stripped[i.PeptideSequence]; !ok {
+				stripped[i.PeptideSequence] = struct{}{}
+				pt.UniqueStrippedPeptides = append(pt.UniqueStrippedPeptides, i.PeptideSequence)
+			}
+
+			pt.Probability = math.Max(pt.Probability, i.InitialProbability)
+			if i.PeptideLength >= minPepLen {
+				pt.TopPepProb = math.Max(pt.TopPepProb, i.InitialProbability)
+			}
+		}
+
+		pt.TotalNumberPeptides = len(pt.UniqueStrippedPeptides)
+
+		protXML.Groups = append(protXML.Groups, GroupIdentification{GroupNumber: pt.GroupNumber, Probability: pt.Probability, Proteins: ProtIDList{pt}})
+
+		return nil
+	})
+	if e != nil {
+		msg.Custom(fmt.Errorf("%s: %w", filepath.Base(f), e), "error")
+	}
+
+	if len(protXML.Groups) == 0 {
+		msg.NoProteinFound(errors.New("no protein groups with PSMs found on proteinGroups.txt"), "error")
+	}
+
+	return protXML
+}
+
+// maxQuantGroupIons merges the PSMs of a protein group into peptide ions, keeping the best probability
+func maxQuantGroupIons(psms []maxQuantIon) []PeptideIonIdentification {
+
+	var ions []PeptideIonIdentification
+	var index = make(map[string]int)
+
+	for _, i := range psms {
+
+		key := fmt.Sprintf("%s#%s#%d", i.PeptideSequence, i.ModifiedPeptide, i.Charge)
+
+		if j, ok := index[key]; ok {
+			ions[j].InitialProbability = math.Max(ions[j].InitialProbability, i.InitialProbability)
+			continue
+		}
+
+		ion := i.PeptideIonIdentification
+		ion.Weight = 1 / float64(len(i.Groups))
+		ion.GroupWeight = ion.Weight
+		ion.IsUnique = len(i.Groups) == 1
+		ion.Modifications.Index = make(map[string]mod.Modification)
+
+		index[key] = len(ions)
+		ions = append(ions, ion)
+	}
+
+	return ions
+}
+
+// readMaxQuantParameters reads the fixed modifications and the MaxQuant version from parameters.txt
+func readMaxQuantParameters(f string) ([]maxQuantFixed, string) {
+
+	var fixed []maxQuantFixed
+	var version string
+
+	if _, e := os.Stat(f); e != nil {
+		msg.Custom(errors.New("the MaxQuant folder has no parameters.txt file, the fixed modifications are not added to the peptides"), "warning")
+		return fixed, version
+	}
+
+	var unknown = make(map[string][]string)
+
+	e := readTSV(f, func(line int, row map[string]string) error {
+
+		switch row["Parameter"] {
+		case "Version":
+			version = row["Value"]
+		case "Fixed modifications":
+			for _, i := range strings.Split(row["Value"], ";") {
+
+				name := strings.TrimSpace(i)
+				if len(name) == 0 {
+					continue
+				}
+
+				m := newMaxQuantFixed(name)
+				if !m.Resolved {
+					unknown[name] = append(unknown[name], "parameters.txt")
+					continue
+				}
+
+				fixed = append(fixed, m)
+			}
+		}
+
+		return nil
+	})
+	if e != nil {
+		msg.Custom(fmt.Errorf("parameters.txt: %w", e), "error")
+	}
+
+	if len(unknown) > 0 {
+		msg.Custom(unknownModificationsError(unknown), "error")
+	}
+
+	return fixed, version
+}
+
+// newMaxQuantFixed resolves a fixed modification name, as Carbamidomethyl (C)
+func newMaxQuantFixed(name string) maxQuantFixed {
+
+	var m = maxQuantFixed{Name: name}
+
+	if match := maxQuantSiteRG.FindStringSubmatch(name); match != nil {
+
+		site := strings.ToLower(match[2])
+
+		switch {
+		case strings.Contains(site, "n-term"):
+			m.NTerm = true
+		case strings.Contains(site, "c-term"):
+			m.CTerm = true
+		default:
+			m.Sites = match[2]
+		}
+	}
+
+	m.Shift, m.Unimod, m.Resolved = maxQuantModificationMass(name)
+
+	return m
+}
+
+// apply adds the fixed modification to the peptide, MaxQuant leaves them out of the modified sequences
+func (m maxQuantFixed) apply(p *ModifiedPeptide) {
+
+	switch {
+	case m.NTerm:
+		p.NTerm += m.Shift
+		p.Unimod[0] = m.Unimod
+	case m.CTerm:
+		p.CTerm += m.Shift
+		p.Unimod[len(p.Sequence)+1] = m.Unimod
+	default:
+		for i := 0; i < len(p.Sequence); i++ {
+			if strings.IndexByte(m.Sites, p.Sequence[i]) >= 0 {
+				p.Shifts[i+1] += m.Shift
+				p.Unimod[i+1] = m.Unimod
+			}
+		}
+	}
+}
+
+// readTSV reads a tab-delimited table with a header, row is called with the values of each line by column name
+func readTSV(f string, row func(line int, values map[string]string) error) error {
+
+	file, e := os.Open(f)
+	if e != nil {
+		return e
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+
+	var header []string
+	var line int
+
+	for scanner.Scan() {
+
+		line++
+		parts := strings.Split(strings.TrimRight(scanner.Text(), "\r"), "\t")
+
+		if line == 1 {
+			header = parts
+			continue
+		}
+
+		var values = make(map[string]string, len(header))
+		for i, j := range header {
+			if i < len(parts) {
+				values[j] = parts[i]
+			}
+		}
+
+		if e := row(line, values); e != nil {
+			return fmt.Errorf("line %d: %w", line, e)
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,131 @@
+package id
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/obo"
+)
+
+func TestParseMaxQuantSequence(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		sequence string
+		want     ModifiedPeptide
+		unknown  int
+		wantErr  bool
+	}{
+		{"unmodified", "_PEPTIDER_", ModifiedPeptide{Sequence: "PEPTIDER", Shifts: map[int]float64{}}, 0, false},
+		{"named", "_(Acetyl (Protein N-term))PEPM(Oxidation (M))K_", ModifiedPeptide{Sequence: "PEPMK", NTerm: 42.010565, Shifts: map[int]float64{4: 15.994915}}, 0, false},
+		{"abbreviated", "_(ac)PEPM(ox)K_", ModifiedPeptide{Sequence: "PEPMK", NTerm: 42.010565, Shifts: map[int]float64{4: 15.994915}}, 0, false},
+		{"phospho", "_S(Phospho (STY))EK_", ModifiedPeptide{Sequence: "SEK", Shifts: map[int]float64{1: 79.966331}}, 0, false},
+		{"unknown", "_PEPT(mystery)K_", ModifiedPeptide{Sequence: "PEPTK", Shifts: map[int]float64{}}, 1, false},
+		{"unbalanced", "_PEPM(Oxidation (M)K_", ModifiedPeptide{}, 0, true},
+		{"lowercase", "_PEPmK_", ModifiedPeptide{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			// the unknown names are only searched on Unimod after the built-in table
+			unimodTerms = map[string]obo.Term{}
+			defer func() { unimodTerms = nil }()
+
+			got, unknown, e := ParseMaxQuantSequence(tt.sequence)
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("ParseMaxQuantSequence() error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if len(unknown) != tt.unknown {
+				t.Errorf("Unknown modifications are incorrect, got %v, want %d", unknown, tt.unknown)
+			}
+
+			if got.Sequence != tt.want.Sequence || got.NTerm != tt.want.NTerm || got.CTerm != tt.want.CTerm || len(got.Shifts) != len(tt.want.Shifts) {
+				t.Errorf("ParseMaxQuantSequence() = %+v, want %+v", got, tt.want)
+			}
+
+			for k, v := range tt.want.Shifts {
+				if got.Shifts[k] != v {
+					t.Errorf("Shift at %d is incorrect, got %v, want %v", k, got.Shifts[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestReadMaxQuant(t *testing.T) {
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"parameters.txt": "Parameter\tValue\n" +
+			"Version\t2.4.0.0\n" +
+			"Fixed modifications\tCarbamidomethyl (C)\n",
+		"msms.txt": "Raw file\tScan number\tSequence\tModified sequence\tMissed cleavages\tProteins\tCharge\tm/z\tRetention time\tPEP\tScore\tDelta score\tid\tProtein group IDs\n" +
+			"sample\t1234\tACMK\t_(Acetyl (Protein N-term))ACM(Oxidation (M))K_\t0\tsp|P1|A;sp|P2|B\t2\t300.5\t20.5\t0.02\t120\t20\t0\t0\n" +
+			"sample\t2000\tPEPTIDER\t_PEPTIDER_\t0\tREV__sp|P3|C\t3\t400.1\t30\t0.9\t10\t5\t1\t1\n",
+		"proteinGroups.txt": "Protein IDs\tMajority protein IDs\tFasta headers\tSequence length\tSequence coverage [%]\tid\n" +
+			"sp|P1|A;sp|P2|B\tsp|P1|A\tsp|P1|A Protein A\t300\t1.5\t0\n" +
+			"REV__sp|P3|C\tREV__sp|P3|C\t\t250\t3.2\t1\n",
+	}
+
+	for k, v := range files {
+		if e := os.WriteFile(filepath.Join(dir, k), []byte(v), 0644); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	p, protXML := ReadMaxQuant(dir, "rev_", 3)
+
+	if len(p.PeptideIdentification) != 2 {
+		t.Fatalf("PSM number is incorrect, got %d, want 2", len(p.PeptideIdentification))
+	}
+
+	first := p.PeptideIdentification[0]
+	if first.Spectrum != "sample.01234.01234.2" || first.Peptide != "ACMK" || first.Protein != "sp|P1|A" || len(first.AlternativeProteins) != 1 {
+		t.Errorf("First PSM is incorrect, got %+v", first)
+	}
+
+	if math.Abs(first.Probability-0.98) > 1e-9 || first.RetentionTime != 1230 || first.Hyperscore != 120 || first.Nextscore != 100 {
+		t.Errorf("First PSM scores are incorrect, got %+v", first)
+	}
+
+	if first.ModifiedPeptide != "n[43]AC[160]M[147]K" {
+		t.Errorf("First PSM modified peptide is incorrect, got %s", first.ModifiedPeptide)
+	}
+
+	second := p.PeptideIdentification[1]
+	if second.Spectrum != "sample.02000.02000.3" || second.Protein != "rev_sp|P3|C" || len(second.ModifiedPeptide) > 0 {
+		t.Errorf("Second PSM is incorrect, got %+v", second)
+	}
+
+	for _, i := range []string{"C#160.0306", "M#147.0354", "N-term#43.0184"} {
+		if _, ok := p.Modifications.Index[i]; !ok {
+			t.Errorf("Modification %s is missing, got %v", i, p.Modifications.Index)
+		}
+	}
+
+	if len(protXML.Groups) != 2 {
+		t.Fatalf("Protein group number is incorrect, got %d, want 2", len(protXML.Groups))
+	}
+
+	pt := protXML.Groups[0].Proteins[0]
+	if pt.ProteinName != "sp|P1|A" || len(pt.IndistinguishableProtein) != 1 || pt.Description != "Protein A" || pt.Length != 300 || len(pt.PeptideIons) != 1 {
+		t.Errorf("First protein group is incorrect, got %+v", pt)
+	}
+
+	if math.Abs(pt.TopPepProb-0.98) > 1e-9 || !pt.PeptideIons[0].IsUnique || pt.PeptideIons[0].ModifiedPeptide != "n[43]AC[160]M[147]K" {
+		t.Errorf("First protein group peptides are incorrect, got %+v", pt.PeptideIons)
+	}
+
+	if protXML.Groups[1].Proteins[0].ProteinName != "rev_sp|P3|C" {
+		t.Errorf("Decoy protein group is incorrect, got %s", protXML.Groups[1].Proteins[0].ProteinName)
+	}
+}
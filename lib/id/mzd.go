@@ -0,0 +1,440 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/mod"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/obo"
+	"github.com/Nesvilab/philosopher/lib/psi"
+	"github.com/Nesvilab/philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+var nativeScanRG = regexp.MustCompile(`scan=(\d+)`)
+var nativeIndexRG = regexp.MustCompile(`index=(\d+)`)
+var spectrumTitleRG = regexp.MustCompile(`^(\S+?)\.(\d+)\.(\d+)\.(\d+)`)
+
+// expectationScores are the expectation values of the search engines, in order of preference
+var expectationScores = []string{
+	"MS:1002053", // MS-GF:EValue
+	"MS:1001172", // Mascot:expectation value
+	"MS:1001330", // X!Tandem:expect
+	"MS:1001328", // OMSSA:evalue
+	"MS:1002257", // Comet:expectation value
+}
+
+// unimodTerms caches the Unimod terms by accession and lower case name, the ontology is only loaded
+// when a modification has no mass
+var unimodTerms map[string]obo.Term
+
+// ReadMzIdentMLInput reads one or more mzIdentML files and organizes the data into a PSM list
+func ReadMzIdentMLInput(input, decoyTag string) (PepIDListPtrs, string) {
+
+	var files []string
+
+	if strings.HasSuffix(strings.ToLower(input), ".mzid") {
+		files = append(files, input)
+	} else {
+		files = uti.IOReadDir(input, ".mzid")
+	}
+
+	if len(files) == 0 {
+		msg.NoParametersFound(errors.New("missing mzIdentML files"), "error")
+	}
+
+	sort.Strings(files)
+
+	var pepXML PepXML4Serialiazation
+	var searchEngine string
+
+	pepXML.DecoyTag = decoyTag
+	pepXML.Modifications.Index = make(map[string]mod.Modification)
+
+	for _, f := range files {
+
+		logrus.Info("Parsing ", f)
+
+		var xml psi.MzIdentML
+		xml.Parse(f)
+
+		p := ReadMzIdentML(xml, filepath.Base(f), decoyTag)
+
+		if len(searchEngine) == 0 {
+			searchEngine = p.SearchEngine
+			pepXML.FileName = p.FileName
+			pepXML.SearchEngine = p.SearchEngine
+			pepXML.Database = p.Database
+		}
+
+		for k, v := range p.Modifications.Index {
+			if _, ok := pepXML.Modifications.Index[k]; !ok {
+				pepXML.Modifications.Index[k] = v
+			}
+		}
+
+		pepXML.PeptideIdentification = append(pepXML.PeptideIdentification, ToPepIDListPtrs(p.PeptideIdentification)...)
+	}
+
+	pepXML.prepare()
+
+	return pepXML.PeptideIdentification, searchEngine
+}
+
+// ReadMzIdentML maps the top ranked SpectrumIdentificationItem of each spectrum to a PSM, following
+// the peptide, evidence and database sequence references
+func ReadMzIdentML(xml psi.MzIdentML, name, decoyTag string) PepXML {
+
+	var p PepXML
+
+	p.FileName = name
+	p.DecoyTag = decoyTag
+	p.Prophet = "mzidentml"
+	p.Modifications.Index = make(map[string]mod.Modification)
+
+	if len(xml.AnalysisSoftwareList.AnalysisSoftware) > 0 {
+		software := xml.AnalysisSoftwareList.AnalysisSoftware[0]
+		p.SearchEngine = software.Name
+		if len(p.SearchEngine) == 0 {
+			p.SearchEngine = software.ID
+		}
+	}
+
+	if len(xml.DataCollection.Inputs.SearchDatabase) > 0 {
+		p.Database = xml.DataCollection.Inputs.SearchDatabase[0].Location
+	}
+
+	var peptides = make(map[string]psi.Peptide)
+	for _, i := range xml.SequenceCollection.Peptide {
+		peptides[i.ID] = i
+	}
+
+	var evidences = make(map[string]psi.PeptideEvidence)
+	for _, i := range xml.SequenceCollection.PeptideEvidence {
+		evidences[i.ID] = i
+	}
+
+	var proteins = make(map[string]string)
+	for _, i := range xml.SequenceCollection.DBSequence {
+		proteins[i.ID] = i.Accession
+	}
+
+	var sources = make(map[string]string)
+	for _, i := range xml.DataCollection.Inputs.SpectraData {
+		sources[i.ID] = spectraSource(i)
+	}
+
+	for _, list := range xml.DataCollection.AnalysisData.SpectrumIdentificationList {
+		for _, sir := range list.SpectrumIdentificationResult {
+
+			if len(sir.SpectrumIdentificationItem) == 0 {
+				continue
+			}
+
+			// the best hit has the lowest rank, the order of the items is not mandatory
+			best := sir.SpectrumIdentificationItem[0]
+			for _, i := range sir.SpectrumIdentificationItem[1:] {
+				if i.Rank < best.Rank {
+					best = i
+				}
+			}
+
+			psm, e := mzIdentMLPSM(sir, best, sources[sir.SpectraDataRef], peptides, evidences, proteins, decoyTag, p.Modifications)
+			if e != nil {
+				msg.Custom(fmt.Errorf("%s, %s: %w", name, sir.ID, e), "error")
+			}
+
+			psm.SpectrumFile = name
+			psm.Index = uint32(len(p.PeptideIdentification))
+
+			p.PeptideIdentification = append(p.PeptideIdentification, psm)
+		}
+	}
+
+	if len(p.PeptideIdentification) == 0 {
+		msg.NoPSMFound(errors.New(name), "warning")
+	}
+
+	return p
+}
+
+// spectraSource is the spectra file name without folders and extension, paths may come from Windows
+func spectraSource(s psi.SpectraData) string {
+
+	source := s.Location
+	if len(source) == 0 {
+		source = s.Name
+	}
+
+	source = path.Base(strings.ReplaceAll(source, "\\", "/"))
+	source = strings.TrimSuffix(source, ".gz")
+
+	return strings.TrimSuffix(source, path.Ext(source))
+}
+
+// mzIdentMLPSM maps a SpectrumIdentificationItem and the result it belongs to into a PSM
+func mzIdentMLPSM(sir psi.SpectrumIdentificationResult, sii psi.SpectrumIdentificationItem, source string, peptides map[string]psi.Peptide, evidences map[string]psi.PeptideEvidence, proteins map[string]string, decoyTag string, mods mod.Modifications) (PeptideIdentification, error) {
+
+	var psm PeptideIdentification
+	psm.AlternativeProteins = make(map[string]string)
+
+	// spectrum values are written on the result, some engines write them on the item
+	params := append(append([]psi.CVParam{}, sir.CVParam...), sii.CVParam...)
+
+	scan, title := mzIdentMLScan(sir.SpectrumID, params)
+	if len(source) == 0 {
+		source = title
+	}
+
+	if scan == 0 || len(source) == 0 || sii.ChargeState == 0 {
+		return psm, fmt.Errorf("cannot find the spectrum file, scan and charge of the spectrum %s", sir.SpectrumID)
+	}
+
+	psm.Spectrum = fmt.Sprintf("%s.%05d.%05d.%d", source, scan, scan, sii.ChargeState)
+	psm.AssumedCharge = sii.ChargeState
+	psm.HitRank = sii.Rank
+	if psm.HitRank == 0 {
+		psm.HitRank = 1
+	}
+
+	z := float64(sii.ChargeState)
+	psm.PrecursorNeutralMass = (sii.ExperimentalMassToCharge - bio.Proton) * z
+	psm.UncalibratedPrecursorNeutralMass = psm.PrecursorNeutralMass
+	psm.CalcNeutralPepMass = (sii.CalculatedMassToCharge - bio.Proton) * z
+	psm.Massdiff = uti.ToFixed(psm.PrecursorNeutralMass-psm.CalcNeutralPepMass, 4)
+
+	pep, ok := peptides[sii.PeptideRef]
+	if !ok {
+		return psm, fmt.Errorf("the peptide %s is not defined", sii.PeptideRef)
+	}
+
+	peptide, e := mzIdentMLPeptide(pep)
+	if e != nil {
+		return psm, e
+	}
+
+	for _, i := range sii.PeptideEvidenceRef {
+
+		ev, ok := evidences[i.PeptideEvidenceRef]
+		if !ok {
+			return psm, fmt.Errorf("the peptide evidence %s is not defined", i.PeptideEvidenceRef)
+		}
+
+		protein, ok := proteins[ev.DBSequenceRef]
+		if !ok {
+			return psm, fmt.Errorf("the database sequence %s is not defined", ev.DBSequenceRef)
+		}
+
+		// decoys are recognized by their tag on the next steps
+		if strings.EqualFold(ev.IsDecoy, "true") && !strings.HasPrefix(protein, decoyTag) {
+			protein = decoyTag + protein
+		}
+
+		if len(psm.Protein) == 0 {
+			psm.Protein = protein
+			peptide.PrevAA, peptide.NextAA = ev.Pre, ev.Post
+		} else if protein != psm.Protein {
+			psm.AlternativeProteins[protein] = ev.Pre + "#" + ev.Post
+		}
+	}
+
+	if len(psm.Protein) == 0 {
+		return psm, fmt.Errorf("the peptide %s has no proteins", pep.ID)
+	}
+
+	psm.Peptide = peptide.Sequence
+	psm.PeptideLength = uint8(len(peptide.Sequence))
+	psm.PrevAA = []byte(peptide.PrevAA)
+	psm.NextAA = []byte(peptide.NextAA)
+
+	mzIdentMLScores(&psm, params)
+
+	psm.mapModsFromPepXML(peptide.modificationInfo(mods), mods)
+
+	return psm, nil
+}
+
+// mzIdentMLScan finds the scan number on the cvParams, the native ID or the spectrum title, the second
+// value is the spectra file named on the title
+func mzIdentMLScan(spectrumID string, params []psi.CVParam) (int, string) {
+
+	var scan int
+	var source string
+
+	for _, i := range params {
+		switch i.Accession {
+		case "MS:1001115": // scan number(s)
+			scan, _ = strconv.Atoi(strings.Fields(i.Value + " 0")[0])
+		case "MS:1000796": // spectrum title
+			if match := spectrumTitleRG.FindStringSubmatch(i.Value); match != nil {
+				source = match[1]
+				if scan == 0 {
+					scan, _ = strconv.Atoi(match[2])
+				}
+			}
+		}
+	}
+
+	if scan > 0 {
+		return scan, source
+	}
+
+	if match := nativeScanRG.FindStringSubmatch(spectrumID); match != nil {
+		scan, _ = strconv.Atoi(match[1])
+	} else if match := nativeIndexRG.FindStringSubmatch(spectrumID); match != nil {
+		// peak lists are referenced by their zero-based position
+		scan, _ = strconv.Atoi(match[1])
+		scan++
+	}
+
+	return scan, source
+}
+
+// mzIdentMLPeptide reads the sequence and the modifications of a peptide, the modifications without a
+// mass shift are resolved with their Unimod accession
+func mzIdentMLPeptide(pep psi.Peptide) (ModifiedPeptide, error) {
+
+	p := ModifiedPeptide{
+		Sequence: strings.ToUpper(strings.TrimSpace(pep.PeptideSequence.Value)),
+		Shifts:   make(map[int]float64),
+		Unimod:   make(map[int][2]string),
+	}
+
+	if len(p.Sequence) == 0 {
+		return p, fmt.Errorf("the peptide %s has no sequence", pep.ID)
+	}
+
+	for _, i := range pep.Modification {
+
+		location, e := strconv.Atoi(i.Location)
+		if e != nil && len(i.Location) > 0 {
+			return p, fmt.Errorf("the modification location %s of peptide %s is not a number", i.Location, pep.ID)
+		}
+
+		var unimod [2]string
+		for _, j := range i.CVParam {
+			if strings.HasPrefix(j.Accession, "UNIMOD:") {
+				unimod = [2]string{j.Accession, j.Name}
+				break
+			}
+		}
+
+		shift := i.MonoIsotopicMassDelta
+		if shift == 0 {
+			if term, ok := unimodTerm(unimod[0]); ok {
+				shift = term.MonoIsotopicMass
+			}
+		}
+
+		if shift == 0 {
+			var names []string
+			for _, j := range i.CVParam {
+				names = append(names, fmt.Sprintf("%s (%s)", j.Name, j.Accession))
+			}
+			return p, fmt.Errorf("the modification %s of peptide %s has no mass shift", strings.Join(names, ", "), pep.ID)
+		}
+
+		switch {
+		case location <= 0:
+			p.NTerm += shift
+			p.Unimod[0] = unimod
+		case location > len(p.Sequence):
+			p.CTerm += shift
+			p.Unimod[len(p.Sequence)+1] = unimod
+		default:
+			p.Shifts[location] += shift
+			p.Unimod[location] = unimod
+		}
+	}
+
+	if len(pep.SubstitutionModification) > 0 {
+		logrus.Warning("Amino acid substitutions are not supported, the original sequence of peptide ", pep.ID, " is used")
+	}
+
+	return p, nil
+}
+
+// unimodTerm finds a Unimod term by its accession or name
+func unimodTerm(key string) (obo.Term, bool) {
+
+	if len(key) == 0 {
+		return obo.Term{}, false
+	}
+
+	if unimodTerms == nil {
+
+		unimodTerms = make(map[string]obo.Term)
+
+		o := obo.NewUniModOntology()
+		for _, i := range o.Terms {
+			unimodTerms[i.ID] = i
+			unimodTerms[strings.ToLower(i.Name)] = i
+		}
+	}
+
+	term, ok := unimodTerms[strings.ToLower(key)]
+	if !ok {
+		term, ok = unimodTerms[key]
+	}
+
+	return term, ok
+}
+
+// mzIdentMLScores reads the search engine scores and the probability of a PSM. Posterior error
+// probabilities are used when available, otherwise the probability is derived from the expectation
+// value, so the PSMs keep the order the search engine gave them
+func mzIdentMLScores(psm *PeptideIdentification, params []psi.CVParam) {
+
+	var values = make(map[string]float64)
+	for _, i := range params {
+		if v, e := strconv.ParseFloat(i.Value, 64); e == nil {
+			values[i.Accession] = v
+		}
+	}
+
+	for _, i := range expectationScores {
+		if v, ok := values[i]; ok {
+			psm.Expectation = v
+			break
+		}
+	}
+
+	for k, v := range values {
+		switch k {
+		case "MS:1001155": // SEQUEST:xcorr
+			psm.Xcorr = v
+		case "MS:1001156": // SEQUEST:deltacn
+			psm.DeltaCN = v
+		case "MS:1001331": // X!Tandem:hyperscore
+			psm.Hyperscore = v
+		case "MS:1000894", "MS:1000016": // retention time, scan start time
+			psm.RetentionTime = v
+		}
+	}
+
+	for _, i := range params {
+		if (i.Accession == "MS:1000894" || i.Accession == "MS:1000016") && strings.HasPrefix(i.UnitName, "minute") {
+			psm.RetentionTime *= 60
+		}
+	}
+
+	if v, ok := values["MS:1001493"]; ok { // percolator:PEP
+		psm.Probability = 1 - v
+	} else if v, ok := values["MS:1002357"]; ok { // PSM-level probability
+		psm.Probability = v
+	} else if v, ok := values["MS:1001950"]; ok { // PEAKS:peptideScore, -10lgP
+		psm.Probability = 1 - math.Pow(10, -v/10)
+	} else if psm.Expectation > 0 {
+		psm.Probability = 1 / (1 + psm.Expectation)
+	}
+}
@@ -0,0 +1,98 @@
+package id
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/psi"
+)
+
+func TestReadMzIdentML(t *testing.T) {
+
+	mzid := `<?xml version="1.0" encoding="UTF-8"?>
+<MzIdentML id="test" version="1.2.0" xmlns="http://psidev.info/psi/pi/mzIdentML/1.2">
+<AnalysisSoftwareList><AnalysisSoftware id="ID_software" name="MS-GF+" version="2023.01.12"/></AnalysisSoftwareList>
+<SequenceCollection>
+<DBSequence id="DBSeq1" accession="sp|P1|A" searchDatabase_ref="SearchDB_1"/>
+<DBSequence id="DBSeq2" accession="sp|P2|B" searchDatabase_ref="SearchDB_1"/>
+<Peptide id="Pep1">
+<PeptideSequence>PEMK</PeptideSequence>
+<Modification location="0" monoisotopicMassDelta="42.010565"><cvParam cvRef="UNIMOD" accession="UNIMOD:1" name="Acetyl"/></Modification>
+<Modification location="3" monoisotopicMassDelta="15.994915"><cvParam cvRef="UNIMOD" accession="UNIMOD:35" name="Oxidation"/></Modification>
+</Peptide>
+<Peptide id="Pep2"><PeptideSequence>AAAK</PeptideSequence></Peptide>
+<PeptideEvidence id="PE1" peptide_ref="Pep1" dBSequence_ref="DBSeq1" pre="K" post="A" isDecoy="false"/>
+<PeptideEvidence id="PE2" peptide_ref="Pep1" dBSequence_ref="DBSeq2" pre="R" post="-" isDecoy="true"/>
+<PeptideEvidence id="PE3" peptide_ref="Pep2" dBSequence_ref="DBSeq1" pre="K" post="L" isDecoy="false"/>
+</SequenceCollection>
+<DataCollection>
+<Inputs>
+<SearchDatabase id="SearchDB_1" location="/db/proteins.fasta"/>
+<SpectraData id="SID_1" location="C:\data\sample.mzML"/>
+</Inputs>
+<AnalysisData>
+<SpectrumIdentificationList id="SI_LIST_1">
+<SpectrumIdentificationResult id="SIR_1" spectrumID="controllerType=0 controllerNumber=1 scan=1234" spectraData_ref="SID_1">
+<SpectrumIdentificationItem id="SII_1_2" rank="2" chargeState="2" experimentalMassToCharge="300.0" calculatedMassToCharge="299.0" peptide_ref="Pep2" passThreshold="true">
+<PeptideEvidenceRef peptideEvidence_ref="PE3"/>
+<cvParam cvRef="PSI-MS" accession="MS:1002053" name="MS-GF:EValue" value="5.0"/>
+</SpectrumIdentificationItem>
+<SpectrumIdentificationItem id="SII_1_1" rank="1" chargeState="2" experimentalMassToCharge="289.631" calculatedMassToCharge="289.630" peptide_ref="Pep1" passThreshold="true">
+<PeptideEvidenceRef peptideEvidence_ref="PE1"/>
+<PeptideEvidenceRef peptideEvidence_ref="PE2"/>
+<cvParam cvRef="PSI-MS" accession="MS:1002053" name="MS-GF:EValue" value="0.01"/>
+</SpectrumIdentificationItem>
+<cvParam cvRef="PSI-MS" accession="MS:1000894" name="retention time" value="20.5" unitCvRef="UO" unitAccession="UO:0000031" unitName="minute"/>
+</SpectrumIdentificationResult>
+</SpectrumIdentificationList>
+</AnalysisData>
+</DataCollection>
+</MzIdentML>
+`
+
+	f := filepath.Join(t.TempDir(), "test.mzid")
+	if e := os.WriteFile(f, []byte(mzid), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	var xml psi.MzIdentML
+	xml.Parse(f)
+
+	p := ReadMzIdentML(xml, "test.mzid", "rev_")
+
+	if p.SearchEngine != "MS-GF+" || p.Database != "/db/proteins.fasta" {
+		t.Errorf("Search summary is incorrect, got %s and %s", p.SearchEngine, p.Database)
+	}
+
+	if len(p.PeptideIdentification) != 1 {
+		t.Fatalf("PSM number is incorrect, got %d, want 1", len(p.PeptideIdentification))
+	}
+
+	psm := p.PeptideIdentification[0]
+
+	if psm.Spectrum != "sample.01234.01234.2" || psm.Peptide != "PEMK" || psm.HitRank != 1 || psm.AssumedCharge != 2 {
+		t.Errorf("PSM spectrum is incorrect, got %+v", psm)
+	}
+
+	if psm.Protein != "sp|P1|A" || string(psm.PrevAA) != "K" || string(psm.NextAA) != "A" {
+		t.Errorf("PSM protein is incorrect, got %s %s %s", psm.Protein, psm.PrevAA, psm.NextAA)
+	}
+
+	if _, ok := psm.AlternativeProteins["rev_sp|P2|B"]; !ok || len(psm.AlternativeProteins) != 1 {
+		t.Errorf("Alternative proteins are incorrect, got %v", psm.AlternativeProteins)
+	}
+
+	if psm.Expectation != 0.01 || math.Abs(psm.Probability-1/1.01) > 1e-9 || psm.RetentionTime != 1230 || math.Abs(psm.Massdiff-0.002) > 1e-9 {
+		t.Errorf("PSM scores are incorrect, got %+v", psm)
+	}
+
+	if psm.ModifiedPeptide != "n[43]PEM[147]K" {
+		t.Errorf("Modified peptide is incorrect, got %s", psm.ModifiedPeptide)
+	}
+
+	if m, ok := p.Modifications.Index["M#147.0354"]; !ok || m.ID != "UNIMOD:35" || m.Name != "Oxidation" {
+		t.Errorf("Modification index is incorrect, got %+v", p.Modifications.Index)
+	}
+}
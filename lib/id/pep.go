@@ -97,6 +97,7 @@ type PeptideIdentification struct {
 	MSFragerLoc                      *MSFraggerLoc
 	PTM                              *PTM
 	Modifications                    mod.ModificationsSlice
+	Rescore                          float64
 }
 
 type PTM struct {
@@ -396,14 +397,20 @@ func ReadPepXMLInput(xmlFile, decoyTag, temp string, models bool) (PepIDListPtrs
 	}
 	pepXML.Modifications.Index = modsIndex
 
+	pepXML.prepare()
+
+	return pepXML.PeptideIdentification, searchEngine
+}
+
+// prepare promotes the protein identifications, sorts the PSMs and serializes the combined input
+func (p *PepXML4Serialiazation) prepare() {
+
 	// promoting Spectra that matches to both decoys and targets to TRUE hits
-	pepXML.PromoteProteinIDs()
+	p.PromoteProteinIDs()
 
 	// serialize all pep files
-	sort.Sort(pepXML.PeptideIdentification)
-	pepXML.Serialize()
-
-	return pepXML.PeptideIdentification, searchEngine
+	sort.Sort(p.PeptideIdentification)
+	p.Serialize()
 }
 
 func processSpectrumQuery(sq spc.SpectrumQuery, mods mod.Modifications, decoyTag, FileName string) PeptideIdentification {
@@ -865,6 +872,19 @@ func (p *PepXML) Restore() {
 	}
 }
 
+// UpdatePepXML replaces the PSMs of the combined input with the given list, so the changes made after
+// the input was read, like the rescoring, reach the filters that restore it
+func UpdatePepXML(p PepIDListPtrs) {
+	if serialize_pepxml {
+		var pepXML PepXML4Serialiazation
+		sys.Restore(&pepXML, sys.PepxmlBin(), false)
+		pepXML.PeptideIdentification = p
+		pepXML.Serialize()
+	} else if globals_pepxml != nil {
+		globals_pepxml.PeptideIdentification = p
+	}
+}
+
 // Serialize converts the whle structure to a gob file
 func (p *PepIDList) Serialize(level string) {
 
@@ -0,0 +1,49 @@
+package id
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Nesvilab/philosopher/lib/sys"
+)
+
+func TestUpdatePepXML(t *testing.T) {
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	if e := os.Chdir(t.TempDir()); e != nil {
+		t.Fatal(e)
+	}
+
+	if e := os.Mkdir(sys.MetaDir(), 0755); e != nil {
+		t.Fatal(e)
+	}
+
+	defer func(v bool) { serialize_pepxml = v }(serialize_pepxml)
+
+	for _, serialized := range []bool{false, true} {
+
+		serialize_pepxml = serialized
+
+		p := PepIDListPtrs{{Spectrum: "run.00001.00001.2", Probability: 0.2}, {Spectrum: "run.00002.00002.2", Probability: 0.3}}
+
+		pepXML := PepXML4Serialiazation{DecoyTag: "rev_", PeptideIdentification: p}
+		pepXML.Serialize()
+
+		p[0].Probability = 0.9
+		p[1].Rescore = 1.5
+		UpdatePepXML(p)
+
+		var restored PepXML
+		restored.Restore()
+
+		if restored.DecoyTag != "rev_" || len(restored.PeptideIdentification) != 2 {
+			t.Fatalf("serialized = %v, restored input is incorrect, got %v", serialized, restored)
+		}
+
+		if restored.PeptideIdentification[0].Probability != 0.9 || restored.PeptideIdentification[1].Rescore != 1.5 {
+			t.Errorf("serialized = %v, the input was restored without the updates, got %v", serialized, restored.PeptideIdentification)
+		}
+	}
+}
@@ -0,0 +1,595 @@
+package id
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/bio"
+	"github.com/Nesvilab/philosopher/lib/mod"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/spc"
+	"github.com/Nesvilab/philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// hydrogenMass is added to the N-terminal modification masses, as in pepXML
+	hydrogenMass = 1.007825035
+	// hydroxylMass is added to the C-terminal modification masses, as in pepXML
+	hydroxylMass = 17.002740
+)
+
+// percolatorColumns are the leading columns of the Percolator PSM and peptide results
+var percolatorColumns = []string{"PSMId", "score", "q-value", "posterior_error_prob", "peptide", "proteinIds"}
+
+// MSFragger writes the pin identifiers as <file>.<scan>.<scan>.<charge>_<rank>, Comet as <file>_<scan>_<charge>_<rank>
+var fraggerSpecIDRG = regexp.MustCompile(`^(.+)\.(\d+)\.(\d+)\.(\d+)(?:_(\d+))?$`)
+var cometSpecIDRG = regexp.MustCompile(`^(.+)_(\d+)_(\d+)_(\d+)$`)
+
+var flankedPeptideRG = regexp.MustCompile(`^([A-Z-])\.(.+)\.([A-Z-])$`)
+
+// PinEntry holds the search values a pin file has for one PSM
+type PinEntry struct {
+	Scan     int
+	Charge   uint8
+	ExpMass  float64
+	CalcMass float64
+	Features map[string]float64
+}
+
+// ModifiedPeptide is a peptide string split into its sequence and mass shifts
+type ModifiedPeptide struct {
+	Sequence string
+	PrevAA   string
+	NextAA   string
+	NTerm    float64
+	CTerm    float64
+	// Shifts holds the mass shifts by their 1-based residue position
+	Shifts map[int]float64
+	// Unimod holds the accession and name of the modifications by position, 0 and len+1 are the termini
+	Unimod map[int][2]string
+}
+
+// ReadPercolatorInput reads the Percolator PSM or peptide results and organizes the data into a PSM list.
+// The input is a results file or a folder with the target and decoy results, the pin files found
+// next to them add the precursor masses, retention times and search engine scores
+func ReadPercolatorInput(input, decoyTag string) (PepIDListPtrs, string) {
+
+	var results, pins []string
+
+	info, e := os.Stat(input)
+	if e != nil {
+		msg.InputNotFound(e, "error")
+	}
+
+	dir := input
+	if !info.IsDir() {
+		dir = filepath.Dir(input)
+		results = append(results, input)
+	}
+
+	entries, e := os.ReadDir(dir)
+	if e != nil {
+		msg.ReadFile(e, "error")
+	}
+
+	for _, i := range entries {
+
+		f := filepath.Join(dir, i.Name())
+
+		if i.IsDir() {
+			continue
+		} else if strings.EqualFold(filepath.Ext(f), ".pin") {
+			pins = append(pins, f)
+		} else if info.IsDir() && isPercolatorResult(f) {
+			results = append(results, f)
+		}
+	}
+
+	if len(results) == 0 {
+		msg.NoParametersFound(errors.New("missing Percolator results files"), "error")
+	}
+
+	var features = make(map[string]PinEntry)
+	for _, i := range pins {
+		logrus.Info("Parsing ", i)
+		for k, v := range ReadPin(i) {
+			features[k] = v
+		}
+	}
+
+	p := ReadPercolator(results, decoyTag, features)
+
+	var pepXML PepXML4Serialiazation
+	pepXML.FileName = p.FileName
+	pepXML.SearchEngine = p.SearchEngine
+	pepXML.DecoyTag = decoyTag
+	pepXML.Prophet = p.Prophet
+	pepXML.Modifications = p.Modifications
+	pepXML.PeptideIdentification = ToPepIDListPtrs(p.PeptideIdentification)
+
+	pepXML.prepare()
+
+	return pepXML.PeptideIdentification, p.SearchEngine
+}
+
+// isPercolatorResult checks the header of a tab-delimited file for the Percolator results columns
+func isPercolatorResult(f string) bool {
+
+	file, e := os.Open(f)
+	if e != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return false
+	}
+
+	return strings.HasPrefix(scanner.Text(), strings.Join(percolatorColumns, "\t"))
+}
+
+// ReadPercolator parses the Percolator results files, a PSM is only kept once when it is present on
+// both the PSM and the peptide results. The probability of each PSM is 1 - PEP
+func ReadPercolator(files []string, decoyTag string, features map[string]PinEntry) PepXML {
+
+	var p PepXML
+	var seen = make(map[string]struct{})
+
+	p.FileName = filepath.Base(files[0])
+	p.SearchEngine = "Percolator"
+	p.Prophet = "percolator"
+	p.DecoyTag = decoyTag
+	p.Modifications.Index = make(map[string]mod.Modification)
+
+	sort.Strings(files)
+
+	for _, f := range files {
+
+		logrus.Info("Parsing ", f)
+
+		file, e := os.Open(f)
+		if e != nil {
+			msg.ReadFile(e, "error")
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+
+		var line int
+		for scanner.Scan() {
+
+			line++
+			parts := strings.Split(scanner.Text(), "\t")
+
+			if line == 1 {
+				if !strings.HasPrefix(scanner.Text(), strings.Join(percolatorColumns, "\t")) {
+					msg.Custom(fmt.Errorf("%s is not a Percolator results file, the header must start with %s", f, strings.Join(percolatorColumns, ", ")), "error")
+				}
+				continue
+			}
+
+			if len(parts) < len(percolatorColumns) {
+				continue
+			}
+
+			if _, ok := seen[parts[0]]; ok {
+				continue
+			}
+			seen[parts[0]] = struct{}{}
+
+			psm, e := percolatorPSM(parts, features, p.Modifications)
+			if e != nil {
+				msg.Custom(fmt.Errorf("%s, line %d: %w", filepath.Base(f), line, e), "error")
+			}
+
+			psm.SpectrumFile = filepath.Base(f)
+			psm.Index = uint32(len(p.PeptideIdentification))
+
+			p.PeptideIdentification = append(p.PeptideIdentification, psm)
+		}
+
+		if e := scanner.Err(); e != nil {
+			msg.ReadFile(e, "error")
+		}
+
+		file.Close()
+	}
+
+	if len(p.PeptideIdentification) == 0 {
+		msg.NoPSMFound(errors.New(strings.Join(files, ", ")), "warning")
+	}
+
+	return p
+}
+
+// percolatorPSM maps a Percolator results line to a PSM, the modifications found on the peptide are
+// added to the modifications index
+func percolatorPSM(parts []string, features map[string]PinEntry, mods mod.Modifications) (PeptideIdentification, error) {
+
+	var psm PeptideIdentification
+	psm.AlternativeProteins = make(map[string]string)
+
+	pin, hasPin := features[parts[0]]
+
+	source, scan, charge, rank, ok := parseSpecID(parts[0])
+	if !ok {
+		return psm, fmt.Errorf("cannot find the spectrum file, scan and charge in the PSMId %s", parts[0])
+	}
+
+	psm.Spectrum = fmt.Sprintf("%s.%05d.%05d.%d", source, scan, scan, charge)
+	psm.AssumedCharge = charge
+	psm.HitRank = rank
+
+	pep, e := strconv.ParseFloat(parts[3], 64)
+	if e != nil {
+		return psm, fmt.Errorf("the posterior error probability %s is not a number", parts[3])
+	}
+	psm.Probability = 1 - pep
+
+	// MSFragger writes the modified residues and termini with their full masses, Comet writes mass shifts
+	parse := ParseModifiedPeptide
+	if fraggerSpecIDRG.MatchString(parts[0]) {
+		parse = ParseResidueMassPeptide
+	}
+
+	peptide, e := parse(parts[4])
+	if e != nil {
+		return psm, e
+	}
+
+	psm.Peptide = peptide.Sequence
+	psm.PeptideLength = uint8(len(peptide.Sequence))
+	psm.PrevAA = []byte(peptide.PrevAA)
+	psm.NextAA = []byte(peptide.NextAA)
+
+	// the protein list is tab-delimited, some writers use commas instead
+	var proteins []string
+	for _, i := range parts[5:] {
+		for _, j := range strings.Split(i, ",") {
+			if j = strings.TrimSpace(j); len(j) > 0 {
+				proteins = append(proteins, j)
+			}
+		}
+	}
+
+	if len(proteins) == 0 {
+		return psm, fmt.Errorf("the PSM %s has no proteins", parts[0])
+	}
+
+	psm.Protein = proteins[0]
+	for _, i := range proteins[1:] {
+		psm.AlternativeProteins[i] = peptide.PrevAA + "#" + peptide.NextAA
+	}
+
+	calcMass, ok := peptide.Mass()
+	if !ok {
+		return psm, fmt.Errorf("the peptide %s has residues without a defined mass", parts[4])
+	}
+
+	psm.CalcNeutralPepMass = calcMass
+	psm.PrecursorNeutralMass = calcMass
+
+	if hasPin {
+
+		if pin.CalcMass > 0 {
+			psm.CalcNeutralPepMass = pin.CalcMass
+		}
+
+		if pin.ExpMass > 0 {
+			psm.PrecursorNeutralMass = pin.ExpMass
+		}
+
+		pin.scores(&psm)
+	}
+
+	psm.UncalibratedPrecursorNeutralMass = psm.PrecursorNeutralMass
+	psm.Massdiff = uti.ToFixed(psm.PrecursorNeutralMass-psm.CalcNeutralPepMass, 4)
+
+	psm.mapModsFromPepXML(peptide.modificationInfo(mods), mods)
+
+	return psm, nil
+}
+
+// parseSpecID reads the spectrum file, scan, charge and rank from a pin identifier
+func parseSpecID(id string) (string, int, uint8, uint8, bool) {
+
+	var source, scan, charge, rank string
+
+	if match := fraggerSpecIDRG.FindStringSubmatch(id); match != nil {
+		source, scan, charge, rank = match[1], match[2], match[4], match[5]
+	} else if match := cometSpecIDRG.FindStringSubmatch(id); match != nil {
+		source, scan, charge, rank = match[1], match[2], match[3], match[4]
+	} else {
+		return "", 0, 0, 0, false
+	}
+
+	s, _ := strconv.Atoi(scan)
+	z, _ := strconv.Atoi(charge)
+	r, _ := strconv.Atoi(rank)
+
+	if r == 0 {
+		r = 1
+	}
+
+	return source, s, uint8(z), uint8(r), true
+}
+
+// ReadPin parses the PSM values of a Percolator input file, indexed by their SpecId
+func ReadPin(f string) map[string]PinEntry {
+
+	var entries = make(map[string]PinEntry)
+
+	file, e := os.Open(f)
+	if e != nil {
+		msg.ReadFile(e, "error")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+
+	var header []string
+	for scanner.Scan() {
+
+		parts := strings.Split(scanner.Text(), "\t")
+
+		if header == nil {
+			header = parts
+			continue
+		}
+
+		// the optional second line holds the default feature directions
+		if len(parts) < len(header) || strings.EqualFold(parts[0], "DefaultDirection") {
+			continue
+		}
+
+		entry := PinEntry{Features: make(map[string]float64)}
+
+		for i, name := range header {
+
+			value, e := strconv.ParseFloat(parts[i], 64)
+			if e != nil {
+				continue
+			}
+
+			switch key := strings.ToLower(name); {
+			case key == "scannr":
+				entry.Scan = int(value)
+			case key == "expmass":
+				entry.ExpMass = value
+			case key == "calcmass":
+				entry.CalcMass = value
+			case strings.HasPrefix(key, "charge") && value == 1:
+				z, _ := strconv.Atoi(strings.TrimLeft(key, "charge_"))
+				entry.Charge = uint8(z)
+			default:
+				entry.Features[key] = value
+			}
+		}
+
+		entries[parts[0]] = entry
+	}
+
+	if e := scanner.Err(); e != nil {
+		msg.ReadFile(e, "error")
+	}
+
+	return entries
+}
+
+// scores copies the search engine values written by MSFragger and Comet on their pin files
+func (p PinEntry) scores(psm *PeptideIdentification) {
+
+	for k, v := range p.Features {
+		switch k {
+		case "retentiontime":
+			// MSFragger writes the retention time in minutes
+			psm.RetentionTime = v * 60
+		case "hyperscore":
+			psm.Hyperscore = v
+		case "log10_evalue":
+			psm.Expectation = math.Pow(10, v)
+		case "lnexpect":
+			psm.Expectation = math.Exp(v)
+		case "xcorr":
+			psm.Xcorr = v
+		case "deltcn":
+			psm.DeltaCN = v
+		case "ntt":
+			psm.NumberOfEnzymaticTermini = uint8(v)
+		case "nmc", "enzint":
+			psm.NumberofMissedCleavages = uint8(v)
+		case "ion_mobility":
+			psm.IonMobility = v
+		}
+	}
+
+	if delta, ok := p.Features["delta_hyperscore"]; ok {
+		psm.Nextscore = psm.Hyperscore - delta
+	}
+}
+
+// ParseModifiedPeptide reads a peptide with flanking residues and bracketed mass shifts, like
+// K.n[42.0106]PEPM[15.9949]TIDE.R, the shifts before the first residue belong to the N-terminus
+func ParseModifiedPeptide(s string) (ModifiedPeptide, error) {
+
+	p := ModifiedPeptide{Shifts: make(map[int]float64)}
+
+	if match := flankedPeptideRG.FindStringSubmatch(s); match != nil {
+		p.PrevAA, s, p.NextAA = match[1], match[2], match[3]
+	}
+
+	var seq strings.Builder
+	var terminus byte
+
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+
+		switch {
+		case c >= 'A' && c <= 'Z':
+			seq.WriteByte(c)
+			terminus = 0
+
+		case c == 'n' || c == 'c':
+			terminus = c
+
+		case c == '-':
+			if seq.Len() > 0 {
+				terminus = 'c'
+			}
+
+		case c == '[' || c == '(':
+
+			end := strings.IndexAny(s[i:], "])")
+			if end < 0 {
+				return p, fmt.Errorf("the peptide %s has an unclosed modification", s)
+			}
+
+			value, e := strconv.ParseFloat(s[i+1:i+end], 64)
+			if e != nil {
+				return p, fmt.Errorf("the modification %s of peptide %s is not a mass shift", s[i+1:i+end], s)
+			}
+
+			if terminus == 'n' || seq.Len() == 0 {
+				p.NTerm += value
+			} else if terminus == 'c' {
+				p.CTerm += value
+			} else {
+				p.Shifts[seq.Len()] += value
+			}
+
+			i += end
+
+		default:
+			return p, fmt.Errorf("unexpected character %q on peptide %s", c, s)
+		}
+	}
+
+	p.Sequence = seq.String()
+
+	if len(p.Sequence) == 0 {
+		return p, fmt.Errorf("the peptide %s has no sequence", s)
+	}
+
+	return p, nil
+}
+
+// ParseResidueMassPeptide reads a peptide where the brackets hold the mass of the modified residue or
+// terminus, like n[43.0184]PEPM[147.0354]TIDE, as MSFragger writes them, and converts them to mass shifts
+func ParseResidueMassPeptide(s string) (ModifiedPeptide, error) {
+
+	p, e := ParseModifiedPeptide(s)
+	if e != nil {
+		return p, e
+	}
+
+	// the masses are written with four decimals
+	round := func(v float64) float64 { return math.Round(v*1e4) / 1e4 }
+
+	for k, v := range p.Shifts {
+
+		residue, ok := bio.PeptideMass(p.Sequence[k-1 : k])
+		if !ok {
+			return p, fmt.Errorf("the modified residue %c of peptide %s has no defined mass", p.Sequence[k-1], s)
+		}
+
+		p.Shifts[k] = round(v - (residue - bio.Water))
+	}
+
+	if p.NTerm != 0 {
+		p.NTerm = round(p.NTerm - hydrogenMass)
+	}
+
+	if p.CTerm != 0 {
+		p.CTerm = round(p.CTerm - hydroxylMass)
+	}
+
+	return p, nil
+}
+
+// Mass is the neutral monoisotopic mass of the modified peptide
+func (p ModifiedPeptide) Mass() (float64, bool) {
+
+	mass, ok := bio.PeptideMass(p.Sequence)
+
+	for _, v := range p.Shifts {
+		mass += v
+	}
+
+	return mass + p.NTerm + p.CTerm, ok
+}
+
+// modificationInfo writes the mass shifts the way pepXML does, with the modified residue and terminal
+// masses, and registers them on the modifications index
+func (p ModifiedPeptide) modificationInfo(mods mod.Modifications) spc.ModificationInfo {
+
+	var info spc.ModificationInfo
+	var modified strings.Builder
+
+	if p.NTerm != 0 {
+		info.ModNTermMass = hydrogenMass + p.NTerm
+		modified.WriteString(fmt.Sprintf("n[%.0f]", info.ModNTermMass))
+		registerModification(mods, "N-term", info.ModNTermMass, p.NTerm, p.Unimod[0])
+	}
+
+	for i := 0; i < len(p.Sequence); i++ {
+
+		aa := p.Sequence[i : i+1]
+		modified.WriteString(aa)
+
+		shift, ok := p.Shifts[i+1]
+		if !ok || shift == 0 {
+			continue
+		}
+
+		residue, _ := bio.PeptideMass(aa)
+		mass := residue - bio.Water + shift
+
+		info.ModAminoacidMass = append(info.ModAminoacidMass, spc.ModAminoacidMass{Position: i + 1, Mass: mass})
+		modified.WriteString(fmt.Sprintf("[%.0f]", mass))
+		registerModification(mods, aa, mass, shift, p.Unimod[i+1])
+	}
+
+	if p.CTerm != 0 {
+		info.ModCTermMass = hydroxylMass + p.CTerm
+		modified.WriteString(fmt.Sprintf("c[%.0f]", info.ModCTermMass))
+		registerModification(mods, "C-term", info.ModCTermMass, p.CTerm, p.Unimod[len(p.Sequence)+1])
+	}
+
+	// unmodified peptides have no modified sequence on pepXML
+	if info.ModNTermMass != 0 || info.ModCTermMass != 0 || len(info.ModAminoacidMass) > 0 {
+		info.ModifiedPeptide = []byte(modified.String())
+	}
+
+	return info
+}
+
+// registerModification adds a modification to the index with the same keys the pepXML search summary produces
+func registerModification(mods mod.Modifications, site string, mass, shift float64, unimod [2]string) {
+
+	key := fmt.Sprintf("%s#%.4f", site, mass)
+
+	if _, ok := mods.Index[key]; !ok {
+		mods.Index[key] = mod.Modification{
+			Index:     key,
+			ID:        unimod[0],
+			Name:      unimod[1],
+			Type:      mod.Assigned,
+			MassDiff:  uti.ToFixed(shift, 4),
+			Variable:  true,
+			AminoAcid: site,
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package id
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseModifiedPeptide(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		peptide       string
+		residueMasses bool
+		want          ModifiedPeptide
+		wantErr       bool
+	}{
+		{"flanked", "K.PEPTIDER.A", false, ModifiedPeptide{Sequence: "PEPTIDER", PrevAA: "K", NextAA: "A", Shifts: map[int]float64{}}, false},
+		{"residue", "-.PEPM[15.9949]K.-", false, ModifiedPeptide{Sequence: "PEPMK", PrevAA: "-", NextAA: "-", Shifts: map[int]float64{4: 15.9949}}, false},
+		{"terminal", "n[42.0106]PEPTIDEKc[-0.9840]", false, ModifiedPeptide{Sequence: "PEPTIDEK", NTerm: 42.0106, CTerm: -0.984, Shifts: map[int]float64{}}, false},
+		{"leading shift", "[+42.0106]-AC[+57.0215]K", false, ModifiedPeptide{Sequence: "ACK", NTerm: 42.0106, Shifts: map[int]float64{2: 57.0215}}, false},
+		{"MSFragger residue masses", "K.n[43.0184]PEPM[147.0354]C[160.0307]K.A", true, ModifiedPeptide{Sequence: "PEPMCK", PrevAA: "K", NextAA: "A", NTerm: 42.0106, Shifts: map[int]float64{4: 15.9949, 5: 57.0215}}, false},
+		{"MSFragger C-terminus", "PEPTIDEKc[17.0027]", true, ModifiedPeptide{Sequence: "PEPTIDEK", Shifts: map[int]float64{}}, false},
+		{"unimod", "PEPM[UNIMOD:35]K", false, ModifiedPeptide{}, true},
+		{"unclosed", "PEPM[15.99", false, ModifiedPeptide{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			parse := ParseModifiedPeptide
+			if tt.residueMasses {
+				parse = ParseResidueMassPeptide
+			}
+
+			got, e := parse(tt.peptide)
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("ParseModifiedPeptide() error = %v, wantErr %v", e, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got.Sequence != tt.want.Sequence || got.PrevAA != tt.want.PrevAA || got.NextAA != tt.want.NextAA || got.NTerm != tt.want.NTerm || got.CTerm != tt.want.CTerm || len(got.Shifts) != len(tt.want.Shifts) {
+				t.Errorf("ParseModifiedPeptide() = %+v, want %+v", got, tt.want)
+			}
+
+			for k, v := range tt.want.Shifts {
+				if got.Shifts[k] != v {
+					t.Errorf("Shift at %d is incorrect, got %v, want %v", k, got.Shifts[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestReadPercolator(t *testing.T) {
+
+	dir := t.TempDir()
+
+	results := filepath.Join(dir, "target.psms.txt")
+	pout := "PSMId\tscore\tq-value\tposterior_error_prob\tpeptide\tproteinIds\n" +
+		"sample.01234.01234.2_1\t1.5\t0.001\t0.02\tK.PEPM[147.0354]K.A\tsp|P1|A\tsp|P2|B\n" +
+		"sample_2000_3_1\t-0.5\t0.2\t0.9\t-.n[42.0106]ACDEK.-\trev_sp|P3|C\n"
+
+	if e := os.WriteFile(results, []byte(pout), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	pin := "SpecId\tLabel\tScanNr\tExpMass\tretentiontime\thyperscore\tdelta_hyperscore\tcharge_1\tcharge_2\tPeptide\tProteins\n" +
+		"sample.01234.01234.2_1\t1\t1234\t617.2800\t20.5\t30\t10\t0\t1\tK.PEPM[147.0354]K.A\tsp|P1|A\n"
+
+	features := make(map[string]PinEntry)
+	f := filepath.Join(dir, "sample.pin")
+	if e := os.WriteFile(f, []byte(pin), 0644); e != nil {
+		t.Fatal(e)
+	}
+	for k, v := range ReadPin(f) {
+		features[k] = v
+	}
+
+	p := ReadPercolator([]string{results}, "rev_", features)
+
+	if len(p.PeptideIdentification) != 2 {
+		t.Fatalf("PSM number is incorrect, got %d, want 2", len(p.PeptideIdentification))
+	}
+
+	first := p.PeptideIdentification[0]
+	if first.Spectrum != "sample.01234.01234.2" || first.AssumedCharge != 2 || first.Peptide != "PEPMK" || first.Protein != "sp|P1|A" || len(first.AlternativeProteins) != 1 {
+		t.Errorf("First PSM is incorrect, got %+v", first)
+	}
+
+	if math.Abs(first.Probability-0.98) > 1e-9 || first.RetentionTime != 1230 || first.Hyperscore != 30 || first.Nextscore != 20 || first.PrecursorNeutralMass != 617.28 {
+		t.Errorf("First PSM scores are incorrect, got %+v", first)
+	}
+
+	if first.ModifiedPeptide != "PEPM[147]K" || len(first.Modifications.IndexSlice) == 0 {
+		t.Errorf("First PSM modifications are incorrect, got %s and %+v", first.ModifiedPeptide, first.Modifications)
+	}
+
+	second := p.PeptideIdentification[1]
+	if second.Spectrum != "sample.02000.02000.3" || second.ModifiedPeptide != "n[43]ACDEK" || second.Protein != "rev_sp|P3|C" {
+		t.Errorf("Second PSM is incorrect, got %+v", second)
+	}
+
+	if _, ok := p.Modifications.Index["M#147.0354"]; !ok {
+		t.Errorf("Modification index is incorrect, got %v", p.Modifications.Index)
+	}
+
+	if _, ok := p.Modifications.Index["N-term#43.0184"]; !ok {
+		t.Errorf("Modification index is incorrect, got %v", p.Modifications.Index)
+	}
+}
@@ -54,6 +54,8 @@ type ProteinIdentification struct {
 	TopPepProb               float64
 	PeptideIons              []PeptideIonIdentification
 	HasRazor                 bool
+	QValue                   float64
+	PEP                      float64
 }
 
 // PeptideIonIdentification struct
@@ -0,0 +1,192 @@
+package id
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/mod"
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/psi"
+	"github.com/Nesvilab/philosopher/lib/uti"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sageResults is the name of the PSM table Sage writes
+const sageResults = "results.sage.tsv"
+
+// ReadSageInput reads the Sage PSM table and organizes the data into a PSM list, the input is the
+// results.sage.tsv file or the folder Sage wrote it to
+func ReadSageInput(input, decoyTag string) (PepIDListPtrs, string) {
+
+	info, e := os.Stat(input)
+	if e != nil {
+		msg.InputNotFound(e, "error")
+	}
+
+	if info.IsDir() {
+		input = filepath.Join(input, sageResults)
+	}
+
+	p := ReadSage(input, decoyTag)
+
+	var pepXML PepXML4Serialiazation
+	pepXML.FileName = p.FileName
+	pepXML.SearchEngine = p.SearchEngine
+	pepXML.DecoyTag = decoyTag
+	pepXML.Prophet = p.Prophet
+	pepXML.Modifications = p.Modifications
+	pepXML.PeptideIdentification = ToPepIDListPtrs(p.PeptideIdentification)
+
+	pepXML.prepare()
+
+	return pepXML.PeptideIdentification, p.SearchEngine
+}
+
+// ReadSage parses the Sage PSM table, only the top ranked PSM of each spectrum is kept. Sage writes the
+// posterior error probabilities as log10 values, the probability of each PSM is 1 - PEP
+func ReadSage(f, decoyTag string) PepXML {
+
+	var p PepXML
+
+	p.FileName = filepath.Base(f)
+	p.SearchEngine = "Sage"
+	p.Prophet = "sage"
+	p.DecoyTag = decoyTag
+	p.Modifications.Index = make(map[string]mod.Modification)
+
+	logrus.Info("Parsing ", f)
+
+	e := readTSV(f, func(line int, row map[string]string) error {
+
+		if rank, e := strconv.Atoi(row["rank"]); e == nil && rank > 1 {
+			return nil
+		}
+
+		psm, e := sagePSM(row, decoyTag, p.Modifications)
+		if e != nil {
+			return e
+		}
+
+		psm.SpectrumFile = p.FileName
+		psm.Index = uint32(len(p.PeptideIdentification))
+
+		p.PeptideIdentification = append(p.PeptideIdentification, psm)
+
+		return nil
+	})
+	if e != nil {
+		msg.Custom(fmt.Errorf("%s: %w", filepath.Base(f), e), "error")
+	}
+
+	if len(p.PeptideIdentification) == 0 {
+		msg.NoPSMFound(fmt.Errorf("no PSMs found on %s", f), "error")
+	}
+
+	return p
+}
+
+// sagePSM maps a row of the Sage PSM table to a PSM
+func sagePSM(row map[string]string, decoyTag string, mods mod.Modifications) (PeptideIdentification, error) {
+
+	var psm PeptideIdentification
+	psm.AlternativeProteins = make(map[string]string)
+
+	// the scans are written as native IDs, or as numbers on the older versions
+	scan, e := strconv.Atoi(row["scannr"])
+	if e != nil {
+		scan, _ = mzIdentMLScan(row["scannr"], nil)
+	}
+
+	charge, e := strconv.ParseUint(row["charge"], 10, 8)
+	if e != nil || charge == 0 || scan == 0 || len(row["filename"]) == 0 {
+		return psm, fmt.Errorf("cannot find the spectrum file, scan and charge of the PSM %s", row["psm_id"])
+	}
+
+	source := spectraSource(psi.SpectraData{Location: row["filename"]})
+
+	psm.Spectrum = fmt.Sprintf("%s.%05d.%05d.%d", source, scan, scan, charge)
+	psm.AssumedCharge = uint8(charge)
+	psm.HitRank = 1
+
+	peptide, e := ParseModifiedPeptide(row["peptide"])
+	if e != nil {
+		return psm, e
+	}
+
+	for i, j := range strings.Split(row["proteins"], ";") {
+
+		protein := strings.TrimSpace(j)
+		if len(protein) == 0 {
+			continue
+		}
+
+		// decoys are recognized by their tag on the next steps
+		if row["label"] == "-1" && !strings.HasPrefix(protein, decoyTag) {
+			protein = decoyTag + protein
+		}
+
+		if i == 0 {
+			psm.Protein = protein
+		} else if protein != psm.Protein {
+			psm.AlternativeProteins[protein] = peptide.PrevAA + "#" + peptide.NextAA
+		}
+	}
+
+	if len(psm.Protein) == 0 {
+		return psm, fmt.Errorf("the PSM %s has no proteins", row["psm_id"])
+	}
+
+	psm.Peptide = peptide.Sequence
+	psm.PeptideLength = uint8(len(peptide.Sequence))
+
+	psm.NumberOfEnzymaticTermini = 2
+	if row["semi_enzymatic"] == "1" {
+		psm.NumberOfEnzymaticTermini = 1
+	}
+
+	if mc, e := strconv.Atoi(row["missed_cleavages"]); e == nil {
+		psm.NumberofMissedCleavages = uint8(mc)
+	}
+
+	psm.PrecursorNeutralMass, _ = strconv.ParseFloat(row["expmass"], 64)
+	psm.UncalibratedPrecursorNeutralMass = psm.PrecursorNeutralMass
+
+	psm.CalcNeutralPepMass, _ = peptide.Mass()
+	if mass, e := strconv.ParseFloat(row["calcmass"], 64); e == nil {
+		psm.CalcNeutralPepMass = mass
+	}
+	psm.Massdiff = uti.ToFixed(psm.PrecursorNeutralMass-psm.CalcNeutralPepMass, 4)
+
+	// Sage reports the retention times in minutes
+	if rt, e := strconv.ParseFloat(row["rt"], 64); e == nil {
+		psm.RetentionTime = rt * 60
+	}
+
+	psm.IonMobility, _ = strconv.ParseFloat(row["ion_mobility"], 64)
+
+	if score, e := strconv.ParseFloat(row["hyperscore"], 64); e == nil {
+		psm.Hyperscore = score
+		if delta, e := strconv.ParseFloat(row["delta_next"], 64); e == nil {
+			psm.Nextscore = score - delta
+		}
+	}
+
+	if poisson, e := strconv.ParseFloat(row["poisson"], 64); e == nil {
+		psm.Expectation = math.Pow(10, poisson)
+	}
+
+	pep, e := strconv.ParseFloat(row["posterior_error"], 64)
+	if e != nil {
+		return psm, fmt.Errorf("the posterior error %s of PSM %s is not valid", row["posterior_error"], row["psm_id"])
+	}
+	psm.Probability = 1 - math.Min(math.Pow(10, pep), 1)
+
+	psm.mapModsFromPepXML(peptide.modificationInfo(mods), mods)
+
+	return psm, nil
+}
@@ -0,0 +1,46 @@
+package id
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadSage(t *testing.T) {
+
+	f := filepath.Join(t.TempDir(), "results.sage.tsv")
+
+	tsv := "psm_id\tpeptide\tproteins\tnum_proteins\tfilename\tscannr\trank\tlabel\texpmass\tcalcmass\tcharge\tpeptide_len\tmissed_cleavages\tsemi_enzymatic\thyperscore\tdelta_next\trt\tion_mobility\tposterior_error\tpoisson\n" +
+		"1\t[+42.0106]-PEPM[+15.9949]K\tsp|P1|A;sp|P2|B\t2\t/data/sample.mzML\tcontrollerType=0 controllerNumber=1 scan=1234\t1\t1\t677.30\t677.29\t2\t5\t0\t0\t30\t10\t20.5\t0\t-2\t-5\n" +
+		"2\tPEPTIDER\tsp|P1|A\t1\t/data/sample.mzML\tcontrollerType=0 controllerNumber=1 scan=1234\t2\t1\t677.30\t955.45\t2\t8\t0\t0\t20\t0\t20.5\t0\t0\t-1\n" +
+		"3\tACDEK\tsp|P3|C\t1\t/data/sample.mzML\t2000\t1\t-1\t580.20\t580.21\t3\t5\t0\t1\t5\t1\t30\t0.9\t0\t-1\n"
+
+	if e := os.WriteFile(f, []byte(tsv), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	p := ReadSage(f, "rev_")
+
+	if len(p.PeptideIdentification) != 2 {
+		t.Fatalf("PSM number is incorrect, got %d, want 2", len(p.PeptideIdentification))
+	}
+
+	first := p.PeptideIdentification[0]
+	if first.Spectrum != "sample.01234.01234.2" || first.Peptide != "PEPMK" || first.Protein != "sp|P1|A" || len(first.AlternativeProteins) != 1 {
+		t.Errorf("First PSM is incorrect, got %+v", first)
+	}
+
+	if math.Abs(first.Probability-0.99) > 1e-9 || first.RetentionTime != 1230 || first.Hyperscore != 30 || first.Nextscore != 20 || math.Abs(first.Expectation-1e-5) > 1e-12 || first.Massdiff != 0.01 {
+		t.Errorf("First PSM scores are incorrect, got %+v", first)
+	}
+
+	if first.ModifiedPeptide != "n[43]PEPM[147]K" || first.NumberOfEnzymaticTermini != 2 {
+		t.Errorf("First PSM modifications are incorrect, got %s", first.ModifiedPeptide)
+	}
+
+	second := p.PeptideIdentification[1]
+	if second.Spectrum != "sample.02000.02000.3" || second.Protein != "rev_sp|P3|C" || second.Probability != 0 || second.NumberOfEnzymaticTermini != 1 {
+		t.Errorf("Second PSM is incorrect, got %+v", second)
+	}
+}
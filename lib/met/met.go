@@ -51,6 +51,9 @@ type Data struct {
 	TMTIntegrator  TMTIntegrator
 	Index          Index
 	Pipeline       Pipeline
+	Genome         Genome
+	CheckSpectra   CheckSpectra
+	Sage           Sage
 }
 
 // Msconvert options and parameters
@@ -61,6 +64,16 @@ type Msconvert struct {
 	IntensityBinaryEncoding string
 	NoIndex                 bool
 	Zlib                    bool
+	MSLevel                 []int
+	PeakPicking             bool
+	PeakApex                string
+	SignalToNoise           float64
+}
+
+// CheckSpectra options and parameters
+type CheckSpectra struct {
+	Dir     string
+	MSLevel []int
 }
 
 // Idconvert optioons and parameters
@@ -70,19 +83,34 @@ type Idconvert struct {
 
 // Database options and parameters
 type Database struct {
-	ID        string `yaml:"id"`
-	Annot     string `yaml:"protein_database"`
-	Enz       string `yaml:"enzyme"`
-	Tag       string `yaml:"decoy_tag"`
-	Add       string `yaml:"add"`
-	Custom    string `yaml:"custom"`
-	TimeStamp string `yaml:"timestamp"`
-	Crap      bool   `yaml:"contam"`
-	CrapTag   bool   `yaml:"contaminant_tag"`
-	Rev       bool   `yaml:"reviewed"`
-	Iso       bool   `yaml:"isoform"`
-	NoD       bool   `yaml:"nodecoys"`
-	Verbose   bool   `yaml:"verbose"`
+	ID              string  `yaml:"id"`
+	Annot           string  `yaml:"protein_database"`
+	Enz             string  `yaml:"enzyme"`
+	EnzymeFile      string  `yaml:"enzyme_file"`
+	HeaderFile      string  `yaml:"header_file"`
+	HeaderFormat    string  `yaml:"header_format"`
+	Variants        string  `yaml:"variants"`
+	Entrapment      string  `yaml:"entrapment"`
+	EntrapTag       string  `yaml:"entrapment_tag"`
+	Tag             string  `yaml:"decoy_tag"`
+	DecoyMethod     string  `yaml:"decoy_method"`
+	Add             string  `yaml:"add"`
+	Custom          string  `yaml:"custom"`
+	ContamFile      string  `yaml:"contam_file"`
+	UniProtFile     string  `yaml:"uniprot_file"`
+	Diff            string  `yaml:"diff"`
+	TimeStamp       string  `yaml:"timestamp"`
+	DecoySeed       int64   `yaml:"decoy_seed"`
+	DecoyCollisions int     `yaml:"decoy_collisions"`
+	EntrapmentRatio float64 `yaml:"entrapment_ratio"`
+	Crap            bool    `yaml:"contam"`
+	CrapTag         bool    `yaml:"contaminant_tag"`
+	Rev             bool    `yaml:"reviewed"`
+	Iso             bool    `yaml:"isoform"`
+	NoD             bool    `yaml:"nodecoys"`
+	Verbose         bool    `yaml:"verbose"`
+	DebugHeaders    bool    `yaml:"debug_headers"`
+	Stats           bool    `yaml:"stats"`
 }
 
 // Comet options and parameters
@@ -95,6 +123,41 @@ type Comet struct {
 	NoIndex      bool `yaml:"noindex"`
 }
 
+// Sage options and parameters, the empty values are left to the Sage defaults
+type Sage struct {
+	BinPath           string               `yaml:"path"`
+	Extension         string               `yaml:"extension"`
+	DatabaseName      string               `yaml:"database_name"`
+	DecoyPrefix       string               `yaml:"decoy_prefix"`
+	GenerateDecoys    bool                 `yaml:"generate_decoys"`
+	Enzyme            string               `yaml:"enzyme"`
+	MissedCleavages   int                  `yaml:"missed_cleavages"`
+	MinLength         int                  `yaml:"min_length"`
+	MaxLength         int                  `yaml:"max_length"`
+	SemiEnzymatic     bool                 `yaml:"semi_enzymatic"`
+	MinPeptideMass    float64              `yaml:"peptide_min_mass"`
+	MaxPeptideMass    float64              `yaml:"peptide_max_mass"`
+	StaticMods        map[string]float64   `yaml:"static_mods"`
+	VariableMods      map[string][]float64 `yaml:"variable_mods"`
+	MaxVariableMods   int                  `yaml:"max_variable_mods"`
+	PrecursorTol      []float64            `yaml:"precursor_tol"`
+	PrecursorTolUnits string               `yaml:"precursor_tol_units"`
+	FragmentTol       []float64            `yaml:"fragment_tol"`
+	FragmentTolUnits  string               `yaml:"fragment_tol_units"`
+	IsotopeErrors     []int                `yaml:"isotope_errors"`
+	MinPeaks          int                  `yaml:"min_peaks"`
+	MaxPeaks          int                  `yaml:"max_peaks"`
+	MinMatchedPeaks   int                  `yaml:"min_matched_peaks"`
+	MaxFragmentCharge int                  `yaml:"max_fragment_charge"`
+	ReportPSMs        int                  `yaml:"report_psms"`
+	Deisotope         bool                 `yaml:"deisotope"`
+	Chimera           bool                 `yaml:"chimera"`
+	WideWindow        bool                 `yaml:"wide_window"`
+	NoPredictRT       bool                 `yaml:"no_predict_rt"`
+	RawFiles          []string
+	ParamFile         []byte
+}
+
 // MSFragger options and parameters
 type MSFragger struct {
 	JarPath                            string `yaml:"path"`
@@ -339,29 +402,35 @@ type PTMProphet struct {
 
 // Filter options and parameters
 type Filter struct {
-	Pex       string  `yaml:"pepxml"`
-	Pox       string  `yaml:"protxml"`
-	Tag       string  `yaml:"tag"`
-	Mods      string  `yaml:"mods"`
-	ProBin    string  `yaml:"probin"`
-	DbBin     string  `yaml:"dbbin"`
-	PsmFDR    float64 `yaml:"psmFDR"`
-	PepFDR    float64 `yaml:"peptideFDR"`
-	IonFDR    float64 `yaml:"ionFDR"`
-	PtFDR     float64 `yaml:"proteinFDR"`
-	ProtProb  float64 `yaml:"proteinProbability"`
-	PepProb   float64 `yaml:"peptideProbability"`
-	Weight    float64 `yaml:"peptideWeight"`
-	Model     bool    `yaml:"models"`
-	Razor     bool    `yaml:"razor"`
-	Picked    bool    `yaml:"picked"`
-	Seq       bool    `yaml:"sequential"`
-	TwoD      bool    `yaml:"two-dimensional"`
-	Mapmods   bool    `yaml:"mapMods"`
-	Delta     bool    `yaml:"delta"`
-	Inference bool    `yaml:"inference"`
-	Group     bool    `yaml:"group"`
-	MinPepLen int     `yaml:"minPepLen"`
+	Pex        string  `yaml:"pepxml"`
+	Pox        string  `yaml:"protxml"`
+	Tag        string  `yaml:"tag"`
+	Mods       string  `yaml:"mods"`
+	ProBin     string  `yaml:"probin"`
+	DbBin      string  `yaml:"dbbin"`
+	PsmFDR     float64 `yaml:"psmFDR"`
+	PepFDR     float64 `yaml:"peptideFDR"`
+	IonFDR     float64 `yaml:"ionFDR"`
+	PtFDR      float64 `yaml:"proteinFDR"`
+	ProtProb   float64 `yaml:"proteinProbability"`
+	PepProb    float64 `yaml:"peptideProbability"`
+	Weight     float64 `yaml:"peptideWeight"`
+	Model      bool    `yaml:"models"`
+	Razor      bool    `yaml:"razor"`
+	Picked     bool    `yaml:"picked"`
+	Seq        bool    `yaml:"sequential"`
+	TwoD       bool    `yaml:"two-dimensional"`
+	Mapmods    bool    `yaml:"mapMods"`
+	Delta      bool    `yaml:"delta"`
+	Inference  bool    `yaml:"inference"`
+	Group      bool    `yaml:"group"`
+	MinPepLen  int     `yaml:"minPepLen"`
+	Percolator string  `yaml:"percolator"`
+	MzID       string  `yaml:"mzid"`
+	MaxQuant   string  `yaml:"maxquant"`
+	Sage       string  `yaml:"sage"`
+	Rescore    bool    `yaml:"rescore"`
+	ErrorRates bool    `yaml:"errorRates"`
 }
 
 // Quantify options and parameters
@@ -388,6 +457,8 @@ type Quantify struct {
 	Raw        bool    `yaml:"raw"`
 	Faims      bool    `yaml:"faims"`
 	LabelNames map[string]string
+	PeakApex   string  `yaml:"peakApex"`
+	SNR        float64 `yaml:"signalToNoise"`
 }
 
 // Abacus options ad parameters
@@ -436,6 +507,13 @@ type Index struct {
 	Spectra string
 }
 
+// Genome options and parameters
+type Genome struct {
+	Annotation string
+	Fasta      string
+	Tag        string
+}
+
 // Pipeline options and parameters
 type Pipeline struct {
 	Directives string
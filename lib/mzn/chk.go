@@ -0,0 +1,293 @@
+package mzn
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/psi"
+)
+
+var fileChecksumRG = regexp.MustCompile(`<fileChecksum>\s*([0-9a-fA-F]+)\s*</fileChecksum>`)
+var scanNumberRG = regexp.MustCompile(`^\d+$`)
+
+// Check is the outcome of one of the integrity tests of a spectra file
+type Check struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// Integrity is the report of a spectra file check
+type Integrity struct {
+	FileName string
+	Checks   []Check
+	// Levels counts the spectra of each MS level
+	Levels map[string]int
+	// Scans maps the scan numbers to their MS level
+	Scans map[string]string
+}
+
+// Passed is true when every check of the file passed
+func (g Integrity) Passed() bool {
+
+	for _, i := range g.Checks {
+		if !i.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// tally counts the spectra failing a check and keeps the first of them as an example
+type tally struct {
+	count   int
+	example string
+}
+
+func (t *tally) add(id, reason string) {
+	if t.count == 0 {
+		t.example = fmt.Sprintf("%s (%s)", id, reason)
+	}
+	t.count++
+}
+
+func (t tally) check(name string, total int, detail string) Check {
+
+	if t.count == 0 {
+		return Check{Name: name, Passed: true, Detail: detail}
+	}
+
+	return Check{Name: name, Detail: fmt.Sprintf("%d of %d spectra failed, the first is %s", t.count, total, t.example)}
+}
+
+// CheckMzML tests the integrity of a mzML file and its compatibility with the quantification steps,
+// levels are the MS levels the file is expected to have. Problems are reported instead of stopping
+// the program, so all the files of a data set are checked in one pass
+func CheckMzML(f string, levels []string) Integrity {
+
+	g := Integrity{FileName: f, Levels: make(map[string]int), Scans: make(map[string]string)}
+
+	file, e := os.Open(f)
+	if e != nil {
+		g.Checks = append(g.Checks, Check{Name: "file", Detail: e.Error()})
+		return g
+	}
+	defer file.Close()
+
+	info, e := file.Stat()
+	if e != nil {
+		g.Checks = append(g.Checks, Check{Name: "file", Detail: e.Error()})
+		return g
+	}
+
+	r := &MzMLReader{FileName: f, file: file, size: info.Size()}
+
+	g.Checks = append(g.Checks, r.checkIndex(), r.checkChecksum())
+
+	if len(r.offsets) == 0 {
+		g.Checks = append(g.Checks, Check{Name: "spectra", Detail: "no spectra found"})
+		return g
+	}
+
+	software := Check{Name: "software", Passed: true, Detail: "supported"}
+	if r.deprecatedSoftware() {
+		software = Check{Name: "software", Detail: "converted with a deprecated msconvert version"}
+	}
+	g.Checks = append(g.Checks, software)
+
+	var parsing, arrays, scans, precursors tally
+
+	for i := range r.offsets {
+
+		id := r.ids[i]
+		if len(id) == 0 {
+			id = fmt.Sprintf("spectrum %d", i)
+		}
+
+		spec, e := r.parse(i)
+		if e == nil {
+			e = spectrumLayout(spec)
+		}
+
+		if e != nil {
+			parsing.add(id, e.Error())
+			continue
+		}
+
+		s, encoding := processSpectrum(spec)
+		g.Levels[s.Level]++
+
+		// freequant names the spectra as <file>.<scan>.<scan>.<charge>, the scan must be a unique number
+		if !scanNumberRG.MatchString(s.Scan) {
+			scans.add(id, fmt.Sprintf("scan %s is not a number", s.Scan))
+		} else if _, ok := g.Scans[s.Scan]; ok {
+			scans.add(id, fmt.Sprintf("scan %s is repeated", s.Scan))
+		} else {
+			g.Scans[s.Scan] = s.Level
+		}
+
+		if encoding != nil {
+			arrays.add(id, encoding.Error())
+		} else if e := checkArrays(s, int(spec.DefaultArrayLength)); e != nil {
+			arrays.add(id, e.Error())
+		}
+
+		if len(s.Level) > 0 && s.Level != "1" {
+			if spec.PrecursorList == nil || len(spec.PrecursorList.Precursor) == 0 || s.Precursor.SelectedIon == 0 {
+				precursors.add(id, "no precursor m/z")
+			} else if s.Precursor.TargetIon == 0 && s.Precursor.IsolationWindowLowerOffset == 0 && s.Precursor.IsolationWindowUpperOffset == 0 {
+				precursors.add(id, "no isolation window")
+			}
+		}
+	}
+
+	total := len(r.offsets)
+
+	g.Checks = append(g.Checks,
+		parsing.check("parsing", total, fmt.Sprintf("%d spectra", total)),
+		arrays.check("arrays", total, "m/z and intensity lengths match"),
+		scans.check("scans", total, "unique scan numbers"),
+		g.checkLevels(levels),
+		precursors.check("precursors", total, "precursor and isolation window found"),
+	)
+
+	return g
+}
+
+// checkIndex tests that the index points to the spectra, a file without index is valid, but slower to read
+func (r *MzMLReader) checkIndex() Check {
+
+	if r.readIndex() && r.validIndex() {
+		return Check{Name: "index", Passed: true, Detail: fmt.Sprintf("%d spectrum offsets", len(r.offsets))}
+	}
+
+	tail, _ := r.tail()
+	c := Check{Name: "index", Passed: true, Detail: "not indexed"}
+	if indexListOffsetRG.Match(tail) {
+		c = Check{Name: "index", Detail: "the offsets do not point to the spectra"}
+	}
+
+	if e := r.scanIndex(); e != nil {
+		c = Check{Name: "index", Detail: fmt.Sprintf("the file is truncated or malformed: %s", e)}
+	}
+
+	return c
+}
+
+// checkChecksum compares the SHA-1 of the file up to the checksum tag with the declared value
+func (r *MzMLReader) checkChecksum() Check {
+
+	tail, start := r.tail()
+
+	loc := fileChecksumRG.FindSubmatchIndex(tail)
+	if loc == nil {
+		return Check{Name: "checksum", Passed: true, Detail: "no file checksum"}
+	}
+
+	end := start + int64(loc[0]+len("<fileChecksum>"))
+
+	h := sha1.New()
+	if _, e := io.Copy(h, io.NewSectionReader(r.file, 0, end)); e != nil {
+		return Check{Name: "checksum", Detail: e.Error()}
+	}
+
+	got := fmt.Sprintf("%x", h.Sum(nil))
+	want := strings.ToLower(string(tail[loc[2]:loc[3]]))
+
+	if got != want {
+		return Check{Name: "checksum", Detail: fmt.Sprintf("the file SHA-1 is %s, %s expected", got, want)}
+	}
+
+	return Check{Name: "checksum", Passed: true, Detail: "SHA-1 matches"}
+}
+
+// checkLevels tests that all the expected MS levels have spectra
+func (g Integrity) checkLevels(levels []string) Check {
+
+	var found []string
+	for i := range g.Levels {
+		found = append(found, i)
+	}
+	sort.Strings(found)
+
+	var counts []string
+	for _, i := range found {
+		counts = append(counts, fmt.Sprintf("MS%s %d", i, g.Levels[i]))
+	}
+
+	var missing []string
+	for _, i := range levels {
+		if g.Levels[i] == 0 {
+			missing = append(missing, "MS"+i)
+		}
+	}
+
+	if len(missing) > 0 {
+		return Check{Name: "levels", Detail: fmt.Sprintf("no %s spectra, found %s", strings.Join(missing, ", "), strings.Join(counts, ", "))}
+	}
+
+	return Check{Name: "levels", Passed: true, Detail: strings.Join(counts, ", ")}
+}
+
+// spectrumLayout checks the elements processSpectrum relies on
+func spectrumLayout(spec psi.Spectrum) error {
+
+	if len(spec.ScanList.Scan) == 0 {
+		return errors.New("no scan element")
+	}
+
+	if arrays := len(spec.BinaryDataArrayList.BinaryDataArray); arrays < 2 || arrays < spec.BinaryDataArrayList.Count {
+		return errors.New("missing binary arrays")
+	}
+
+	if spec.PrecursorList != nil && len(spec.PrecursorList.Precursor) > 0 {
+
+		p := spec.PrecursorList.Precursor[0]
+
+		if len(p.SpectrumRef) > 0 && !strings.Contains(p.SpectrumRef, "scan=") {
+			return fmt.Errorf("the precursor reference %s has no scan number", p.SpectrumRef)
+		}
+
+		if len(p.SelectedIonList.SelectedIon) == 0 {
+			return errors.New("no selected ion")
+		}
+	}
+
+	return nil
+}
+
+// checkArrays decodes the binary arrays of a spectrum and compares their lengths with the declared one
+func checkArrays(s Spectrum, length int) error {
+
+	mz, e := decodeBinary(s.Mz.Stream, s.Mz.Precision, s.Mz.Compression)
+	if e != nil {
+		return fmt.Errorf("m/z array: %w", e)
+	}
+
+	intensity, e := decodeBinary(s.Intensity.Stream, s.Intensity.Precision, s.Intensity.Compression)
+	if e != nil {
+		return fmt.Errorf("intensity array: %w", e)
+	}
+
+	if len(mz) != length || len(intensity) != length {
+		return fmt.Errorf("%d m/z and %d intensity values, %d declared", len(mz), len(intensity), length)
+	}
+
+	if len(s.IonMobility.Stream) > 0 {
+
+		// the reader skips the additional arrays it cannot decode, so they are not a failure
+		im, e := decodeBinary(s.IonMobility.Stream, s.IonMobility.Precision, s.IonMobility.Compression)
+		if e == nil && len(im) != length {
+			return fmt.Errorf("%d ion mobility values, %d declared", len(im), length)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,302 @@
+package mzn
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/psi"
+	"github.com/sirupsen/logrus"
+)
+
+// tailSize is the number of bytes read from the end of the file to find the index offset
+const tailSize = 4096
+
+var indexListOffsetRG = regexp.MustCompile(`<indexListOffset>\s*(\d+)\s*</indexListOffset>`)
+var nativeScanRG = regexp.MustCompile(`scan=(\d+)`)
+
+// MzMLReader gives streaming and random access to the spectra of a mzML file. Spectra are located with
+// the byte offsets of the mzML index and parsed one at a time, the binary arrays are kept encoded
+// until Decode is called
+type MzMLReader struct {
+	FileName string
+	file     *os.File
+	size     int64
+	offsets  []int64
+	ids      []string
+	scans    map[string]int
+	next     int
+}
+
+// NewMzMLReader opens a mzML file and loads its spectrum index, the index is rebuilt by scanning the
+// file when it is missing or does not point to the spectra
+func NewMzMLReader(f string) *MzMLReader {
+
+	file, e := os.Open(f)
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	info, e := file.Stat()
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	r := &MzMLReader{FileName: f, file: file, size: info.Size()}
+
+	if !r.readIndex() || !r.validIndex() {
+		logrus.Warning("The mzML index is missing or broken, rebuilding it from ", f)
+		r.rebuildIndex()
+	}
+
+	if len(r.offsets) == 0 {
+		msg.NoSpectraFound(errors.New(""), "error")
+	}
+
+	r.scans = make(map[string]int)
+	for i, id := range r.ids {
+		scan := strconv.Itoa(i + 1)
+		if match := nativeScanRG.FindStringSubmatch(id); match != nil {
+			scan = match[1]
+		}
+		r.scans[scan] = i
+	}
+
+	r.checkSoftware()
+
+	return r
+}
+
+// Close releases the mzML file
+func (r *MzMLReader) Close() {
+	r.file.Close()
+}
+
+// Len returns the number of spectra on the file
+func (r *MzMLReader) Len() int {
+	return len(r.offsets)
+}
+
+// Reset moves the stream back to the first spectrum
+func (r *MzMLReader) Reset() {
+	r.next = 0
+}
+
+// Next returns the following spectrum of the stream, the boolean is false when the stream is over
+func (r *MzMLReader) Next() (Spectrum, bool) {
+
+	if r.next >= len(r.offsets) {
+		return Spectrum{}, false
+	}
+
+	s := r.Spectrum(r.next)
+	r.next++
+
+	return s, true
+}
+
+// Spectrum returns the spectrum at the given position of the index
+func (r *MzMLReader) Spectrum(i int) Spectrum {
+
+	spec, e := r.parse(i)
+	if e != nil {
+		msg.Custom(e, "fatal")
+	}
+
+	s, e := processSpectrum(spec)
+	if e != nil {
+		msg.Custom(e, "fatal")
+	}
+
+	return s
+}
+
+// parse decodes the spectrum element at the given position of the index
+func (r *MzMLReader) parse(i int) (psi.Spectrum, error) {
+
+	var spec psi.Spectrum
+
+	decoder := xml.NewDecoder(io.NewSectionReader(r.file, r.offsets[i], r.size-r.offsets[i]))
+	if e := decoder.Decode(&spec); e != nil {
+		return spec, fmt.Errorf("cannot parse spectrum %d from %s: %w", i, r.FileName, e)
+	}
+
+	return spec, nil
+}
+
+// Scan fetches a spectrum by the scan number of its native ID
+func (r *MzMLReader) Scan(scan string) (Spectrum, bool) {
+
+	i, ok := r.scans[strings.TrimLeft(scan, "0")]
+	if !ok {
+		return Spectrum{}, false
+	}
+
+	return r.Spectrum(i), true
+}
+
+// readIndex parses the spectrum offsets from the index list at the end of the file
+func (r *MzMLReader) readIndex() bool {
+
+	tail, _ := r.tail()
+
+	match := indexListOffsetRG.FindSubmatch(tail)
+	if match == nil {
+		return false
+	}
+
+	offset, e := strconv.ParseInt(string(match[1]), 10, 64)
+	if e != nil || offset <= 0 || offset >= r.size {
+		return false
+	}
+
+	var list psi.IndexList
+	decoder := xml.NewDecoder(io.NewSectionReader(r.file, offset, r.size-offset))
+	if e := decoder.Decode(&list); e != nil {
+		return false
+	}
+
+	for _, i := range list.Index {
+		if i.Name != "spectrum" {
+			continue
+		}
+		for _, j := range i.Offset {
+			r.offsets = append(r.offsets, j.Value)
+			r.ids = append(r.ids, j.IDRef)
+		}
+	}
+
+	return len(r.offsets) > 0
+}
+
+// tail returns the last bytes of the file and their position
+func (r *MzMLReader) tail() ([]byte, int64) {
+
+	start := r.size - tailSize
+	if start < 0 {
+		start = 0
+	}
+
+	tail := make([]byte, r.size-start)
+	if _, e := r.file.ReadAt(tail, start); e != nil && e != io.EOF {
+		msg.ReadFile(e, "fatal")
+	}
+
+	return tail, start
+}
+
+// validIndex checks that every offset points to the start of a spectrum element
+func (r *MzMLReader) validIndex() bool {
+
+	tag := []byte("<spectrum")
+	buf := make([]byte, len(tag)+1)
+
+	for _, i := range r.offsets {
+
+		if i < 0 || i+int64(len(buf)) > r.size {
+			return false
+		}
+
+		if _, e := r.file.ReadAt(buf, i); e != nil {
+			return false
+		}
+
+		// the tag must not be the prefix of a longer element name, like spectrumList
+		if !bytes.HasPrefix(buf, tag) || !strings.ContainsRune(" \t\r\n>", rune(buf[len(tag)])) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rebuildIndex finds the spectrum offsets by scanning the whole file
+func (r *MzMLReader) rebuildIndex() {
+	if e := r.scanIndex(); e != nil {
+		msg.Custom(e, "fatal")
+	}
+}
+
+// scanIndex collects the spectrum offsets found before the end of the file or the first XML error
+func (r *MzMLReader) scanIndex() error {
+
+	r.offsets = nil
+	r.ids = nil
+
+	decoder := xml.NewDecoder(io.NewSectionReader(r.file, 0, r.size))
+
+	// offsets must refer to the raw bytes, so the content is never transcoded
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	for {
+
+		offset := decoder.InputOffset()
+
+		t, e := decoder.RawToken()
+		if e == io.EOF {
+			break
+		} else if e != nil {
+			return fmt.Errorf("cannot index %s: %w", r.FileName, e)
+		}
+
+		if se, ok := t.(xml.StartElement); ok && se.Name.Local == "spectrum" {
+			var id string
+			for _, a := range se.Attr {
+				if a.Name.Local == "id" {
+					id = a.Value
+				}
+			}
+			r.offsets = append(r.offsets, offset)
+			r.ids = append(r.ids, id)
+		}
+	}
+
+	return nil
+}
+
+// checkSoftware warns about files converted with deprecated ProteoWizard versions
+func (r *MzMLReader) checkSoftware() {
+	if r.deprecatedSoftware() {
+		msg.Custom(errors.New("the msconvert version used to convert this file is not supported, or is deprecated. Please update your ProteoWizard and convert the raw files again"), "warning")
+	}
+}
+
+// deprecatedSoftware is true when the file was converted by a ProteoWizard version that is no longer supported
+func (r *MzMLReader) deprecatedSoftware() bool {
+
+	decoder := xml.NewDecoder(io.NewSectionReader(r.file, 0, r.offsets[0]))
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	for {
+
+		t, e := decoder.Token()
+		if e != nil {
+			return false
+		}
+
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "softwareList" {
+			continue
+		}
+
+		var list psi.SoftwareList
+		if e := decoder.DecodeElement(&list, &se); e != nil || len(list.Software) == 0 {
+			return false
+		}
+
+		if list.Software[0].ID != "pwiz" {
+			return false
+		}
+
+		version, _ := strconv.Atoi(strings.Replace(list.Software[0].Version, ".", "", -1))
+
+		return version <= 3020232
+	}
+}
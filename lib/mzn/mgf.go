@@ -0,0 +1,163 @@
+package mzn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+)
+
+var titleScanRG = regexp.MustCompile(`^\S+?\.(\d+)\.\d+\.\d+`)
+
+// MGFReader streams the fragment spectra of a Mascot Generic Format file, MGF files carry MS2 scans only
+type MGFReader struct {
+	FileName string
+	file     *os.File
+	scanner  *bufio.Scanner
+	count    int
+}
+
+// NewMGFReader opens a MGF file
+func NewMGFReader(f string) *MGFReader {
+
+	file, e := os.Open(f)
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	r := &MGFReader{FileName: f, file: file}
+	r.Reset()
+
+	return r
+}
+
+// Close releases the MGF file
+func (r *MGFReader) Close() {
+	r.file.Close()
+}
+
+// Reset moves the stream back to the first spectrum
+func (r *MGFReader) Reset() {
+
+	if _, e := r.file.Seek(0, io.SeekStart); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	r.scanner = bufio.NewScanner(r.file)
+	r.scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	r.count = 0
+}
+
+// Next returns the following spectrum of the stream
+func (r *MGFReader) Next() (Spectrum, bool) {
+
+	var spec Spectrum
+	var open bool
+
+	for r.scanner.Scan() {
+
+		line := strings.TrimSpace(r.scanner.Text())
+
+		switch {
+		case line == "BEGIN IONS":
+			spec = Spectrum{Level: "2"}
+			open = true
+
+		case line == "END IONS" && open:
+			r.count++
+			if len(spec.Scan) == 0 {
+				spec.Scan = strconv.Itoa(r.count)
+			}
+			scan, _ := strconv.Atoi(spec.Scan)
+			spec.Index = strconv.Itoa(scan - 1)
+			return spec, true
+
+		case !open || len(line) == 0 || line[0] == '#':
+			continue
+
+		case strings.Contains(line, "="):
+			key := line[:strings.Index(line, "=")]
+			value := line[strings.Index(line, "=")+1:]
+			r.parseHeader(&spec, strings.ToUpper(key), value)
+
+		default:
+			fields := strings.Fields(line)
+			mz, e1 := strconv.ParseFloat(fields[0], 64)
+			var intensity float64
+			var e2 error
+			if len(fields) > 1 {
+				intensity, e2 = strconv.ParseFloat(fields[1], 64)
+			}
+			if e1 != nil || e2 != nil {
+				msg.Custom(fmt.Errorf("malformed peak line on %s: %s", r.FileName, line), "fatal")
+			}
+			spec.Mz.DecodedStream = append(spec.Mz.DecodedStream, mz)
+			spec.Intensity.DecodedStream = append(spec.Intensity.DecodedStream, intensity)
+		}
+	}
+
+	if e := r.scanner.Err(); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	return Spectrum{}, false
+}
+
+// parseHeader fills the spectrum attributes from a KEY=value line
+func (r *MGFReader) parseHeader(spec *Spectrum, key, value string) {
+
+	switch key {
+	case "TITLE":
+		spec.SpectrumName = value
+		if len(spec.Scan) == 0 {
+			spec.Scan = titleScan(value)
+		}
+
+	case "SCANS":
+		// scan ranges of merged spectra keep the first scan
+		spec.Scan = strings.Split(value, "-")[0]
+
+	case "PEPMASS":
+		fields := strings.Fields(value)
+		if len(fields) > 0 {
+			spec.Precursor.SelectedIon, _ = strconv.ParseFloat(fields[0], 64)
+			spec.Precursor.TargetIon = spec.Precursor.SelectedIon
+		}
+		if len(fields) > 1 {
+			spec.Precursor.SelectedIonIntensity, _ = strconv.ParseFloat(fields[1], 64)
+		}
+
+	case "CHARGE":
+		// multiple charges like 2+ and 3+ keep the first one
+		charge := strings.Fields(value)
+		if len(charge) > 0 {
+			spec.Precursor.ChargeState, _ = strconv.Atoi(strings.TrimRight(charge[0], "+-"))
+		}
+
+	case "RTINSECONDS":
+		rt, e := strconv.ParseFloat(value, 64)
+		if e != nil {
+			msg.CastFloatToString(e, "error")
+		}
+		spec.ScanStartTime = rt / 60
+	}
+}
+
+// titleScan reads the scan number from msconvert native IDs or from TPP style titles
+func titleScan(title string) string {
+
+	if match := nativeScanRG.FindStringSubmatch(title); match != nil {
+		return match[1]
+	}
+
+	if match := titleScanRG.FindStringSubmatch(title); match != nil {
+		return strings.TrimLeft(match[1], "0")
+	}
+
+	return ""
+}
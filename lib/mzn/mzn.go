@@ -6,17 +6,17 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/Nesvilab/philosopher/lib/msg"
 
 	"github.com/Nesvilab/philosopher/lib/psi"
-
-	"github.com/sirupsen/logrus"
 )
 
 // MsData top struct
@@ -41,6 +41,13 @@ type Spectrum struct {
 	Mz                  Mz
 	Intensity           Intensity
 	IonMobility         IonMobility
+	IonInjectionTime    float64
+	MasterScan          string
+	MonoisotopicMz      float64
+	AGCTarget           float64
+	FilterString        string
+	Profile             bool
+	Polarity            string
 }
 
 // Precursor struct
@@ -54,6 +61,29 @@ type Precursor struct {
 	TargetIonIntensity         float64
 	IsolationWindowLowerOffset float64
 	IsolationWindowUpperOffset float64
+	ActivationEnergy           float64
+	ActivationMethod           string
+}
+
+// activationMethods maps the dissociation methods to their controlled vocabulary terms
+var activationMethods = map[string][2]string{
+	"CID":   {"MS:1000133", "collision-induced dissociation"},
+	"HCD":   {"MS:1000422", "beam-type collision-induced dissociation"},
+	"ETD":   {"MS:1000598", "electron transfer dissociation"},
+	"ECD":   {"MS:1000250", "electron capture dissociation"},
+	"PQD":   {"MS:1000599", "pulsed q dissociation"},
+	"IRMPD": {"MS:1000262", "infrared multiphoton dissociation"},
+	"ETHCD": {"MS:1002631", "electron-transfer/higher-energy collision dissociation"},
+}
+
+// activationMethod returns the dissociation method of a controlled vocabulary term
+func activationMethod(accession string) (string, bool) {
+	for k, v := range activationMethods {
+		if v[0] == accession {
+			return k, true
+		}
+	}
+	return "", false
 }
 
 // Mz struct
@@ -85,106 +115,17 @@ func (a Spectra) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a Spectra) Less(i, j int) bool { return a[i].Index < a[j].Index }
 
 // ReadRaw is the main function for parsing Thermo Raw data
-func (p *MsData) ReadRaw(fileName, f string) {
-
-	p.FileName = fileName
-	var spectra Spectra
-
-	lines := strings.Split(f, "%")
-
-	for _, i := range lines {
-		parts := strings.Split(i, "#")
-
-		if len(parts) >= 8 {
-
-			var spec Spectrum
-
-			if strings.Contains(parts[0], "ms3") {
-				spec.Level = "3"
-			} else if strings.Contains(parts[0], "ms2") {
-				spec.Level = "2"
-			} else {
-				spec.Level = "1"
-			}
-
-			spec.Scan = parts[1]
-			indexInt, _ := strconv.Atoi(parts[1])
-			indexInt--
-			spec.Index = string(strconv.Itoa(indexInt))
-
-			parts2, _ := strconv.Atoi(parts[2])
-			spec.Precursor.ChargeState = parts2
-
-			spec.Precursor.ParentScan = parts[3]
-			parentIndexInt, _ := strconv.Atoi(parts[3])
-			parentIndexInt--
-			spec.Precursor.ParentIndex = string(strconv.Itoa(parentIndexInt))
-
-			spec.Precursor.IsolationWindowLowerOffset = 0.6
-			spec.Precursor.IsolationWindowUpperOffset = 0.6
-
-			siVal1, e := strconv.ParseFloat(parts[5], 64)
-			if e != nil {
-				msg.CastFloatToString(e, "error")
-			} else {
-				spec.Precursor.SelectedIon = siVal1
-				spec.Precursor.TargetIon = siVal1
-			}
-
-			siVal2, e := strconv.ParseFloat(parts[6], 64)
-			if e != nil {
-				msg.CastFloatToString(e, "error")
-			} else {
-				//spec.Precursor.SelectedIonIntensity = siVal2
-				spec.Precursor.TargetIonIntensity = siVal2
-			}
-
-			if spec.Level == "1" {
-				spec.Precursor.ParentScan = ""
-				spec.Precursor.ParentIndex = ""
-				spec.Precursor.ChargeState = 0
-				spec.Precursor.SelectedIon = 0
-				spec.Precursor.SelectedIonIntensity = 0
-				spec.Precursor.TargetIon = 0
-				spec.Precursor.TargetIonIntensity = 0
-				spec.Precursor.IsolationWindowLowerOffset = 0.6
-				spec.Precursor.IsolationWindowUpperOffset = 0.6
-			}
-
-			rtVal, _ := strconv.ParseFloat(parts[4], 64)
-			spec.ScanStartTime = rtVal
+func (p *MsData) ReadRaw(f string) {
 
-			//spec.Mz.Stream = []byte(parts[7])
-			spec.Mz.Precision = "64"
-			spec.Mz.Compression = "1"
+	r := NewRawReader(f)
+	defer r.Close()
 
-			//spec.Intensity.Stream = []byte(parts[8])
-			spec.Intensity.Precision = "64"
-			spec.Intensity.Compression = "1"
-
-			peaks := strings.Split(parts[8], " ")
-			for _, arg := range peaks {
-				if n, e := strconv.ParseFloat(string(arg), 64); e == nil {
-					spec.Mz.DecodedStream = append(spec.Mz.DecodedStream, n)
-				}
-			}
-
-			intensities := strings.Split(parts[9], " ")
-			for _, arg := range intensities {
-				if n, e := strconv.ParseFloat(string(arg), 64); e == nil {
-					spec.Intensity.DecodedStream = append(spec.Intensity.DecodedStream, n)
-				}
-			}
-
-			spectra = append(spectra, spec)
-		}
-	}
+	*p = Load(r, "1", "2", "3")
+	p.FileName = f
 
-	if len(spectra) == 0 {
+	if len(p.Spectra) == 0 {
 		msg.NoSpectraFound(errors.New(""), "error")
 	}
-
-	p.Spectra = spectra
 }
 
 // Read is the main function for parsing mzML data
@@ -207,7 +148,10 @@ func (p *MsData) Read(f string) {
 
 	for _, i := range sl.Spectrum {
 
-		spectrum := processSpectrum(i)
+		spectrum, e := processSpectrum(i)
+		if e != nil {
+			msg.Custom(e, "fatal")
+		}
 
 		spectra = append(spectra, spectrum)
 	}
@@ -220,7 +164,9 @@ func (p *MsData) Read(f string) {
 
 }
 
-func processSpectrum(mzSpec psi.Spectrum) Spectrum {
+// processSpectrum converts a mzML spectrum, the error reports binary arrays with an unsupported
+// encoding, the spectrum is returned without their encoding
+func processSpectrum(mzSpec psi.Spectrum) (Spectrum, error) {
 
 	var spec Spectrum
 
@@ -236,6 +182,15 @@ func processSpectrum(mzSpec psi.Spectrum) Spectrum {
 			spec.Level = j.Value
 		}
 
+		switch string(j.Accession) {
+		case "MS:1000128":
+			spec.Profile = true
+		case "MS:1000130":
+			spec.Polarity = "+"
+		case "MS:1000129":
+			spec.Polarity = "-"
+		}
+
 		if string(j.Accession) == "MS:1001581" {
 			spec.CompensationVoltage = j.Value
 		}
@@ -249,10 +204,30 @@ func processSpectrum(mzSpec psi.Spectrum) Spectrum {
 			}
 			spec.ScanStartTime = val
 		}
+
+		if string(j.Accession) == "MS:1000927" {
+			spec.IonInjectionTime, _ = strconv.ParseFloat(j.Value, 64)
+		}
+
+		if string(j.Accession) == "MS:1000512" {
+			spec.FilterString = j.Value
+		}
+	}
+
+	// msconvert keeps some of the Thermo trailer extra values as user parameters
+	for _, j := range mzSpec.ScanList.Scan[0].UserParam {
+		switch j.Name {
+		case "[Thermo Trailer Extra]Monoisotopic M/Z:":
+			spec.MonoisotopicMz, _ = strconv.ParseFloat(j.Value, 64)
+		case "[Thermo Trailer Extra]Master Scan Number:":
+			spec.MasterScan = j.Value
+		case "[Thermo Trailer Extra]AGC Target:":
+			spec.AGCTarget, _ = strconv.ParseFloat(j.Value, 64)
+		}
 	}
 
 	spec.Precursor = Precursor{}
-	if len(mzSpec.PrecursorList.Precursor) > 0 {
+	if mzSpec.PrecursorList != nil && len(mzSpec.PrecursorList.Precursor) > 0 {
 
 		if len(mzSpec.PrecursorList.Precursor[0].SpectrumRef) > 0 {
 
@@ -318,61 +293,54 @@ func processSpectrum(mzSpec psi.Spectrum) Spectrum {
 				spec.Precursor.SelectedIonIntensity = val
 			}
 		}
-	}
 
-	spec.Mz.Stream = mzSpec.BinaryDataArrayList.BinaryDataArray[0].Binary.Value
-	for _, j := range mzSpec.BinaryDataArrayList.BinaryDataArray[0].CVParam {
-		if string(j.Accession) == "MS:1000523" {
-			spec.Mz.Precision = "64"
-		} else if string(j.Accession) == "MS:1000521" {
-			spec.Mz.Precision = "32"
+		for _, j := range mzSpec.PrecursorList.Precursor[0].Activation.CVParam {
+			if string(j.Accession) == "MS:1000045" {
+				spec.Precursor.ActivationEnergy, _ = strconv.ParseFloat(j.Value, 64)
+			}
+			if m, ok := activationMethod(string(j.Accession)); ok {
+				spec.Precursor.ActivationMethod = m
+			}
 		}
+	}
 
-		if string(j.Accession) == "MS:1000574" {
-			spec.Mz.Compression = "1"
-		} else if string(j.Accession) == "MS:1000576" {
-			spec.Mz.Compression = "0"
-		}
+	var err error
+	var e error
+
+	spec.Mz.Stream = mzSpec.BinaryDataArrayList.BinaryDataArray[0].Binary.Value
+	if spec.Mz.Precision, spec.Mz.Compression, e = arrayEncoding(mzSpec.BinaryDataArrayList.BinaryDataArray[0].CVParam); e != nil {
+		err = fmt.Errorf("m/z array: %w", e)
 	}
 
 	spec.Intensity.Stream = mzSpec.BinaryDataArrayList.BinaryDataArray[1].Binary.Value
-	for _, j := range mzSpec.BinaryDataArrayList.BinaryDataArray[1].CVParam {
-		if string(j.Accession) == "MS:1000523" {
-			spec.Intensity.Precision = "64"
-		} else if string(j.Accession) == "MS:1000521" {
-			spec.Intensity.Precision = "32"
-		}
-
-		if string(j.Accession) == "MS:1000574" {
-			spec.Intensity.Compression = "1"
-		} else if string(j.Accession) == "MS:1000576" {
-			spec.Intensity.Compression = "0"
-		}
+	if spec.Intensity.Precision, spec.Intensity.Compression, e = arrayEncoding(mzSpec.BinaryDataArrayList.BinaryDataArray[1].CVParam); e != nil && err == nil {
+		err = fmt.Errorf("intensity array: %w", e)
 	}
 
 	if mzSpec.BinaryDataArrayList.Count == 3 {
 		spec.IonMobility.Stream = mzSpec.BinaryDataArrayList.BinaryDataArray[2].Binary.Value
-		for _, j := range mzSpec.BinaryDataArrayList.BinaryDataArray[2].CVParam {
-			if string(j.Accession) == "MS:1000523" {
-				spec.IonMobility.Precision = "64"
-			} else if string(j.Accession) == "MS:1000521" {
-				spec.IonMobility.Precision = "32"
-			}
-
-			if string(j.Accession) == "MS:1000574" {
-				spec.IonMobility.Compression = "1"
-			} else if string(j.Accession) == "MS:1000576" {
-				spec.IonMobility.Compression = "0"
-			}
+		if spec.IonMobility.Precision, spec.IonMobility.Compression, e = arrayEncoding(mzSpec.BinaryDataArrayList.BinaryDataArray[2].CVParam); e != nil {
+			skipArray(fmt.Errorf("ion mobility array: %w", e))
+			spec.IonMobility.Stream = nil
 		}
 	}
 
-	return spec
+	return spec, err
 }
 
-// Decode processes the binary data
+// Decode processes the binary data, profile spectra are centroided with the Picking settings
 func (s *Spectrum) Decode() {
 
+	s.decodeArrays()
+
+	if s.Profile {
+		s.Centroid(Picking)
+	}
+}
+
+// decodeArrays transforms the encoded binary arrays into values
+func (s *Spectrum) decodeArrays() {
+
 	if len(s.Mz.Stream) > 0 && len(s.Intensity.Stream) > 0 {
 		s.Mz.DecodedStream = readEncoded(s.Mz.Stream, s.Mz.Precision, s.Mz.Compression)
 		s.Mz.Stream = nil
@@ -382,51 +350,121 @@ func (s *Spectrum) Decode() {
 	}
 
 	if len(s.IonMobility.Stream) > 0 {
-		s.IonMobility.DecodedStream = readEncoded(s.IonMobility.Stream, s.IonMobility.Precision, s.IonMobility.Compression)
+		im, e := decodeBinary(s.IonMobility.Stream, s.IonMobility.Precision, s.IonMobility.Compression)
+		if e != nil {
+			skipArray(fmt.Errorf("ion mobility array: %w", e))
+		}
+		s.IonMobility.DecodedStream = im
 		s.IonMobility.Stream = nil
 	}
 
 }
 
+// arrayEncoding reads the precision and compression of a binary data array from its cvParams
+func arrayEncoding(cv []psi.CVParam) (string, string, error) {
+
+	var precision, compression string
+
+	for _, j := range cv {
+
+		if v, ok := precisionTerms[string(j.Accession)]; ok {
+			precision = v
+		} else if v, ok := compressionTerms[string(j.Accession)]; ok {
+			compression = v
+		} else if name, ok := unsupportedTerms[string(j.Accession)]; ok {
+			return precision, compression, fmt.Errorf("the mzML binary encoding %s (%s) is not supported, convert the raw files without it", j.Accession, name)
+		}
+	}
+
+	return precision, compression, nil
+}
+
+// skippedArrays keeps the warning about the arrays that cannot be decoded to one per run
+var skippedArrays sync.Once
+
+// skipArray reports an array that is left out of the spectrum, only the m/z and intensity arrays are required
+func skipArray(e error) {
+	skippedArrays.Do(func() {
+		msg.Custom(fmt.Errorf("skipping the binary arrays that cannot be decoded, %w", e), "warning")
+	})
+}
+
 // readEncoded transforms the binary data into float64 values
 func readEncoded(bin []byte, precision, isCompressed string) []float64 {
 
-	var floatArray []float64
+	floatArray, e := decodeBinary(bin, precision, isCompressed)
+	if e != nil {
+		msg.Custom(e, "fatal")
+	}
+
+	return floatArray
+}
+
+// decodeBinary decodes a base64 binary array, the compression is applied before the number encoding
+func decodeBinary(bin []byte, precision, isCompressed string) ([]float64, error) {
+
+	if len(bin) == 0 {
+		return nil, nil
+	}
 
 	b := bytes.NewReader(bin)
 	b64 := base64.NewDecoder(base64.StdEncoding, b)
 
 	var bytestream bytes.Buffer
-	if isCompressed == "1" {
+	if isCompressed == "1" || strings.HasSuffix(isCompressed, "+zlib") {
 		r, e := zlib.NewReader(b64)
 		if e != nil {
-			msg.ReadingMzMLZlib(e, "fatal")
-			var emptyArray []float64
-			emptyArray = append(emptyArray, 0.0)
-			return emptyArray
+			return nil, fmt.Errorf("cannot read the zlib compressed array: %w", e)
+		}
+		if _, e := io.Copy(&bytestream, r); e != nil {
+			return nil, fmt.Errorf("cannot read the zlib compressed array: %w", e)
 		}
-		io.Copy(&bytestream, r)
-	} else {
-		io.Copy(&bytestream, b64)
+	} else if _, e := io.Copy(&bytestream, b64); e != nil {
+		return nil, fmt.Errorf("cannot decode the base64 array: %w", e)
 	}
 
 	dataArray := bytestream.Bytes()
 
-	if precision == "32" {
-		for i := 0; i < len(dataArray); i += 4 {
+	// MS-Numpress arrays carry their own precision
+	switch strings.TrimSuffix(isCompressed, "+zlib") {
+	case "linear":
+		return decodeLinear(dataArray)
+	case "pic":
+		return decodePic(dataArray)
+	case "slof":
+		return decodeSlof(dataArray)
+	}
+
+	return decodeNumbers(dataArray, precision)
+}
+
+// decodeNumbers converts the little-endian numbers of a plain binary array
+func decodeNumbers(dataArray []byte, precision string) ([]float64, error) {
+
+	var floatArray []float64
+
+	switch precision {
+	case "32":
+		for i := 0; i+4 <= len(dataArray); i += 4 {
 			bits := binary.LittleEndian.Uint32(dataArray[i : i+4])
-			converted := math.Float32frombits(bits)
-			floatArray = append(floatArray, float64(converted))
+			floatArray = append(floatArray, float64(math.Float32frombits(bits)))
 		}
-	} else if precision == "64" {
-		for i := 0; i < len(dataArray); i += 8 {
+	case "64":
+		for i := 0; i+8 <= len(dataArray); i += 8 {
 			bits := binary.LittleEndian.Uint64(dataArray[i : i+8])
-			converted := math.Float64frombits(bits)
-			floatArray = append(floatArray, float64(converted))
+			floatArray = append(floatArray, math.Float64frombits(bits))
 		}
-	} else {
-		logrus.Trace("Error trying to define mzML binary precision")
+	case "i32":
+		for i := 0; i+4 <= len(dataArray); i += 4 {
+			floatArray = append(floatArray, float64(int32(binary.LittleEndian.Uint32(dataArray[i:i+4]))))
+		}
+	case "i64":
+		for i := 0; i+8 <= len(dataArray); i += 8 {
+			floatArray = append(floatArray, float64(int64(binary.LittleEndian.Uint64(dataArray[i:i+8]))))
+		}
+	default:
+		return nil, errors.New("the mzML binary array has no supported precision cvParam")
 	}
 
-	return floatArray
+	return floatArray, nil
 }
@@ -1,8 +1,19 @@
 package mzn_test
 
 import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/Nesvilab/philosopher/lib/fin"
 	"github.com/Nesvilab/philosopher/lib/mzn"
 	"github.com/Nesvilab/philosopher/lib/tes"
 	"github.com/Nesvilab/philosopher/lib/uti"
@@ -108,3 +119,574 @@ func TestMS2Spectra(t *testing.T) {
 		t.Errorf("Spectrum number is incorrect, got %f, want %f", spec.Precursor.IsolationWindowLowerOffset, 0.2500)
 	}
 }
+
+// encodeArray packs the values as uncompressed, little-endian 64-bit floats
+func encodeArray(values []float64) string {
+	b := make([]byte, 8*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint64(b[8*i:], math.Float64bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// writeIndexedMzML writes a three spectra mzML file, a broken index shifts every offset and the extra
+// cvParams add a third binary array
+func writeIndexedMzML(t *testing.T, brokenIndex bool, extra string) string {
+
+	var b strings.Builder
+	var offsets []int
+
+	b.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?>\n<indexedmzML>\n<mzML>\n<softwareList count=\"1\"><software id=\"test\" version=\"1.0\"/></softwareList>\n<run id=\"run\">\n<spectrumList count=\"3\">\n")
+
+	for i := 0; i < 3; i++ {
+
+		level := 1
+		precursor := ""
+		if i > 0 {
+			level = 2
+			precursor = `<precursorList count="1"><precursor spectrumRef="scan=1"><isolationWindow><cvParam accession="MS:1000827" value="500.25"/></isolationWindow><selectedIonList count="1"><selectedIon><cvParam accession="MS:1000744" value="500.25"/><cvParam accession="MS:1000041" value="2"/></selectedIon></selectedIonList></precursor></precursorList>`
+		}
+
+		arrays := 2
+		third := ""
+		if len(extra) > 0 {
+			arrays = 3
+			third = fmt.Sprintf(`<binaryDataArray>%s<binary>%s</binary></binaryDataArray>`, extra, encodeArray([]float64{1, 2}))
+		}
+
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, `<spectrum index="%d" id="scan=%d" defaultArrayLength="2"><cvParam accession="MS:1000511" value="%d"/><scanList count="1"><scan><cvParam accession="MS:1000016" value="%d.5"/></scan></scanList>%s<binaryDataArrayList count="%d"><binaryDataArray><cvParam accession="MS:1000523"/><cvParam accession="MS:1000576"/><binary>%s</binary></binaryDataArray><binaryDataArray><cvParam accession="MS:1000523"/><cvParam accession="MS:1000576"/><binary>%s</binary></binaryDataArray>%s</binaryDataArrayList></spectrum>`,
+			i, i+1, level, i, precursor, arrays, encodeArray([]float64{100 + float64(i), 200}), encodeArray([]float64{10, 20 * float64(i+1)}), third)
+		b.WriteString("\n")
+	}
+
+	b.WriteString("</spectrumList>\n</run>\n</mzML>\n")
+
+	indexOffset := b.Len()
+	b.WriteString("<indexList count=\"1\">\n<index name=\"spectrum\">\n")
+	for i, o := range offsets {
+		if brokenIndex {
+			o += 3
+		}
+		fmt.Fprintf(&b, "<offset idRef=\"scan=%d\">%d</offset>\n", i+1, o)
+	}
+	fmt.Fprintf(&b, "</index>\n</indexList>\n<indexListOffset>%d</indexListOffset>\n</indexedmzML>\n", indexOffset)
+
+	f := filepath.Join(t.TempDir(), "test.mzML")
+	if e := os.WriteFile(f, []byte(b.String()), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	return f
+}
+
+func TestMzMLReader(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		brokenIndex bool
+		extra       string
+		mobility    int
+	}{
+		{"index", false, "", 0},
+		{"rebuilt index", true, "", 0},
+		{"ion mobility array", false, `<cvParam accession="MS:1000523"/><cvParam accession="MS:1002816"/>`, 2},
+		{"array without precision", false, `<cvParam accession="MS:1000786"/>`, 0},
+		{"array with unsupported encoding", false, `<cvParam accession="MS:1000523"/><cvParam accession="MS:1003090"/>`, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			r := mzn.NewMzMLReader(writeIndexedMzML(t, tt.brokenIndex, tt.extra))
+			defer r.Close()
+
+			if r.Len() != 3 {
+				t.Fatalf("Spectra number is incorrect, got %d, want %d", r.Len(), 3)
+			}
+
+			var levels []string
+			for s, ok := r.Next(); ok; s, ok = r.Next() {
+				if len(s.Mz.DecodedStream) > 0 {
+					t.Errorf("Spectrum %s was decoded before Decode", s.Scan)
+				}
+				levels = append(levels, s.Level)
+			}
+
+			if strings.Join(levels, ",") != "1,2,2" {
+				t.Errorf("Spectrum levels are incorrect, got %v", levels)
+			}
+
+			s, ok := r.Scan("00003")
+			if !ok {
+				t.Fatal("Scan 3 was not found")
+			}
+			s.Decode()
+
+			if s.Index != "2" || s.Precursor.ChargeState != 2 || s.Precursor.ParentScan != "1" {
+				t.Errorf("Spectrum is incorrect, got index %s, charge %d, parent %s", s.Index, s.Precursor.ChargeState, s.Precursor.ParentScan)
+			}
+
+			if s.Mz.DecodedStream[0] != 102 || s.Intensity.DecodedStream[1] != 60 {
+				t.Errorf("Spectrum peaks are incorrect, got %v %v", s.Mz.DecodedStream, s.Intensity.DecodedStream)
+			}
+
+			if len(s.IonMobility.DecodedStream) != tt.mobility {
+				t.Errorf("Ion mobility values are incorrect, got %v, want %d values", s.IonMobility.DecodedStream, tt.mobility)
+			}
+
+			if _, ok := r.Scan("4"); ok {
+				t.Error("Scan 4 should not be found")
+			}
+
+			mz := mzn.Load(r, "1")
+			if len(mz.Spectra) != 3 || len(mz.Spectra[0].Mz.DecodedStream) != 2 || len(mz.Spectra[1].Mz.Stream) > 0 {
+				t.Error("Load should only decode the MS1 spectra")
+			}
+		})
+	}
+}
+
+func TestNumpressDecoding(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		stream      string
+		compression string
+		want        []float64
+		tol         float64
+	}{
+		{"linear", "QPhqAAAAAACAlpgA0FmZAEihbAvDLPZi6owVlg==", "linear", []float64{100, 100.5, 101.25, 101.5, 250.125, 300}, 1e-5},
+		{"linear and zlib", "eJxz+JHFAAIN02YwXIicyeCxMIf7sM63pFc9otMAilsK2w==", "linear+zlib", []float64{100, 100.5, 101.25, 101.5, 250.125, 300}, 1e-5},
+		{"pic", "hxf2AW/0AAEwQuF3", "pic", []float64{0, 1, 15, 16, 255, 4096, 123456, 7}, 0},
+		{"slof", "QLOIAAAAAADVLiRa8IZi1Q==", "slof", []float64{9.998952, 100.008028, 1000.045267, 55557.955656}, 1e-5},
+		{"slof and zlib", "eJxz2NzBAAJX9VSiPrQlXQUAJ2wFqg==", "slof+zlib", []float64{9.998952, 100.008028, 1000.045267, 55557.955656}, 1e-5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var s mzn.Spectrum
+			s.Mz = mzn.Mz{Stream: []byte(tt.stream), Precision: "64", Compression: tt.compression}
+			s.Intensity = mzn.Intensity{Stream: []byte(tt.stream), Precision: "64", Compression: tt.compression}
+			s.Decode()
+
+			if len(s.Mz.DecodedStream) != len(tt.want) {
+				t.Fatalf("Decoded length is incorrect, got %d, want %d", len(s.Mz.DecodedStream), len(tt.want))
+			}
+
+			for i := range tt.want {
+				if math.Abs(s.Mz.DecodedStream[i]-tt.want[i]) > tt.tol {
+					t.Errorf("Decoded value %d is incorrect, got %f, want %f", i, s.Mz.DecodedStream[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+
+	mzXML := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<mzXML>
+<msRun scanCount="2">
+<scan num="7" msLevel="1" peaksCount="2" retentionTime="PT90S">
+<peaks precision="32" byteOrder="network" contentType="m/z-int" compressionType="zlib">eJxzOsnA4FLFwODs4cDg5MHAAAAf1QMf</peaks>
+<scan num="8" msLevel="2" peaksCount="2" retentionTime="PT1M31.5S">
+<precursorMz precursorIntensity="5000" precursorCharge="2" windowWideness="1.4">100.5</precursorMz>
+<peaks precision="64" byteOrder="network" contentType="m/z-int" compressionType="none">QF+IIMSbpeNAf0AAAAAAAEBfx++dsi0OQG9AAAAAAAA=</peaks>
+</scan>
+</scan>
+</msRun>
+</mzXML>
+`
+
+	mgf := `BEGIN IONS
+TITLE=sample.00012.00012.2 File:"sample.raw", NativeID:"controllerType=0 controllerNumber=1 scan=12"
+RTINSECONDS=120
+PEPMASS=500.25 3000
+CHARGE=2+
+126.127 500
+127.124 250
+END IONS
+BEGIN IONS
+TITLE=sample.00015.00015.3
+PEPMASS=400.1
+CHARGE=3+
+126.127 10
+END IONS
+`
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		peak    float64
+		want    []mzn.Spectrum
+	}{
+		{"mzXML", "test.mzXML", mzXML, 500, []mzn.Spectrum{
+			{Scan: "7", Level: "1", ScanStartTime: 1.5},
+			{Scan: "8", Level: "2", ScanStartTime: 1.525, Precursor: mzn.Precursor{ParentScan: "7", ChargeState: 2, TargetIon: 100.5}},
+		}},
+		{"mgf", "test.mgf", mgf, 10, []mzn.Spectrum{
+			{Scan: "12", Level: "2", ScanStartTime: 2, Precursor: mzn.Precursor{ChargeState: 2, TargetIon: 500.25}},
+			{Scan: "15", Level: "2", Precursor: mzn.Precursor{ChargeState: 3, TargetIon: 400.1}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			f := filepath.Join(t.TempDir(), tt.file)
+			if e := os.WriteFile(f, []byte(tt.content), 0644); e != nil {
+				t.Fatal(e)
+			}
+
+			src := mzn.NewSource(f)
+			defer src.Close()
+
+			var got []mzn.Spectrum
+			for s, ok := src.Next(); ok; s, ok = src.Next() {
+				got = append(got, s)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Spectra number is incorrect, got %d, want %d", len(got), len(tt.want))
+			}
+
+			for i, w := range tt.want {
+				g := got[i]
+				if g.Scan != w.Scan || g.Level != w.Level || math.Abs(g.ScanStartTime-w.ScanStartTime) > 1e-9 {
+					t.Errorf("Spectrum %d is incorrect, got scan %s level %s rt %f", i, g.Scan, g.Level, g.ScanStartTime)
+				}
+				if g.Precursor.ParentScan != w.Precursor.ParentScan || g.Precursor.ChargeState != w.Precursor.ChargeState || g.Precursor.TargetIon != w.Precursor.TargetIon {
+					t.Errorf("Spectrum %d precursor is incorrect, got %+v", i, g.Precursor)
+				}
+			}
+
+			if got[1].Mz.DecodedStream[0] != 126.127 || got[1].Intensity.DecodedStream[0] != tt.peak {
+				t.Errorf("Peaks are incorrect, got %v and %v", got[1].Mz.DecodedStream, got[1].Intensity.DecodedStream)
+			}
+
+			// the stream can be read again after a reset
+			mz := mzn.Load(src, "2")
+			if len(mz.Spectra) != len(tt.want) {
+				t.Errorf("Spectra number after reset is incorrect, got %d, want %d", len(mz.Spectra), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestRawSpectrum(t *testing.T) {
+
+	var parents = make(map[int]mzn.Spectrum)
+
+	ms1 := fin.Scan{MSLevel: 1, Time: 10.5, Spectrum: func(centroided ...bool) fin.Spectrum {
+		return fin.Spectrum{{Mz: 500.3, I: 10}, {Mz: 400.2, I: 20}}
+	}}
+
+	ms2 := fin.Scan{MSLevel: 2, Time: 10.6, Reaction: []fin.Reaction{{Precursormz: 400.2}},
+		FilterString: "FTMS + c NSI d Full ms2 400.2000@30.00 [110.0000-2000.0000]",
+		Trailer: fin.Trailer{"Charge State": "2", "Ion Injection Time (ms)": "22.5", "Master Scan Number": "41",
+			"Monoisotopic M/Z": "399.7", "AGC Target": "50000", "FAIMS CV": "-45", "MS2 Isolation Width": "0.7"}}
+
+	s1 := mzn.RawSpectrum(41, ms1, parents)
+	s2 := mzn.RawSpectrum(42, ms2, parents)
+
+	if s1.Level != "1" || s1.Scan != "41" || s1.Index != "40" || s1.ScanStartTime != 10.5 {
+		t.Errorf("MS1 spectrum is incorrect, got %+v", s1)
+	}
+
+	if s1.Mz.DecodedStream[0] != 400.2 || s1.Intensity.DecodedStream[0] != 20 {
+		t.Errorf("MS1 peaks should be sorted by m/z, got %v %v", s1.Mz.DecodedStream, s1.Intensity.DecodedStream)
+	}
+
+	if s2.Level != "2" || s2.Precursor.ParentScan != "41" || s2.Precursor.ParentIndex != "40" || s2.Precursor.TargetIon != 400.2 {
+		t.Errorf("MS2 precursor is incorrect, got %+v", s2.Precursor)
+	}
+
+	if math.Abs(s2.Precursor.IsolationWindowLowerOffset-0.35) > 1e-9 || math.Abs(s2.Precursor.IsolationWindowUpperOffset-0.35) > 1e-9 {
+		t.Errorf("MS2 isolation window is incorrect, got %+v", s2.Precursor)
+	}
+
+	if s2.Precursor.ChargeState != 2 || s2.Precursor.SelectedIon != 399.7 || s2.CompensationVoltage != "-45" {
+		t.Errorf("MS2 trailer precursor values are incorrect, got %+v", s2)
+	}
+
+	if s2.IonInjectionTime != 22.5 || s2.MasterScan != "41" || s2.AGCTarget != 50000 || s2.FilterString != ms2.FilterString {
+		t.Errorf("MS2 trailer values are incorrect, got %+v", s2)
+	}
+}
+
+func TestRawPrecursors(t *testing.T) {
+
+	// a leading MS2, a full MS1-MS2-MS3 cycle, and a cycle where the MS3 comes before any MS2
+	tests := []struct {
+		level  uint8
+		parent string
+	}{
+		{2, ""},
+		{1, ""},
+		{2, "2"},
+		{3, "3"},
+		{2, "2"},
+		{1, ""},
+		{3, ""},
+		{2, "6"},
+		{3, "8"},
+	}
+
+	var parents = make(map[int]mzn.Spectrum)
+
+	for i, tt := range tests {
+
+		sn := i + 1
+		s := mzn.RawSpectrum(sn, fin.Scan{MSLevel: tt.level, Reaction: []fin.Reaction{{Precursormz: 500}}}, parents)
+
+		index := ""
+		if len(tt.parent) > 0 {
+			p, _ := strconv.Atoi(tt.parent)
+			index = strconv.Itoa(p - 1)
+		}
+
+		if s.Precursor.ParentScan != tt.parent || s.Precursor.ParentIndex != index {
+			t.Errorf("Scan %d MS%d parent is incorrect, got scan %q index %q, want scan %q index %q", sn, tt.level, s.Precursor.ParentScan, s.Precursor.ParentIndex, tt.parent, index)
+		}
+	}
+}
+
+func TestWriteMzML(t *testing.T) {
+
+	mzXML := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<mzXML>
+<msRun scanCount="2">
+<scan num="7" msLevel="1" peaksCount="2" retentionTime="PT90S" polarity="+" centroided="1">
+<peaks precision="32" byteOrder="network" contentType="m/z-int" compressionType="zlib">eJxzOsnA4FLFwODs4cDg5MHAAAAf1QMf</peaks>
+<scan num="8" msLevel="2" peaksCount="2" retentionTime="PT1M31.5S" centroided="1">
+<precursorMz precursorIntensity="5000" precursorCharge="2" windowWideness="1.4" activationEnergy="30" activationMethod="HCD">100.5</precursorMz>
+<peaks precision="64" byteOrder="network" contentType="m/z-int" compressionType="none">QF+IIMSbpeNAf0AAAAAAAEBfx++dsi0OQG9AAAAAAAA=</peaks>
+</scan>
+</scan>
+</msRun>
+</mzXML>
+`
+
+	// the spectra keep the native scan numbers on their IDs, but they are read back numbered by
+	// their position on the file, as freequant and labelquant number them
+	tests := []struct {
+		name   string
+		levels []string
+		zlib   bool
+		ids    []string
+		scans  []string
+	}{
+		{"all levels", nil, true, []string{"scan=7", "scan=8"}, []string{"1", "2"}},
+		{"MS2 only", []string{"2"}, false, []string{"scan=8"}, []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			dir := t.TempDir()
+			in := filepath.Join(dir, "test.mzXML")
+			out := filepath.Join(dir, "test.mzML")
+
+			if e := os.WriteFile(in, []byte(mzXML), 0644); e != nil {
+				t.Fatal(e)
+			}
+
+			src := mzn.NewSource(in)
+			n := mzn.WriteMzML(src, out, mzn.WriteOptions{Levels: tt.levels, MzPrecision: "64", IntensityPrecision: "32", Zlib: tt.zlib})
+			src.Close()
+
+			if n != len(tt.scans) {
+				t.Fatalf("Written spectra number is incorrect, got %d, want %d", n, len(tt.scans))
+			}
+
+			b, e := os.ReadFile(out)
+			if e != nil {
+				t.Fatal(e)
+			}
+
+			// the checksum covers everything up to the opening checksum tag
+			tag := strings.Index(string(b), "<fileChecksum>") + len("<fileChecksum>")
+			if want := fmt.Sprintf("%x", sha1.Sum(b[:tag])); string(b[tag:tag+40]) != want {
+				t.Errorf("File checksum is incorrect, got %s, want %s", b[tag:tag+40], want)
+			}
+
+			var ids []string
+			for _, i := range regexp.MustCompile(`<spectrum [^>]*id="([^"]+)"`).FindAllStringSubmatch(string(b), -1) {
+				ids = append(ids, i[1])
+			}
+
+			if strings.Join(ids, ",") != strings.Join(tt.ids, ",") {
+				t.Errorf("Spectrum IDs are incorrect, got %v, want %v", ids, tt.ids)
+			}
+
+			// the dissociation method is written as the concrete term, never as the abstract parent
+			if !strings.Contains(string(b), `accession="MS:1000422"`) || strings.Contains(string(b), `accession="MS:1000044"`) {
+				t.Errorf("Activation terms are incorrect")
+			}
+
+			for _, i := range regexp.MustCompile(`<offset idRef="[^"]+">(\d+)</offset>`).FindAllStringSubmatch(string(b), -1) {
+				offset, _ := strconv.Atoi(i[1])
+				if !strings.HasPrefix(string(b[offset:]), "<spectrum ") {
+					t.Errorf("Index offset %d does not point to a spectrum", offset)
+				}
+			}
+
+			r := mzn.NewMzMLReader(out)
+			defer r.Close()
+
+			var got []mzn.Spectrum
+			for s, ok := r.Next(); ok; s, ok = r.Next() {
+				s.Decode()
+				got = append(got, s)
+			}
+
+			if len(got) != len(tt.scans) {
+				t.Fatalf("Read spectra number is incorrect, got %d, want %d", len(got), len(tt.scans))
+			}
+
+			for i := range got {
+				if got[i].Scan != tt.scans[i] || got[i].Profile {
+					t.Errorf("Spectrum %d is incorrect, got %+v", i, got[i])
+				}
+			}
+
+			ms2 := got[len(got)-1]
+			if ms2.Level != "2" || ms2.Precursor.ParentScan != "7" || ms2.Precursor.ChargeState != 2 || ms2.Precursor.TargetIon != 100.5 || ms2.Precursor.ActivationEnergy != 30 || ms2.Precursor.ActivationMethod != "HCD" {
+				t.Errorf("MS2 precursor is incorrect, got %+v", ms2.Precursor)
+			}
+
+			if math.Abs(ms2.Precursor.IsolationWindowLowerOffset-0.7) > 1e-9 || math.Abs(ms2.ScanStartTime-1.525) > 1e-9 {
+				t.Errorf("MS2 scan values are incorrect, got %+v", ms2)
+			}
+
+			if len(ms2.Mz.DecodedStream) != 2 || ms2.Mz.DecodedStream[0] != 126.127 || ms2.Intensity.DecodedStream[0] != 500 {
+				t.Errorf("MS2 peaks are incorrect, got %v and %v", ms2.Mz.DecodedStream, ms2.Intensity.DecodedStream)
+			}
+		})
+	}
+}
+
+func TestPickPeaks(t *testing.T) {
+
+	// two gaussian peaks over a flat background, sampled every 0.002 m/z
+	var mz, intensity []float64
+	for i := 0; i <= 1000; i++ {
+		x := 500 + float64(i)*0.002
+		y := 10 + 1e5*math.Exp(-math.Pow(x-500.5013, 2)/(2*0.004*0.004)) + 50*math.Exp(-math.Pow(x-501.2007, 2)/(2*0.004*0.004))
+		mz = append(mz, x)
+		intensity = append(intensity, y)
+	}
+
+	tests := []struct {
+		name    string
+		options mzn.PickOptions
+		peaks   []float64
+		mzTol   float64
+	}{
+		{"gaussian apex", mzn.PickOptions{Apex: "gaussian"}, []float64{500.5013, 501.2007}, 2e-4},
+		{"parabolic apex", mzn.PickOptions{Apex: "parabolic"}, []float64{500.5013, 501.2007}, 5e-4},
+		{"signal to noise", mzn.PickOptions{Apex: "gaussian", SignalToNoise: 10}, []float64{500.5013}, 2e-4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := mzn.Spectrum{Profile: true, Mz: mzn.Mz{DecodedStream: mz}, Intensity: mzn.Intensity{DecodedStream: intensity}}
+			s.Centroid(tt.options)
+
+			if s.Profile || len(s.Mz.DecodedStream) != len(tt.peaks) {
+				t.Fatalf("Picked peaks are incorrect, got %v", s.Mz.DecodedStream)
+			}
+
+			for i := range tt.peaks {
+				if math.Abs(s.Mz.DecodedStream[i]-tt.peaks[i]) > tt.mzTol {
+					t.Errorf("Peak %d apex is incorrect, got %f, want %f", i, s.Mz.DecodedStream[i], tt.peaks[i])
+				}
+			}
+		})
+	}
+
+	centroided := mzn.Spectrum{Mz: mzn.Mz{DecodedStream: mz}, Intensity: mzn.Intensity{DecodedStream: intensity}}
+	centroided.Centroid(mzn.PickOptions{})
+	if len(centroided.Mz.DecodedStream) != len(mz) {
+		t.Errorf("Centroided spectra should be kept as they are")
+	}
+}
+
+func TestCheckMzML(t *testing.T) {
+
+	mzXML := `<?xml version="1.0" encoding="ISO-8859-1"?>
+<mzXML>
+<msRun scanCount="2">
+<scan num="7" msLevel="1" peaksCount="2" retentionTime="PT90S" polarity="+" centroided="1">
+<peaks precision="32" byteOrder="network" contentType="m/z-int" compressionType="zlib">eJxzOsnA4FLFwODs4cDg5MHAAAAf1QMf</peaks>
+<scan num="8" msLevel="2" peaksCount="2" retentionTime="PT1M31.5S" centroided="1">
+<precursorMz precursorIntensity="5000" precursorCharge="2" windowWideness="1.4" activationEnergy="30">100.5</precursorMz>
+<peaks precision="64" byteOrder="network" contentType="m/z-int" compressionType="none">QF+IIMSbpeNAf0AAAAAAAEBfx++dsi0OQG9AAAAAAAA=</peaks>
+</scan>
+</scan>
+</msRun>
+</mzXML>
+`
+
+	dir := t.TempDir()
+	in := filepath.Join(dir, "test.mzXML")
+	if e := os.WriteFile(in, []byte(mzXML), 0644); e != nil {
+		t.Fatal(e)
+	}
+
+	valid := filepath.Join(dir, "valid.mzML")
+	src := mzn.NewSource(in)
+	mzn.WriteMzML(src, valid, mzn.WriteOptions{MzPrecision: "64", IntensityPrecision: "32", Zlib: true})
+	src.Close()
+
+	b, e := os.ReadFile(valid)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	tests := []struct {
+		name   string
+		edit   func([]byte) []byte
+		levels []string
+		failed []string
+	}{
+		{"valid", func(b []byte) []byte { return b }, []string{"1", "2"}, nil},
+		{"modified", func(b []byte) []byte { return []byte(strings.Replace(string(b), `value="30"`, `value="31"`, 1)) }, []string{"1", "2"}, []string{"checksum"}},
+		{"truncated", func(b []byte) []byte { return b[:strings.LastIndex(string(b), "<binary>")+20] }, []string{"1", "2"}, []string{"parsing", "levels"}},
+		{"missing level", func(b []byte) []byte { return b }, []string{"1", "2", "3"}, []string{"levels"}},
+		{"unsupported encoding", func(b []byte) []byte {
+			return []byte(strings.Replace(string(b), `accession="MS:1000574"`, `accession="MS:1003090"`, 1))
+		}, []string{"1", "2"}, []string{"checksum", "arrays"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			f := filepath.Join(t.TempDir(), "test.mzML")
+			if e := os.WriteFile(f, tt.edit(b), 0644); e != nil {
+				t.Fatal(e)
+			}
+
+			g := mzn.CheckMzML(f, tt.levels)
+
+			var failed []string
+			for _, i := range g.Checks {
+				if !i.Passed {
+					failed = append(failed, i.Name)
+				}
+			}
+
+			if strings.Join(failed, ",") != strings.Join(tt.failed, ",") || g.Passed() != (len(tt.failed) == 0) {
+				t.Errorf("Failed checks are incorrect, got %v, want %v: %+v", failed, tt.failed, g.Checks)
+			}
+
+			if len(tt.failed) == 0 && (g.Scans["1"] != "1" || g.Scans["2"] != "2") {
+				t.Errorf("Scan levels are incorrect, got %v", g.Scans)
+			}
+		})
+	}
+}
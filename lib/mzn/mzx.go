@@ -0,0 +1,251 @@
+package mzn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/rogpeppe/go-charset/charset"
+)
+
+// MzXMLReader streams the scans of a mzXML file, nested and flat scan layouts are supported
+type MzXMLReader struct {
+	FileName string
+	file     *os.File
+	decoder  *xml.Decoder
+	parents  map[int]string
+}
+
+// mzXMLPrecursor is the precursorMz tag
+type mzXMLPrecursor struct {
+	ScanNum   string  `xml:"precursorScanNum,attr"`
+	Intensity float64 `xml:"precursorIntensity,attr"`
+	Charge    int     `xml:"precursorCharge,attr"`
+	Window    float64 `xml:"windowWideness,attr"`
+	Energy    float64 `xml:"activationEnergy,attr"`
+	Method    string  `xml:"activationMethod,attr"`
+	Value     string  `xml:",chardata"`
+}
+
+// mzXMLPeaks is the peaks tag, holding the interleaved m/z and intensity pairs
+type mzXMLPeaks struct {
+	Precision       string `xml:"precision,attr"`
+	ByteOrder       string `xml:"byteOrder,attr"`
+	CompressionType string `xml:"compressionType,attr"`
+	Value           string `xml:",chardata"`
+}
+
+// NewMzXMLReader opens a mzXML file
+func NewMzXMLReader(f string) *MzXMLReader {
+
+	file, e := os.Open(f)
+	if e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	r := &MzXMLReader{FileName: f, file: file}
+	r.Reset()
+
+	return r
+}
+
+// Close releases the mzXML file
+func (r *MzXMLReader) Close() {
+	r.file.Close()
+}
+
+// Reset moves the stream back to the first scan
+func (r *MzXMLReader) Reset() {
+
+	if _, e := r.file.Seek(0, io.SeekStart); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+
+	r.decoder = xml.NewDecoder(bufio.NewReader(r.file))
+	r.decoder.CharsetReader = charset.NewReader
+	r.parents = make(map[int]string)
+}
+
+// Next returns the following scan of the stream, peaks are decoded right away
+func (r *MzXMLReader) Next() (Spectrum, bool) {
+
+	var spec Spectrum
+	var level int
+
+	for {
+
+		t, e := r.decoder.Token()
+		if e == io.EOF {
+			return Spectrum{}, false
+		} else if e != nil {
+			msg.Custom(fmt.Errorf("cannot parse %s: %w", r.FileName, e), "fatal")
+		}
+
+		se, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch se.Name.Local {
+		case "scan":
+
+			spec = Spectrum{}
+			level = 1
+
+			for _, a := range se.Attr {
+				switch a.Name.Local {
+				case "num":
+					spec.Scan = a.Value
+					num, _ := strconv.Atoi(a.Value)
+					spec.Index = strconv.Itoa(num - 1)
+				case "msLevel":
+					level, _ = strconv.Atoi(a.Value)
+				case "retentionTime":
+					spec.ScanStartTime = parseDuration(a.Value) / 60
+				case "compensationVoltage":
+					spec.CompensationVoltage = a.Value
+				case "centroided":
+					spec.Profile = a.Value == "0"
+				case "polarity":
+					spec.Polarity = a.Value
+				}
+			}
+
+			spec.Level = strconv.Itoa(level)
+			r.parents[level] = spec.Scan
+
+		case "precursorMz":
+
+			var p mzXMLPrecursor
+			if e := r.decoder.DecodeElement(&p, &se); e != nil {
+				msg.Custom(fmt.Errorf("cannot parse %s: %w", r.FileName, e), "fatal")
+			}
+
+			mz, e := strconv.ParseFloat(strings.TrimSpace(p.Value), 64)
+			if e != nil {
+				msg.CastFloatToString(e, "error")
+			}
+
+			spec.Precursor.SelectedIon = mz
+			spec.Precursor.TargetIon = mz
+			spec.Precursor.SelectedIonIntensity = p.Intensity
+			spec.Precursor.ChargeState = p.Charge
+			spec.Precursor.ActivationEnergy = p.Energy
+			if _, ok := activationMethods[strings.ToUpper(p.Method)]; ok {
+				spec.Precursor.ActivationMethod = strings.ToUpper(p.Method)
+			}
+			spec.Precursor.IsolationWindowLowerOffset = p.Window / 2
+			spec.Precursor.IsolationWindowUpperOffset = p.Window / 2
+
+			// nested files omit the precursor scan number, the enclosing scan is the parent
+			spec.Precursor.ParentScan = p.ScanNum
+			if len(spec.Precursor.ParentScan) == 0 {
+				spec.Precursor.ParentScan = r.parents[level-1]
+			}
+
+			if len(spec.Precursor.ParentScan) > 0 {
+				parent, _ := strconv.Atoi(spec.Precursor.ParentScan)
+				spec.Precursor.ParentIndex = strconv.Itoa(parent - 1)
+			}
+
+		case "peaks":
+
+			var p mzXMLPeaks
+			if e := r.decoder.DecodeElement(&p, &se); e != nil {
+				msg.Custom(fmt.Errorf("cannot parse %s: %w", r.FileName, e), "fatal")
+			}
+
+			spec.Mz.DecodedStream, spec.Intensity.DecodedStream = decodePeaks(p)
+			spec.Mz.Precision = p.Precision
+			spec.Intensity.Precision = p.Precision
+
+			return spec, true
+		}
+	}
+}
+
+// decodePeaks splits the interleaved m/z and intensity values of a peaks tag
+func decodePeaks(p mzXMLPeaks) ([]float64, []float64) {
+
+	var mz, intensity []float64
+
+	data, e := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p.Value), ""))
+	if e != nil {
+		msg.Custom(fmt.Errorf("cannot decode the mzXML peaks: %w", e), "fatal")
+	}
+
+	if p.CompressionType == "zlib" && len(data) > 0 {
+		r, e := zlib.NewReader(bytes.NewReader(data))
+		if e != nil {
+			msg.ReadingMzMLZlib(e, "fatal")
+		}
+		data, e = io.ReadAll(r)
+		if e != nil {
+			msg.ReadingMzMLZlib(e, "fatal")
+		}
+	}
+
+	var order binary.ByteOrder = binary.BigEndian
+	if p.ByteOrder == "little" {
+		order = binary.LittleEndian
+	}
+
+	size := 4
+	if p.Precision == "64" {
+		size = 8
+	}
+
+	for i := 0; i+2*size <= len(data); i += 2 * size {
+		if size == 8 {
+			mz = append(mz, math.Float64frombits(order.Uint64(data[i:i+8])))
+			intensity = append(intensity, math.Float64frombits(order.Uint64(data[i+8:i+16])))
+		} else {
+			mz = append(mz, float64(math.Float32frombits(order.Uint32(data[i:i+4]))))
+			intensity = append(intensity, float64(math.Float32frombits(order.Uint32(data[i+4:i+8]))))
+		}
+	}
+
+	return mz, intensity
+}
+
+// parseDuration converts the xs:duration retention times (PT123.4S, PT2M3.4S) to seconds
+func parseDuration(s string) float64 {
+
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "P"), "T")
+
+	var seconds float64
+	var number string
+
+	for _, c := range s {
+		switch c {
+		case 'H', 'M', 'S':
+			v, e := strconv.ParseFloat(number, 64)
+			if e != nil {
+				msg.CastFloatToString(e, "error")
+			}
+			switch c {
+			case 'H':
+				seconds += v * 3600
+			case 'M':
+				seconds += v * 60
+			default:
+				seconds += v
+			}
+			number = ""
+		default:
+			number += string(c)
+		}
+	}
+
+	return seconds
+}
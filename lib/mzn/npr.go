@@ -0,0 +1,190 @@
+package mzn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// precisionTerms maps the binary data type cvParams to the precision codes
+var precisionTerms = map[string]string{
+	"MS:1000521": "32",
+	"MS:1000523": "64",
+	"MS:1000519": "i32",
+	"MS:1000522": "i64",
+}
+
+// compressionTerms maps the binary data compression cvParams to the compression codes
+var compressionTerms = map[string]string{
+	"MS:1000576": "0",
+	"MS:1000574": "1",
+	"MS:1002312": "linear",
+	"MS:1002313": "pic",
+	"MS:1002314": "slof",
+	"MS:1002746": "linear+zlib",
+	"MS:1002747": "pic+zlib",
+	"MS:1002748": "slof+zlib",
+}
+
+// unsupportedTerms are the binary encodings that cannot be decoded
+var unsupportedTerms = map[string]string{
+	"MS:1000520": "16-bit float",
+	"MS:1003088": "truncation, linear prediction and zlib compression",
+	"MS:1003089": "truncation, delta prediction and zlib compression",
+	"MS:1003090": "truncation and zlib compression",
+}
+
+// MS-Numpress decoders, ported from the reference implementation at https://github.com/ms-numpress/ms-numpress
+
+// decodeFixedPoint reads the big-endian scaling factor stored at the beginning of the linear and slof arrays
+func decodeFixedPoint(data []byte) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(data[:8]))
+}
+
+// decodeInt reads one integer from the half-byte stream, the first half-byte holds the number of leading
+// zero (0-8) or 0xf (9-15) half-bytes that were dropped from the value
+func decodeInt(data []byte, di *int, half *int) (uint32, error) {
+
+	var head byte
+	if *half == 0 {
+		head = data[*di] >> 4
+	} else {
+		head = data[*di] & 0xf
+		*di++
+	}
+	*half = 1 - *half
+
+	var res uint32
+	var n int
+
+	if head <= 8 {
+		n = int(head)
+	} else {
+		n = int(head) - 8
+		for i := 0; i < n; i++ {
+			res |= 0xf0000000 >> (4 * i)
+		}
+	}
+
+	if n == 8 {
+		return res, nil
+	}
+
+	if *di+((8-n)-(1-*half))/2 >= len(data) {
+		return 0, errors.New("corrupt MS-Numpress data")
+	}
+
+	for i := n; i < 8; i++ {
+		var hb byte
+		if *half == 0 {
+			hb = data[*di] >> 4
+		} else {
+			hb = data[*di] & 0xf
+			*di++
+		}
+		res |= uint32(hb) << ((i - n) * 4)
+		*half = 1 - *half
+	}
+
+	return res, nil
+}
+
+// decodeLinear restores values encoded as the residuals of a linear prediction from the two previous values
+func decodeLinear(data []byte) ([]float64, error) {
+
+	if len(data) == 8 {
+		return nil, nil
+	}
+
+	if len(data) < 12 {
+		return nil, errors.New("corrupt MS-Numpress linear data, not enough bytes for the first value")
+	}
+
+	fixedPoint := decodeFixedPoint(data)
+
+	var ints [3]int64
+	ints[1] = int64(binary.LittleEndian.Uint32(data[8:12]))
+
+	var result = []float64{float64(ints[1]) / fixedPoint}
+
+	if len(data) == 12 {
+		return result, nil
+	}
+
+	if len(data) < 16 {
+		return nil, errors.New("corrupt MS-Numpress linear data, not enough bytes for the second value")
+	}
+
+	ints[2] = int64(binary.LittleEndian.Uint32(data[12:16]))
+	result = append(result, float64(ints[2])/fixedPoint)
+
+	half := 0
+	di := 16
+
+	for di < len(data) {
+
+		// the last half-byte is padding
+		if di == len(data)-1 && half == 1 && data[di]&0xf == 0 {
+			break
+		}
+
+		ints[0] = ints[1]
+		ints[1] = ints[2]
+
+		buff, e := decodeInt(data, &di, &half)
+		if e != nil {
+			return nil, e
+		}
+
+		extrapol := ints[1] + (ints[1] - ints[0])
+		y := extrapol + int64(int32(buff))
+
+		result = append(result, float64(y)/fixedPoint)
+		ints[2] = y
+	}
+
+	return result, nil
+}
+
+// decodePic restores positive integers, such as ion counts, stored as half-byte integers
+func decodePic(data []byte) ([]float64, error) {
+
+	var result []float64
+
+	half := 0
+	di := 0
+
+	for di < len(data) {
+
+		if di == len(data)-1 && half == 1 && data[di]&0xf == 0 {
+			break
+		}
+
+		x, e := decodeInt(data, &di, &half)
+		if e != nil {
+			return nil, e
+		}
+
+		result = append(result, float64(x))
+	}
+
+	return result, nil
+}
+
+// decodeSlof restores values stored as fixed point 16-bit integers of log(x+1)
+func decodeSlof(data []byte) ([]float64, error) {
+
+	if len(data) < 8 || (len(data)-8)%2 != 0 {
+		return nil, errors.New("corrupt MS-Numpress slof data")
+	}
+
+	fixedPoint := decodeFixedPoint(data)
+
+	var result []float64
+	for i := 8; i < len(data); i += 2 {
+		x := binary.LittleEndian.Uint16(data[i : i+2])
+		result = append(result, math.Exp(float64(x)/fixedPoint)-1)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,148 @@
+package mzn
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+)
+
+// PickOptions defines how profile spectra are centroided
+type PickOptions struct {
+	// Apex is the fitting of the peak apex, gaussian or parabolic
+	Apex string
+	// SignalToNoise is the minimum ratio between the apex and the spectrum noise level
+	SignalToNoise float64
+}
+
+// Picking holds the settings used when profile spectra are decoded
+var Picking = PickOptions{Apex: "gaussian"}
+
+// NewPickOptions validates the peak picking parameters, the gaussian apex is the default
+func NewPickOptions(apex string, snr float64) PickOptions {
+
+	switch apex {
+	case "":
+		apex = "gaussian"
+	case "gaussian", "parabolic":
+	default:
+		msg.Custom(fmt.Errorf("unknown peak apex fitting: %s, use gaussian or parabolic", apex), "error")
+	}
+
+	if snr < 0 {
+		msg.Custom(fmt.Errorf("the signal to noise threshold cannot be negative: %.2f", snr), "error")
+	}
+
+	return PickOptions{Apex: apex, SignalToNoise: snr}
+}
+
+// Centroid replaces the points of a decoded profile spectrum by its picked peaks
+func (s *Spectrum) Centroid(o PickOptions) {
+
+	if !s.Profile {
+		return
+	}
+
+	s.Mz.DecodedStream, s.Intensity.DecodedStream = PickPeaks(s.Mz.DecodedStream, s.Intensity.DecodedStream, o)
+	s.IonMobility.DecodedStream = nil
+	s.Profile = false
+}
+
+// PickPeaks finds the local maxima of a profile spectrum and fits their apex with the two neighbouring
+// points, peaks below the signal to noise threshold are discarded. The noise level is the median of
+// the non-zero intensities, as most profile points of a spectrum are background
+func PickPeaks(mz, intensity []float64, o PickOptions) ([]float64, []float64) {
+
+	var peakMz, peakInt []float64
+
+	if len(mz) < 3 || len(mz) != len(intensity) {
+		return mz, intensity
+	}
+
+	noise := noiseLevel(intensity)
+
+	for i := 1; i < len(mz)-1; i++ {
+
+		if intensity[i] <= 0 || intensity[i] <= intensity[i-1] || intensity[i] < intensity[i+1] {
+			continue
+		}
+
+		apexMz, apexInt := fitApex(mz[i-1:i+2], intensity[i-1:i+2], o.Apex)
+
+		if o.SignalToNoise > 0 && noise > 0 && apexInt/noise < o.SignalToNoise {
+			continue
+		}
+
+		peakMz = append(peakMz, apexMz)
+		peakInt = append(peakInt, apexInt)
+	}
+
+	return peakMz, peakInt
+}
+
+// fitApex interpolates the apex of a peak from three points, the gaussian fit is a parabola on the
+// logarithm of the intensities and needs the three intensities to be positive
+func fitApex(mz, intensity []float64, method string) (float64, float64) {
+
+	y := []float64{intensity[0], intensity[1], intensity[2]}
+
+	gaussian := method != "parabolic" && y[0] > 0 && y[2] > 0
+	if gaussian {
+		for i := range y {
+			y[i] = math.Log(y[i])
+		}
+	}
+
+	// parabola through the three points, centered on the middle one
+	h1 := mz[1] - mz[0]
+	h2 := mz[2] - mz[1]
+	if h1 <= 0 || h2 <= 0 {
+		return mz[1], intensity[1]
+	}
+
+	d1 := (y[1] - y[0]) / h1
+	d2 := (y[2] - y[1]) / h2
+	a := (d2 - d1) / (h1 + h2)
+	b := d1 + a*h1
+
+	if a >= 0 {
+		return mz[1], intensity[1]
+	}
+
+	offset := -b / (2 * a)
+	if offset < -h1 || offset > h2 {
+		return mz[1], intensity[1]
+	}
+
+	apex := y[1] + b*offset + a*offset*offset
+	if gaussian {
+		apex = math.Exp(apex)
+	}
+
+	return mz[1] + offset, apex
+}
+
+// noiseLevel is the median of the non-zero intensities
+func noiseLevel(intensity []float64) float64 {
+
+	var values []float64
+	for _, i := range intensity {
+		if i > 0 {
+			values = append(values, i)
+		}
+	}
+
+	if len(values) == 0 {
+		return 0
+	}
+
+	sort.Float64s(values)
+
+	n := len(values)
+	if n%2 == 0 {
+		return (values[n/2-1] + values[n/2]) / 2
+	}
+
+	return values[n/2]
+}
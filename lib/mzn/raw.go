@@ -0,0 +1,143 @@
+package mzn
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Nesvilab/philosopher/lib/fin"
+	"github.com/Nesvilab/philosopher/lib/msg"
+)
+
+// RawReader streams the scans of a Thermo RAW file with the native reader, the instrument centroids
+// are returned unless Profile is set
+type RawReader struct {
+	FileName string
+	Profile  bool
+	raw      fin.RawData
+	next     int
+	parents  map[int]Spectrum
+}
+
+// NewRawReader opens a Thermo RAW file and loads its scan index
+func NewRawReader(f string) *RawReader {
+
+	r := &RawReader{FileName: f}
+	r.raw.ProcessRaw(f)
+	r.Reset()
+
+	return r
+}
+
+// Close releases the RAW file
+func (r *RawReader) Close() {
+	r.raw.Close()
+}
+
+// Reset moves the stream back to the first scan
+func (r *RawReader) Reset() {
+	r.next = 0
+	r.parents = make(map[int]Spectrum)
+}
+
+// Next returns the following scan of the stream with its centroided peaks
+func (r *RawReader) Next() (Spectrum, bool) {
+
+	if r.next >= r.raw.NScans() {
+		return Spectrum{}, false
+	}
+
+	r.next++
+
+	return rawSpectrum(r.next, r.raw.Scan(r.next), r.parents, !r.Profile), true
+}
+
+// Model returns the instrument model name stored on the RAW file
+func (r *RawReader) Model() string {
+	return r.raw.Model
+}
+
+// RawSpectrum converts a RAW scan with its centroided peaks, the parents map keeps the scan number and
+// index of the last spectrum seen at each MS level and is used to assign the precursor scans
+func RawSpectrum(sn int, scan fin.Scan, parents map[int]Spectrum) Spectrum {
+	return rawSpectrum(sn, scan, parents, true)
+}
+
+// rawSpectrum converts a RAW scan, profile scans keep their profile points when centroided is false
+func rawSpectrum(sn int, scan fin.Scan, parents map[int]Spectrum, centroided bool) Spectrum {
+
+	var spec Spectrum
+
+	level := int(scan.MSLevel)
+
+	spec.Scan = strconv.Itoa(sn)
+	spec.Index = strconv.Itoa(sn - 1)
+	spec.Level = strconv.Itoa(level)
+	spec.ScanStartTime = scan.Time
+	spec.Polarity = string(scan.Polarity)
+	spec.Profile = !centroided && string(scan.Mode) == "Profile"
+
+	// a scan closes the cycles of the higher levels, so they are not linked to the parents of an earlier cycle
+	parents[level] = Spectrum{Index: spec.Index, Scan: spec.Scan}
+	for l := range parents {
+		if l > level {
+			delete(parents, l)
+		}
+	}
+
+	if level > 1 && len(scan.Reaction) > 0 {
+
+		reaction := scan.Reaction[len(scan.Reaction)-1]
+
+		spec.Precursor.SelectedIon = reaction.Precursormz
+		spec.Precursor.TargetIon = reaction.Precursormz
+		spec.Precursor.ActivationEnergy = reaction.Energy
+
+		if parent, ok := parents[level-1]; ok {
+			spec.Precursor.ParentScan = parent.Scan
+			spec.Precursor.ParentIndex = parent.Index
+		} else {
+			msg.Custom(fmt.Errorf("scan %s has no MS%d scan before it, its precursor scan is left empty", spec.Scan, level-1), "warning")
+		}
+	}
+
+	// trailer extra values, the monoisotopic m/z is zero when the instrument could not assign it
+	spec.IonInjectionTime = scan.Trailer.Float("Ion Injection Time (ms)")
+	spec.MasterScan = scan.Trailer["Master Scan Number"]
+	spec.MonoisotopicMz = scan.Trailer.Float("Monoisotopic M/Z")
+	spec.AGCTarget = scan.Trailer.Float("AGC Target")
+	spec.FilterString = scan.FilterString
+
+	if cv, ok := scan.Trailer["FAIMS CV"]; ok {
+		spec.CompensationVoltage = cv
+	}
+
+	if level > 1 {
+		if z, e := strconv.Atoi(scan.Trailer["Charge State"]); e == nil && z > 0 {
+			spec.Precursor.ChargeState = z
+		}
+		if spec.MonoisotopicMz > 0 {
+			spec.Precursor.SelectedIon = spec.MonoisotopicMz
+		}
+		if w := scan.Trailer.Float("MS2 Isolation Width"); w > 0 {
+			spec.Precursor.IsolationWindowLowerOffset = w / 2
+			spec.Precursor.IsolationWindowUpperOffset = w / 2
+		}
+	}
+
+	if scan.Spectrum != nil {
+
+		peaks := scan.Spectrum(centroided)
+		sort.Sort(peaks)
+
+		for _, i := range peaks {
+			spec.Mz.DecodedStream = append(spec.Mz.DecodedStream, i.Mz)
+			spec.Intensity.DecodedStream = append(spec.Intensity.DecodedStream, float64(i.I))
+		}
+	}
+
+	spec.Mz.Precision = "64"
+	spec.Intensity.Precision = "32"
+
+	return spec
+}
@@ -0,0 +1,82 @@
+package mzn
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+)
+
+// Source streams the spectra of a MS data file one at a time
+type Source interface {
+	Next() (Spectrum, bool)
+	Reset()
+	Close()
+}
+
+// SourceFile returns the path of a spectra file, the format is used as the file extension and
+// the upper case extension is tried when the file does not exist, as in Thermo .RAW files
+func SourceFile(dir, name, format string) string {
+
+	f := fmt.Sprintf("%s%s%s.%s", dir, string(filepath.Separator), name, format)
+
+	if _, e := os.Stat(f); e != nil {
+		upper := fmt.Sprintf("%s%s%s.%s", dir, string(filepath.Separator), name, strings.ToUpper(format))
+		if _, e := os.Stat(upper); e == nil {
+			return upper
+		}
+	}
+
+	return f
+}
+
+// NewSource opens a spectra file with the reader matching its extension
+func NewSource(f string) Source {
+
+	switch strings.ToLower(filepath.Ext(f)) {
+	case ".mzml":
+		return NewMzMLReader(f)
+	case ".mzxml":
+		return NewMzXMLReader(f)
+	case ".mgf":
+		return NewMGFReader(f)
+	case ".raw":
+		return NewRawReader(f)
+	}
+
+	msg.Custom(fmt.Errorf("unknown spectra file format: %s", f), "fatal")
+
+	return nil
+}
+
+// Load reads all spectra of a source into memory, decoding the binary arrays of the given MS levels
+// and discarding the arrays of the others
+func Load(src Source, levels ...string) MsData {
+
+	var decode = make(map[string]bool)
+	for _, i := range levels {
+		decode[i] = true
+	}
+
+	var p MsData
+
+	src.Reset()
+	for s, ok := src.Next(); ok; s, ok = src.Next() {
+		if decode[s.Level] {
+			s.Decode()
+		} else {
+			s.Mz.Stream = nil
+			s.Intensity.Stream = nil
+			s.IonMobility.Stream = nil
+			s.Mz.DecodedStream = nil
+			s.Intensity.DecodedStream = nil
+			s.IonMobility.DecodedStream = nil
+		}
+		p.Spectra = append(p.Spectra, s)
+	}
+	src.Reset()
+
+	return p
+}
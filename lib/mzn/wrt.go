@@ -0,0 +1,501 @@
+package mzn
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Nesvilab/philosopher/lib/msg"
+	"github.com/Nesvilab/philosopher/lib/psi"
+)
+
+// spectrumIndent is the indentation of the spectrum tags inside the spectrum list
+const spectrumIndent = "      "
+
+// WriteOptions defines how the spectra are written to mzML
+type WriteOptions struct {
+	Levels             []string
+	MzPrecision        string
+	IntensityPrecision string
+	Zlib               bool
+	NoIndex            bool
+	PeakPicking        bool
+	Picking            PickOptions
+	Version            string
+}
+
+// countingWriter keeps the number of bytes written and the SHA-1 of the stream
+type countingWriter struct {
+	w    io.Writer
+	sha  hash.Hash
+	size int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, e := c.w.Write(p)
+	c.sha.Write(p[:n])
+	c.size += int64(n)
+	return n, e
+}
+
+// WriteMzML writes the spectra of a source as an indexed mzML 1.1 file and returns the number of
+// spectra written. Spectra are streamed to a temporary file first, the spectrum list count is only
+// known after the MS level filter is applied
+func WriteMzML(src Source, f string, o WriteOptions) int {
+
+	var keep = make(map[string]bool)
+	for _, i := range o.Levels {
+		keep[i] = true
+	}
+
+	tmp, e := os.CreateTemp(filepath.Dir(f), ".spectra-*")
+	if e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	idFormat := nativeIDFormat(src)
+
+	var ids []string
+	var offsets []int64
+	var position int64
+
+	tw := bufio.NewWriter(tmp)
+
+	src.Reset()
+	for s, ok := src.Next(); ok; s, ok = src.Next() {
+
+		if len(keep) > 0 && !keep[s.Level] {
+			continue
+		}
+
+		// profile spectra are only centroided when peak picking is requested
+		s.decodeArrays()
+		if o.PeakPicking {
+			s.Centroid(o.Picking)
+		}
+
+		spec := mzMLSpectrum(s, len(ids), idFormat, o)
+
+		b, e := xml.MarshalIndent(spec, spectrumIndent, "  ")
+		if e != nil {
+			msg.WriteFile(e, "fatal")
+		}
+
+		ids = append(ids, spec.ID)
+		offsets = append(offsets, position+int64(len(spectrumIndent)))
+
+		tw.Write(b)
+		tw.WriteString("\n")
+		position += int64(len(b)) + 1
+	}
+	src.Reset()
+
+	if e := tw.Flush(); e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+
+	out, e := os.Create(f)
+	if e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+	defer out.Close()
+
+	bw := bufio.NewWriter(out)
+	w := &countingWriter{w: bw, sha: sha1.New()}
+
+	io.WriteString(w, xml.Header)
+	if !o.NoIndex {
+		io.WriteString(w, `<indexedmzML xmlns="http://psi.hupo.org/ms/mzml" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://psi.hupo.org/ms/mzml http://psidev.info/files/ms/mzML/xsd/mzML1.1.2_idx.xsd">`+"\n")
+	}
+
+	name := strings.TrimSuffix(filepath.Base(f), filepath.Ext(f))
+	fmt.Fprintf(w, `  <mzML xmlns="http://psi.hupo.org/ms/mzml" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://psi.hupo.org/ms/mzml http://psidev.info/files/ms/mzML/xsd/mzML1.1.0.xsd" id="%s" version="1.1.0">`+"\n", escape(name))
+
+	for _, i := range mzMLHeader(src, idFormat, o) {
+		b, e := xml.MarshalIndent(i, "    ", "  ")
+		if e != nil {
+			msg.WriteFile(e, "fatal")
+		}
+		w.Write(b)
+		io.WriteString(w, "\n")
+	}
+
+	fmt.Fprintf(w, `    <run id="%s" defaultInstrumentConfigurationRef="IC1" defaultSourceFileRef="RAW1">`+"\n", escape(name))
+	fmt.Fprintf(w, `      <spectrumList count="%d" defaultDataProcessingRef="philosopher_conversion">`+"\n", len(ids))
+
+	base := w.size
+	if _, e := tmp.Seek(0, io.SeekStart); e != nil {
+		msg.ReadFile(e, "fatal")
+	}
+	if _, e := io.Copy(w, tmp); e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+
+	io.WriteString(w, "      </spectrumList>\n    </run>\n  </mzML>\n")
+
+	if !o.NoIndex {
+
+		indexOffset := w.size
+
+		io.WriteString(w, "  <indexList count=\"1\">\n    <index name=\"spectrum\">\n")
+		for i := range ids {
+			fmt.Fprintf(w, "      <offset idRef=\"%s\">%d</offset>\n", escape(ids[i]), base+offsets[i])
+		}
+		io.WriteString(w, "    </index>\n  </indexList>\n")
+		fmt.Fprintf(w, "  <indexListOffset>%d</indexListOffset>\n", indexOffset)
+
+		// the checksum covers the file up to and including the opening checksum tag
+		io.WriteString(w, "  <fileChecksum>")
+		fmt.Fprintf(bw, "%x</fileChecksum>\n</indexedmzML>\n", w.sha.Sum(nil))
+	}
+
+	if e := bw.Flush(); e != nil {
+		msg.WriteFile(e, "fatal")
+	}
+
+	return len(ids)
+}
+
+// nativeIDFormat returns the native ID prefix and the matching cvParam of the source type
+func nativeIDFormat(src Source) psi.CVParam {
+
+	if _, ok := src.(*RawReader); ok {
+		return psi.CVParam{CVRef: "MS", Accession: "MS:1000768", Name: "Thermo nativeID format", Value: "controllerType=0 controllerNumber=1 "}
+	}
+
+	return psi.CVParam{CVRef: "MS", Accession: "MS:1000776", Name: "scan number only nativeID format"}
+}
+
+// nativeID builds the spectrum identifier for a scan number
+func nativeID(idFormat psi.CVParam, scan string) string {
+	return fmt.Sprintf("%sscan=%s", idFormat.Value, strings.TrimLeft(scan, "0"))
+}
+
+// mzMLHeader builds the mzML elements preceding the run
+func mzMLHeader(src Source, idFormat psi.CVParam, o WriteOptions) []interface{} {
+
+	var header []interface{}
+
+	header = append(header, psi.CvList{
+		Count: 2,
+		CV: []psi.CV{
+			{ID: "MS", FullName: "Proteomics Standards Initiative Mass Spectrometry Ontology", Version: "4.1.30", URI: "https://raw.githubusercontent.com/HUPO-PSI/psi-ms-CV/master/psi-ms.obo"},
+			{ID: "UO", FullName: "Unit Ontology", Version: "09:04:2014", URI: "https://raw.githubusercontent.com/bio-ontology-research-group/unit-ontology/master/unit.obo"},
+		},
+	})
+
+	var fileName, location string
+	var format psi.CVParam
+
+	switch r := src.(type) {
+	case *RawReader:
+		fileName, location = r.FileName, r.FileName
+		format = psi.CVParam{CVRef: "MS", Accession: "MS:1000563", Name: "Thermo RAW format"}
+	case *MzXMLReader:
+		fileName, location = r.FileName, r.FileName
+		format = psi.CVParam{CVRef: "MS", Accession: "MS:1000566", Name: "ISB mzXML format"}
+	case *MGFReader:
+		fileName, location = r.FileName, r.FileName
+		format = psi.CVParam{CVRef: "MS", Accession: "MS:1001062", Name: "Mascot MGF format"}
+	case *MzMLReader:
+		fileName, location = r.FileName, r.FileName
+		format = psi.CVParam{CVRef: "MS", Accession: "MS:1000584", Name: "mzML format"}
+	}
+
+	if abs, e := filepath.Abs(location); e == nil {
+		location = abs
+	}
+
+	header = append(header, psi.FileDescription{
+		FileContent: psi.FileContent{
+			CVParam: []psi.CVParam{
+				{CVRef: "MS", Accession: "MS:1000579", Name: "MS1 spectrum"},
+				{CVRef: "MS", Accession: "MS:1000580", Name: "MSn spectrum"},
+			},
+		},
+		SourceFileList: psi.SourceFileList{
+			Count: 1,
+			SourceFile: []psi.MzMLSourceFile{{
+				ID:       "RAW1",
+				Name:     filepath.Base(fileName),
+				Location: "file://" + filepath.ToSlash(filepath.Dir(location)),
+				CVParam:  []psi.CVParam{{CVRef: idFormat.CVRef, Accession: idFormat.Accession, Name: idFormat.Name}, format},
+			}},
+		},
+	})
+
+	header = append(header, psi.SoftwareList{
+		Count: 1,
+		Software: []psi.Software{{
+			ID:      "philosopher",
+			Version: o.Version,
+			CVParam: []psi.CVParam{{CVRef: "MS", Accession: "MS:1000799", Name: "custom unreleased software tool", Value: "philosopher"}},
+		}},
+	})
+
+	var instrument = psi.InstrumentConfiguration{
+		ID:          "IC1",
+		CVParam:     []psi.CVParam{{CVRef: "MS", Accession: "MS:1000031", Name: "instrument model"}},
+		SoftwareRef: psi.SoftwareRef{Ref: "philosopher"},
+		ComponentList: psi.ComponentList{
+			Count:    3,
+			Source:   psi.Source{Order: 1, CVParam: []psi.CVParam{{CVRef: "MS", Accession: "MS:1000008", Name: "ionization type"}}},
+			Analyzer: psi.Analyzer{Order: 2, CVParam: []psi.CVParam{{CVRef: "MS", Accession: "MS:1000443", Name: "mass analyzer type"}}},
+			Detector: psi.Detector{Order: 3, CVParam: []psi.CVParam{{CVRef: "MS", Accession: "MS:1000026", Name: "detector type"}}},
+		},
+	}
+
+	if r, ok := src.(*RawReader); ok {
+		instrument.CVParam = []psi.CVParam{{CVRef: "MS", Accession: "MS:1000483", Name: "Thermo Fisher Scientific instrument model"}}
+		if len(r.Model()) > 0 {
+			instrument.UserParam = []psi.UserParam{{Name: "instrument model", Value: r.Model()}}
+		}
+	}
+
+	header = append(header, psi.InstrumentConfigurationList{Count: 1, InstrumentConfiguration: []psi.InstrumentConfiguration{instrument}})
+
+	var method = psi.ProcessingMethod{
+		Order:       1,
+		SoftwareRef: "philosopher",
+		CVParam:     []psi.CVParam{{CVRef: "MS", Accession: "MS:1000544", Name: "Conversion to mzML"}},
+	}
+
+	if o.PeakPicking {
+		method.CVParam = append(method.CVParam, psi.CVParam{CVRef: "MS", Accession: "MS:1000035", Name: "peak picking"})
+	}
+
+	header = append(header, psi.DataProcessingList{
+		Count:          1,
+		DataProcessing: []psi.DataProcessing{{ID: "philosopher_conversion", ProcessingMethod: []psi.ProcessingMethod{method}}},
+	})
+
+	return header
+}
+
+// mzMLSpectrum converts a spectrum to the mzML spectrum tag
+func mzMLSpectrum(s Spectrum, index int, idFormat psi.CVParam, o WriteOptions) psi.Spectrum {
+
+	var spec psi.Spectrum
+
+	mz := s.Mz.DecodedStream
+	intensity := s.Intensity.DecodedStream
+
+	spec.ID = nativeID(idFormat, s.Scan)
+	spec.Index = strconv.Itoa(index)
+	spec.DefaultArrayLength = float64(len(mz))
+
+	level := s.Level
+	if len(level) == 0 {
+		level = "1"
+	}
+
+	spec.CVParam = append(spec.CVParam, cvParam("MS:1000511", "ms level", level))
+
+	if level == "1" {
+		spec.CVParam = append(spec.CVParam, cvParam("MS:1000579", "MS1 spectrum", ""))
+	} else {
+		spec.CVParam = append(spec.CVParam, cvParam("MS:1000580", "MSn spectrum", ""))
+	}
+
+	if s.Profile {
+		spec.CVParam = append(spec.CVParam, cvParam("MS:1000128", "profile spectrum", ""))
+	} else {
+		spec.CVParam = append(spec.CVParam, cvParam("MS:1000127", "centroid spectrum", ""))
+	}
+
+	switch s.Polarity {
+	case "+":
+		spec.CVParam = append(spec.CVParam, cvParam("MS:1000130", "positive scan", ""))
+	case "-":
+		spec.CVParam = append(spec.CVParam, cvParam("MS:1000129", "negative scan", ""))
+	}
+
+	if len(mz) > 0 {
+
+		var tic, baseMz, baseInt float64
+		for i := range intensity {
+			tic += intensity[i]
+			if intensity[i] > baseInt {
+				baseInt = intensity[i]
+				baseMz = mz[i]
+			}
+		}
+
+		spec.CVParam = append(spec.CVParam,
+			unitParam(cvParam("MS:1000504", "base peak m/z", formatFloat(baseMz)), "MS", "MS:1000040", "m/z"),
+			unitParam(cvParam("MS:1000505", "base peak intensity", formatFloat(baseInt)), "MS", "MS:1000131", "number of detector counts"),
+			unitParam(cvParam("MS:1000285", "total ion current", formatFloat(tic)), "MS", "MS:1000131", "number of detector counts"),
+			unitParam(cvParam("MS:1000528", "lowest observed m/z", formatFloat(mz[0])), "MS", "MS:1000040", "m/z"),
+			unitParam(cvParam("MS:1000527", "highest observed m/z", formatFloat(mz[len(mz)-1])), "MS", "MS:1000040", "m/z"),
+		)
+	}
+
+	if len(s.CompensationVoltage) > 0 {
+		spec.CVParam = append(spec.CVParam, unitParam(cvParam("MS:1001581", "FAIMS compensation voltage", s.CompensationVoltage), "UO", "UO:0000218", "volt"))
+	}
+
+	var scan psi.Scan
+	scan.CVParam = append(scan.CVParam, unitParam(cvParam("MS:1000016", "scan start time", formatFloat(s.ScanStartTime)), "UO", "UO:0000031", "minute"))
+
+	if len(s.FilterString) > 0 {
+		scan.CVParam = append(scan.CVParam, cvParam("MS:1000512", "filter string", s.FilterString))
+	}
+
+	if s.IonInjectionTime > 0 {
+		scan.CVParam = append(scan.CVParam, unitParam(cvParam("MS:1000927", "ion injection time", formatFloat(s.IonInjectionTime)), "UO", "UO:0000028", "millisecond"))
+	}
+
+	// the trailer extra values without a cvParam keep the msconvert user parameter names
+	if s.MonoisotopicMz > 0 {
+		scan.UserParam = append(scan.UserParam, psi.UserParam{Name: "[Thermo Trailer Extra]Monoisotopic M/Z:", Type: "xsd:float", Value: formatFloat(s.MonoisotopicMz)})
+	}
+
+	if len(s.MasterScan) > 0 {
+		scan.UserParam = append(scan.UserParam, psi.UserParam{Name: "[Thermo Trailer Extra]Master Scan Number:", Type: "xsd:short", Value: s.MasterScan})
+	}
+
+	if s.AGCTarget > 0 {
+		scan.UserParam = append(scan.UserParam, psi.UserParam{Name: "[Thermo Trailer Extra]AGC Target:", Type: "xsd:int", Value: formatFloat(s.AGCTarget)})
+	}
+
+	spec.ScanList = psi.ScanList{
+		Count:   1,
+		CVParam: []psi.CVParam{cvParam("MS:1000795", "no combination", "")},
+		Scan:    []psi.Scan{scan},
+	}
+
+	if level != "1" {
+
+		var p psi.Precursor
+
+		if len(s.Precursor.ParentScan) > 0 {
+			p.SpectrumRef = nativeID(idFormat, s.Precursor.ParentScan)
+		}
+
+		target := s.Precursor.TargetIon
+		if target == 0 {
+			target = s.Precursor.SelectedIon
+		}
+
+		p.IsolationWindow.CVParam = []psi.CVParam{
+			unitParam(cvParam("MS:1000827", "isolation window target m/z", formatFloat(target)), "MS", "MS:1000040", "m/z"),
+			unitParam(cvParam("MS:1000828", "isolation window lower offset", formatFloat(s.Precursor.IsolationWindowLowerOffset)), "MS", "MS:1000040", "m/z"),
+			unitParam(cvParam("MS:1000829", "isolation window upper offset", formatFloat(s.Precursor.IsolationWindowUpperOffset)), "MS", "MS:1000040", "m/z"),
+		}
+
+		var ion psi.SelectedIon
+		ion.CVParam = append(ion.CVParam, unitParam(cvParam("MS:1000744", "selected ion m/z", formatFloat(s.Precursor.SelectedIon)), "MS", "MS:1000040", "m/z"))
+
+		if s.Precursor.ChargeState > 0 {
+			ion.CVParam = append(ion.CVParam, cvParam("MS:1000041", "charge state", strconv.Itoa(s.Precursor.ChargeState)))
+		}
+
+		if s.Precursor.SelectedIonIntensity > 0 {
+			ion.CVParam = append(ion.CVParam, unitParam(cvParam("MS:1000042", "peak intensity", formatFloat(s.Precursor.SelectedIonIntensity)), "MS", "MS:1000131", "number of detector counts"))
+		}
+
+		p.SelectedIonList = psi.SelectedIonList{Count: 1, SelectedIon: []psi.SelectedIon{ion}}
+
+		// the dissociation method is left out when the source does not tell it, as the RAW files
+		if term, ok := activationMethods[s.Precursor.ActivationMethod]; ok {
+			p.Activation.CVParam = append(p.Activation.CVParam, cvParam(term[0], term[1], ""))
+		}
+		if s.Precursor.ActivationEnergy > 0 {
+			p.Activation.CVParam = append(p.Activation.CVParam, unitParam(cvParam("MS:1000045", "collision energy", formatFloat(s.Precursor.ActivationEnergy)), "UO", "UO:0000266", "electronvolt"))
+		}
+
+		spec.PrecursorList = &psi.PrecursorList{Count: 1, Precursor: []psi.Precursor{p}}
+	}
+
+	spec.BinaryDataArrayList = psi.BinaryDataArrayList{
+		Count: 2,
+		BinaryDataArray: []psi.BinaryDataArray{
+			binaryDataArray(mz, o.MzPrecision, o.Zlib, cvParam("MS:1000514", "m/z array", ""), "MS", "MS:1000040", "m/z"),
+			binaryDataArray(intensity, o.IntensityPrecision, o.Zlib, cvParam("MS:1000515", "intensity array", ""), "MS", "MS:1000131", "number of detector counts"),
+		},
+	}
+
+	return spec
+}
+
+// binaryDataArray encodes an array with the given precision and compression
+func binaryDataArray(values []float64, precision string, compress bool, kind psi.CVParam, unitRef, unitAccession, unitName string) psi.BinaryDataArray {
+
+	var raw bytes.Buffer
+	for _, v := range values {
+		if precision == "32" {
+			binary.Write(&raw, binary.LittleEndian, math.Float32bits(float32(v)))
+		} else {
+			binary.Write(&raw, binary.LittleEndian, math.Float64bits(v))
+		}
+	}
+
+	data := raw.Bytes()
+
+	var cv []psi.CVParam
+
+	if precision == "32" {
+		cv = append(cv, cvParam("MS:1000521", "32-bit float", ""))
+	} else {
+		cv = append(cv, cvParam("MS:1000523", "64-bit float", ""))
+	}
+
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(data)
+		zw.Close()
+		data = z.Bytes()
+		cv = append(cv, cvParam("MS:1000574", "zlib compression", ""))
+	} else {
+		cv = append(cv, cvParam("MS:1000576", "no compression", ""))
+	}
+
+	cv = append(cv, unitParam(kind, unitRef, unitAccession, unitName))
+
+	encoded := []byte(base64.StdEncoding.EncodeToString(data))
+
+	return psi.BinaryDataArray{
+		EncodedLength: float64(len(encoded)),
+		CVParam:       cv,
+		Binary:        psi.Binary{Value: encoded},
+	}
+}
+
+// cvParam builds a PSI-MS controlled vocabulary term
+func cvParam(accession, name, value string) psi.CVParam {
+	return psi.CVParam{CVRef: "MS", Accession: accession, Name: name, Value: value}
+}
+
+// unitParam adds the unit to a controlled vocabulary term
+func unitParam(cv psi.CVParam, unitRef, unitAccession, unitName string) psi.CVParam {
+	cv.UnitCvRef = unitRef
+	cv.UnitAccession = unitAccession
+	cv.UnitName = unitName
+	return cv
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// escape replaces the XML special characters of attribute values
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
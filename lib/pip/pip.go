@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/Nesvilab/philosopher/lib/dat"
 	"github.com/Nesvilab/philosopher/lib/msg"
@@ -22,12 +23,16 @@ import (
 	"github.com/Nesvilab/philosopher/lib/qua"
 	"github.com/Nesvilab/philosopher/lib/rep"
 
-	"github.com/Nesvilab/philosopher/lib/ext/comet"
-	"github.com/Nesvilab/philosopher/lib/ext/msfragger"
+	"github.com/Nesvilab/philosopher/lib/eng"
 	"github.com/Nesvilab/philosopher/lib/met"
 	"github.com/Nesvilab/philosopher/lib/sys"
 	"github.com/Nesvilab/philosopher/lib/wrk"
 
+	// the search engines register themselves on the eng package
+	_ "github.com/Nesvilab/philosopher/lib/ext/comet"
+	_ "github.com/Nesvilab/philosopher/lib/ext/msfragger"
+	_ "github.com/Nesvilab/philosopher/lib/ext/sage"
+
 	"github.com/ryanskidmore/parallel"
 	"github.com/sirupsen/logrus"
 )
@@ -73,6 +78,9 @@ type DatabaseSearch struct {
 	DecoyTag        string        `yaml:"decoy_tag"`
 	MSFragger       met.MSFragger `yaml:"msfragger"`
 	Comet           met.Comet     `yaml:"comet"`
+	Sage            met.Sage      `yaml:"sage"`
+	// Parameters are the options of the search engine, as named on its yaml fields
+	Parameters map[string]interface{} `yaml:"parameters"`
 }
 
 // DeployParameterFile deploys the pipeline yaml config file
@@ -164,7 +172,7 @@ func AnnotateDatabase(meta met.Data, p Directives, dir string, data []string) me
 	return meta
 }
 
-// DBSearch executes the search engines if requested
+// DBSearch executes the search engine named on the configuration on the spectra files of all data sets
 func DBSearch(meta met.Data, p Directives, dir string, data []string) met.Data {
 
 	logrus.Info("Running the Database Search")
@@ -172,71 +180,51 @@ func DBSearch(meta met.Data, p Directives, dir string, data []string) met.Data {
 	// reload the meta data
 	meta.Restore(sys.Meta())
 
-	var mzFiles []string
-
-	if p.DatabaseSearch.SearchEngine == "comet" {
-
-		for _, i := range data {
-
-			// getting inside de the dataset folder
-			dsAbs, _ := filepath.Abs(i)
-			os.Chdir(dsAbs)
-
-			meta.Comet = p.DatabaseSearch.Comet
-			gobExtC := fmt.Sprintf("*.%s", p.DatabaseSearch.Comet.RawExtension)
-			filesC, e := filepath.Glob(gobExtC)
-			if e != nil {
-				msg.Custom(e, "error")
-			}
+	engine, ok := eng.Get(p.DatabaseSearch.SearchEngine)
+	if !ok {
+		msg.Custom(fmt.Errorf("the search engine %s is not supported, the available engines are %s", p.DatabaseSearch.SearchEngine, strings.Join(eng.Names(), ", ")), "error")
+	}
 
-			if len(filesC) > 0 {
-				for _, j := range filesC {
-					f, _ := filepath.Abs(j)
-					mzFiles = append(mzFiles, f)
-				}
-			}
+	params, e := p.DatabaseSearch.parameters()
+	if e != nil {
+		msg.Custom(e, "error")
+	}
 
-			// return to the top level directory
-			os.Chdir(dir)
-		}
+	search := eng.Search{
+		Database: p.DatabaseSearch.ProteinDatabase,
+		DecoyTag: p.DatabaseSearch.DecoyTag,
+		Params:   params,
+	}
 
-		comet.Run(meta, mzFiles)
-		meta.SearchEngine = "Comet"
+	e = engine.Prepare(&meta, search)
+	if e != nil {
+		msg.Custom(fmt.Errorf("the %s parameters are not valid: %w", engine.Name(), e), "error")
 	}
 
-	if p.DatabaseSearch.SearchEngine == "msfragger" {
+	if version, e := engine.Version(meta); e == nil {
+		logrus.Info("Searching with ", version)
+	}
 
-		for _, i := range data {
+	var mzFiles []string
 
-			// getting inside de the dataset folder
-			dsAbs, _ := filepath.Abs(i)
-			os.Chdir(dsAbs)
+	for _, i := range data {
 
-			meta.MSFragger = p.DatabaseSearch.MSFragger
-			meta.MSFragger.DatabaseName = p.DatabaseSearch.ProteinDatabase
-			meta.MSFragger.DecoyPrefix = p.DatabaseSearch.DecoyTag
+		dsAbs, _ := filepath.Abs(i)
 
-			gobExtM := fmt.Sprintf("*.%s", p.DatabaseSearch.MSFragger.Extension)
-			filesM, e := filepath.Glob(gobExtM)
-			if e != nil {
-				msg.Custom(e, "error")
-			}
+		files, e := filepath.Glob(filepath.Join(dsAbs, fmt.Sprintf("*.%s", engine.Extension(meta))))
+		if e != nil {
+			msg.Custom(e, "error")
+		}
 
-			if len(filesM) > 0 {
-				for _, j := range filesM {
-					f, _ := filepath.Abs(j)
-					mzFiles = append(mzFiles, f)
-				}
-			}
+		mzFiles = append(mzFiles, files...)
+	}
 
-			// return to the top level directory
-			os.Chdir(dir)
-		}
+	meta = engine.Run(meta, mzFiles)
+	meta.SearchEngine = engine.Name()
 
-		// MSFragger
-		if p.DatabaseSearch.SearchEngine == "msfragger" {
-			msfragger.Run(meta, mzFiles)
-			meta.SearchEngine = "MSFragger"
+	for _, i := range engine.Outputs(meta, mzFiles) {
+		if _, e := os.Stat(i); e != nil {
+			msg.Custom(fmt.Errorf("the %s output %s was not found", engine.Name(), i), "warning")
 		}
 	}
 
@@ -245,6 +233,26 @@ func DBSearch(meta met.Data, p Directives, dir string, data []string) met.Data {
 	return meta
 }
 
+// parameters returns the free-form parameters of the search engine, the engine sections of the older
+// configuration files are used when there are none
+func (d DatabaseSearch) parameters() (map[string]interface{}, error) {
+
+	if len(d.Parameters) > 0 {
+		return d.Parameters, nil
+	}
+
+	switch strings.ToLower(d.SearchEngine) {
+	case "comet":
+		return eng.Encode(d.Comet)
+	case "msfragger":
+		return eng.Encode(d.MSFragger)
+	case "sage":
+		return eng.Encode(d.Sage)
+	}
+
+	return nil, nil
+}
+
 // PeptideProphet executes PeptideProphet in Parallel mode
 func PeptideProphet(meta met.Data, p Directives, dir string, data []string) met.Data {
 
@@ -647,6 +655,11 @@ func Filter(meta met.Data, p Directives, dir string, data []string) met.Data {
 			meta.Filter = p.Filter
 			meta.Filter.Tag = p.DatabaseSearch.DecoyTag
 
+			// the Sage PSMs are read from the results table when they are not rescored
+			if strings.EqualFold(p.DatabaseSearch.SearchEngine, "sage") && len(p.Filter.Sage) == 0 && len(p.Filter.Percolator) == 0 && p.Steps.PeptideValidation != "yes" {
+				meta.Filter.Sage = "results.sage.tsv"
+			}
+
 			if len(p.Filter.Pex) == 0 {
 				meta.Filter.Pex = "interact.pep.xml"
 				if p.Steps.PTMLocalization == "yes" {
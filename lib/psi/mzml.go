@@ -6,9 +6,32 @@ import (
 
 // IndexedMzML is the root level tag
 type IndexedMzML struct {
-	XMLName xml.Name `xml:"indexedmzML"`
-	Name    string
-	MzML    MzML `xml:"mzML"`
+	XMLName         xml.Name `xml:"indexedmzML"`
+	Name            string
+	MzML            MzML      `xml:"mzML"`
+	IndexList       IndexList `xml:"indexList"`
+	IndexListOffset int64     `xml:"indexListOffset"`
+}
+
+// IndexList holds the byte offsets of the spectra and chromatograms of an indexed mzML file
+type IndexList struct {
+	XMLName xml.Name `xml:"indexList"`
+	Count   int      `xml:"count,attr"`
+	Index   []Index  `xml:"index"`
+}
+
+// Index lists the offsets of one element type, either spectrum or chromatogram
+type Index struct {
+	XMLName xml.Name `xml:"index"`
+	Name    string   `xml:"name,attr"`
+	Offset  []Offset `xml:"offset"`
+}
+
+// Offset is the byte position of the element referenced by its native ID
+type Offset struct {
+	XMLName xml.Name `xml:"offset"`
+	IDRef   string   `xml:"idRef,attr"`
+	Value   int64    `xml:",chardata"`
 }
 
 // MzML This is the root element for the Proteomics Standards Initiative (PSI) mzML schema, which is intended to
@@ -19,7 +42,7 @@ type MzML struct {
 	ID                          string                      `xml:"id,attr"`
 	Version                     string                      `xml:"version,attr"`
 	CvList                      CvList                      `xml:"cvList"`
-	FileDescription             FileDescription             `ml:"fileDescription"`
+	FileDescription             FileDescription             `xml:"fileDescription"`
 	RefParamGroupList           RefParamGroupList           `xml:"referenceableParamGroupList"`
 	SampleList                  SampleList                  `xml:"sampleList"`
 	SoftwareList                SoftwareList                `xml:"softwareList"`
@@ -149,14 +172,14 @@ type SourceFileRef struct {
 
 // MzMLSourceFile is a file from which this instance was created
 type MzMLSourceFile struct {
-	XMLName                     xml.Name                    `xml:"sourceFile"`
-	ID                          string                      `xml:"id,attr"`
-	Location                    string                      `xml:"location,attr"`
-	Name                        string                      `xml:"name,attr"`
-	ExternalFormatDocumentation ExternalFormatDocumentation `xml:"ExternalFormatDocumentation"`
-	FileFormat                  FileFormat                  `xml:"FileFormat"`
-	CVParam                     []CVParam                   `xml:"cvParam"`
-	UserParam                   []UserParam                 `xml:"userParam"`
+	XMLName                     xml.Name                     `xml:"sourceFile"`
+	ID                          string                       `xml:"id,attr"`
+	Location                    string                       `xml:"location,attr"`
+	Name                        string                       `xml:"name,attr"`
+	ExternalFormatDocumentation *ExternalFormatDocumentation `xml:"ExternalFormatDocumentation"`
+	FileFormat                  *FileFormat                  `xml:"FileFormat"`
+	CVParam                     []CVParam                    `xml:"cvParam"`
+	UserParam                   []UserParam                  `xml:"userParam"`
 }
 
 // RefParamGroupList is the container for a list of referenceableParamGroups
@@ -271,18 +294,18 @@ type SpectrumList struct {
 // Spectrum tag
 type Spectrum struct {
 	XMLName             xml.Name            `xml:"spectrum"`
-	DataProcessingRef   string              `xml:"dataProcessingRef,attr"`
+	DataProcessingRef   string              `xml:"dataProcessingRef,attr,omitempty"`
 	DefaultArrayLength  float64             `xml:"defaultArrayLength,attr"`
 	ID                  string              `xml:"id,attr"`
 	Index               string              `xml:"index,attr"`
-	SourceFileRef       string              `xml:"sourceFileRef,attr"`
-	SpotID              string              `xml:"spotID,attr"`
+	SourceFileRef       string              `xml:"sourceFileRef,attr,omitempty"`
+	SpotID              string              `xml:"spotID,attr,omitempty"`
 	CVParam             []CVParam           `xml:"cvParam"`
 	ScanList            ScanList            `xml:"scanList"`
-	PrecursorList       PrecursorList       `xml:"precursorList"`
+	PrecursorList       *PrecursorList      `xml:"precursorList"`
 	BinaryDataArrayList BinaryDataArrayList `xml:"binaryDataArrayList"`
-	Peaks               []float64
-	Intensities         []float64
+	Peaks               []float64           `xml:"-"`
+	Intensities         []float64           `xml:"-"`
 }
 
 // ScanList tag
@@ -303,7 +326,7 @@ type PrecursorList struct {
 // Precursor tag
 type Precursor struct {
 	XMLName         xml.Name        `xml:"precursor"`
-	SpectrumRef     string          `xml:"spectrumRef,attr"`
+	SpectrumRef     string          `xml:"spectrumRef,attr,omitempty"`
 	UserParam       []UserParam     `xml:"userParam"`
 	IsolationWindow IsolationWindow `xml:"isolationWindow"`
 	SelectedIonList SelectedIonList `xml:"selectedIonList"`
@@ -312,7 +335,7 @@ type Precursor struct {
 
 // IsolationWindow tag
 type IsolationWindow struct {
-	InstConfigurationRef string      `xml:"isolationWindow,attr"`
+	InstConfigurationRef string      `xml:"isolationWindow,attr,omitempty"`
 	CVParam              []CVParam   `xml:"cvParam"`
 	UserParam            []UserParam `xml:"userParam"`
 }
@@ -332,11 +355,11 @@ type SelectedIon struct {
 
 // Scan tag
 type Scan struct {
-	XMLName              xml.Name       `xml:"scan"`
-	InstConfigurationRef string         `xml:"instrumentConfigurationRef,attr"`
-	CVParam              []CVParam      `xml:"cvParam"`
-	UserParam            []UserParam    `xml:"userParam"`
-	ScanWindowList       ScanWindowList `xml:"scanWindowList"`
+	XMLName              xml.Name        `xml:"scan"`
+	InstConfigurationRef string          `xml:"instrumentConfigurationRef,attr,omitempty"`
+	CVParam              []CVParam       `xml:"cvParam"`
+	UserParam            []UserParam     `xml:"userParam"`
+	ScanWindowList       *ScanWindowList `xml:"scanWindowList"`
 }
 
 // ScanWindowList tag
@@ -13,7 +13,6 @@ import (
 	"github.com/Nesvilab/philosopher/lib/sys"
 
 	"github.com/rogpeppe/go-charset/charset"
-	"github.com/sirupsen/logrus"
 
 	// anon charset
 	_ "github.com/rogpeppe/go-charset/data"
@@ -136,7 +135,7 @@ func (p *MzIdentML) Parse(f string) {
 	decoder.CharsetReader = charset.NewReader
 
 	if e = decoder.Decode(p); e != nil {
-		logrus.Trace("Cannot decode XML file:", e)
+		msg.Custom(fmt.Errorf("cannot decode the mzIdentML file %s: %w", filepath.Base(f), e), "error")
 	}
 
 }
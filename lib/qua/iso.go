@@ -24,15 +24,18 @@ const (
 )
 
 // prepareLabelStructureWithMS2 instantiates the Label objects and maps them against the fragment scans in order to get the channel intensities
-func prepareLabelStructureWithMS2(dir, format, brand, plex string, tol float64, mz mzn.MsData) map[string]iso.Labels {
+func prepareLabelStructureWithMS2(dir, format, brand, plex string, tol float64, r mzn.Source) map[string]iso.Labels {
 
 	// get all spectra names from PSMs and create the label list
 	var labels = make(map[string]iso.Labels)
 	ppmPrecision := tol / math.Pow(10, 6)
 
-	for _, i := range mz.Spectra {
+	r.Reset()
+	for i, ok := r.Next(); ok; i, ok = r.Next() {
 		if i.Level == "2" {
 
+			i.Decode()
+
 			var labelData iso.Labels
 			if brand == "tmt" {
 				labelData = tmt.New(plex)
@@ -265,15 +268,18 @@ func prepareLabelStructureWithMS2(dir, format, brand, plex string, tol float64,
 }
 
 // prepareLabelStructureWithMS3 instantiates the Label objects and maps them against the fragment scans in order to get the channel intensities
-func prepareLabelStructureWithMS3(dir, format, brand, plex string, tol float64, mz mzn.MsData) map[string]iso.Labels {
+func prepareLabelStructureWithMS3(dir, format, brand, plex string, tol float64, r mzn.Source) map[string]iso.Labels {
 
 	// get all spectra names from PSMs and create the label list
 	var labels = make(map[string]iso.Labels)
 	ppmPrecision := tol / math.Pow(10, 6)
 
-	for _, i := range mz.Spectra {
+	r.Reset()
+	for i, ok := r.Next(); ok; i, ok = r.Next() {
 		if i.Level == "3" {
 
+			i.Decode()
+
 			var labelData iso.Labels
 			if brand == "tmt" {
 				labelData = tmt.New(plex)
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -33,7 +32,7 @@ func NewLFQ() LFQ {
 	return self
 }
 
-func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float64, isIso, isRaw, isFaims bool) rep.Evidence {
+func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float64, isIso, isFaims bool) rep.Evidence {
 
 	logrus.Info("Indexing PSM information")
 
@@ -81,26 +80,16 @@ func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float
 	for _, s := range sourceList {
 
 		logrus.Info("Processing ", s)
-		var mz mzn.MsData
-		var fileName string
-
-		if isRaw {
-			//fileName = fmt.Sprintf("%s%s%s.raw", dir, string(filepath.Separator), s)
-			//stream := rawfilereader.Run(fileName, "")
-			//mz.ReadRaw(s, stream)
-		} else {
-			fileName = fmt.Sprintf("%s%s%s.mzML", dir, string(filepath.Separator), s)
-			mz.Read(fileName)
-		}
+		// only the MS1 peaks are needed for tracing, the fragment arrays are never decoded
+		src := mzn.NewSource(mzn.SourceFile(dir, s, format))
+		mz := mzn.Load(src, "1")
+		src.Close()
 
 		for i := range mz.Spectra {
 
 			spectrum := fmt.Sprintf("%s.%05s.%05s.%d", s, mz.Spectra[i].Scan, mz.Spectra[i].Scan, mz.Spectra[i].Precursor.ChargeState)
 
 			if mz.Spectra[i].Level == "1" {
-				if !isRaw {
-					mz.Spectra[i].Decode()
-				}
 
 				if isFaims {
 					mzCVMap[mz.Spectra[i].Scan] = mz.Spectra[i].CompensationVoltage
@@ -121,6 +110,7 @@ func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float
 			if ok {
 				psm := v
 				psm.Purity = j.Purity
+				copyScanMetadata(&psm, j)
 				psmMap[j.SpectrumFileName()] = psm
 			}
 		}
@@ -178,6 +168,7 @@ func peakIntensity(evi rep.Evidence, dir, format string, rTWin, pTWin, tol float
 		v, ok := psmMap[evi.PSM[i].SpectrumFileName()]
 		if ok {
 			evi.PSM[i].Purity = v.Purity
+			copyScanMetadata(&evi.PSM[i], v)
 			puritySum += v.Purity
 		}
 	}
@@ -6,7 +6,6 @@ import (
 	"github.com/Nesvilab/philosopher/lib/ibt"
 	"math"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 
@@ -45,6 +44,9 @@ func RunLabelFreeQuantification(p met.Quantify) {
 	// This parameter is hardcoded now because of the changes in the latest msconvert version 3.20.
 	p.Isolated = true
 
+	// profile spectra are centroided when decoded
+	mzn.Picking = mzn.NewPickOptions(p.PeakApex, p.SNR)
+
 	var evi rep.Evidence
 	evi.RestoreGranular()
 
@@ -53,7 +55,7 @@ func RunLabelFreeQuantification(p met.Quantify) {
 		os.Exit(0)
 	}
 
-	evi = peakIntensity(evi, p.Dir, p.Format, p.RTWin, p.PTWin, p.Tol, p.Isolated, p.Raw, p.Faims)
+	evi = peakIntensity(evi, p.Dir, p.Format, p.RTWin, p.PTWin, p.Tol, p.Isolated, p.Faims)
 
 	evi = calculateIntensities(evi)
 
@@ -72,6 +74,9 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 		msg.NoParametersFound(errors.New("you need to specify a brand type (tmt or itraq)"), "error")
 	}
 
+	// profile spectra are centroided when decoded
+	mzn.Picking = mzn.NewPickOptions(p.PeakApex, p.SNR)
+
 	var evi rep.Evidence
 	evi.RestoreGranular()
 
@@ -104,39 +109,33 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 		p.LabelNames = uti.GetLabelNames(p.Annot)
 	}
 
+	// the precursor purity is calculated from the MS1 scans
+	if p.Format == "mgf" && p.Purity > 0 {
+		msg.Custom(errors.New("MGF files have no MS1 scans, the ion purity filter will be ignored"), "warning")
+		p.Purity = 0
+	}
+
 	logrus.Info("Calculating intensities and ion interference")
 
 	for i := range sourceList {
 
-		var mz mzn.MsData
-		var fileName string
-
 		logrus.Info("Processing ", sourceList[i])
 
-		if p.Raw {
-			//fileName = fmt.Sprintf("%s%s%s.raw", p.Dir, string(filepath.Separator), sourceList[i])
-			//stream := rawfilereader.Run(fileName, "")
-			//mz.ReadRaw(fileName, stream)
-
-		} else {
+		src := mzn.NewSource(mzn.SourceFile(p.Dir, sourceList[i], p.Format))
 
-			fileName = fmt.Sprintf("%s%s%s.mzML", p.Dir, string(filepath.Separator), sourceList[i])
-			mz.Read(fileName)
-
-			for i := range mz.Spectra {
-				mz.Spectra[i].Decode()
-			}
-		}
+		// the purity only needs the MS1 peaks, the reporter scans are streamed afterwards
+		mz := mzn.Load(src, "1")
 
 		mappedPurity := calculateIonPurity(p.Dir, p.Format, mz, sourceMap[sourceList[i]])
 
 		var labels map[string]iso.Labels
 		if p.Level == 3 {
-			labels = prepareLabelStructureWithMS3(p.Dir, p.Format, p.Brand, p.Plex, p.Tol, mz)
+			labels = prepareLabelStructureWithMS3(p.Dir, p.Format, p.Brand, p.Plex, p.Tol, src)
 
 		} else {
-			labels = prepareLabelStructureWithMS2(p.Dir, p.Format, p.Brand, p.Plex, p.Tol, mz)
+			labels = prepareLabelStructureWithMS2(p.Dir, p.Format, p.Brand, p.Plex, p.Tol, src)
 		}
+		src.Close()
 
 		labels = assignLabelNames(labels, p.LabelNames, p.Brand, p.Plex)
 
@@ -147,6 +146,7 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 			if ok {
 				psm := v
 				psm.Purity = j.Purity
+				copyScanMetadata(&psm, j)
 				psmMap[j.SpectrumFileName()] = psm
 			}
 		}
@@ -167,6 +167,7 @@ func RunIsobaricLabelQuantification(p met.Quantify, mods bool) met.Quantify {
 		if ok {
 			evi.PSM[i].Purity = v.Purity
 			evi.PSM[i].Labels = v.Labels
+			copyScanMetadata(&evi.PSM[i], v)
 		}
 	}
 	//psmMap = nil
@@ -1184,6 +1185,15 @@ func classification(evi rep.Evidence, mods, best bool, remove, purity, probabili
 	return spectrumMap, phosphoSpectrumMap
 }
 
+// copyScanMetadata transfers the acquisition values of the fragment scan between PSMs
+func copyScanMetadata(dst *rep.PSMEvidence, src rep.PSMEvidence) {
+	dst.IonInjectionTime = src.IonInjectionTime
+	dst.MasterScan = src.MasterScan
+	dst.MonoisotopicMz = src.MonoisotopicMz
+	dst.AGCTarget = src.AGCTarget
+	dst.FilterString = src.FilterString
+}
+
 // calculateIonPurity verifies how much interference there is on the precursor scans for each fragment
 func calculateIonPurity(d, f string, mz mzn.MsData, evi []rep.PSMEvidence) []rep.PSMEvidence {
 
@@ -1258,6 +1268,12 @@ func calculateIonPurity(d, f string, mz mzn.MsData, evi []rep.PSMEvidence) []rep
 		v2, ok := indexedMS2[split[1]]
 		if ok {
 
+			evi[i].IonInjectionTime = v2.IonInjectionTime
+			evi[i].MasterScan = v2.MasterScan
+			evi[i].MonoisotopicMz = v2.MonoisotopicMz
+			evi[i].AGCTarget = v2.AGCTarget
+			evi[i].FilterString = v2.FilterString
+
 			v1 := indexedMS1[v2.Precursor.ParentScan]
 
 			var ions = make(map[float64]float64)
@@ -0,0 +1,133 @@
+package rep
+
+import (
+	"fmt"
+
+	"github.com/Nesvilab/philosopher/lib/cla"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EntrapmentSummary compares the target-decoy FDR estimate with the FDR observed on entrapment sequences
+type EntrapmentSummary struct {
+	Level         string
+	Targets       int
+	Entrapments   int
+	Decoys        int
+	DecoyFDR      float64
+	EntrapmentFDR float64
+}
+
+// newEntrapmentSummary estimates the entrapment FDR with the combined method, where the
+// entrapment hits are scaled by the entrapment to target database size ratio
+func newEntrapmentSummary(level string, targets, entrapments, decoys int, ratio float64) EntrapmentSummary {
+
+	s := EntrapmentSummary{Level: level, Targets: targets, Entrapments: entrapments, Decoys: decoys}
+
+	accepted := float64(targets + entrapments)
+	if accepted == 0 {
+		return s
+	}
+
+	s.DecoyFDR = float64(decoys) / accepted
+
+	if ratio > 0 {
+		s.EntrapmentFDR = float64(entrapments) * (1 + 1/ratio) / accepted
+	}
+
+	return s
+}
+
+// EntrapmentFDR calculates the entrapment FDR for the PSMs
+func (evi PSMEvidenceList) EntrapmentFDR(entrapTag string, ratio float64) EntrapmentSummary {
+
+	var targets, entrapments, decoys int
+
+	for _, i := range evi {
+
+		if i.IsDecoy {
+			decoys++
+			continue
+		}
+
+		var alt []string
+		for j := range i.MappedProteins {
+			alt = append(alt, j)
+		}
+
+		if cla.IsEntrapmentPSM(i.Protein, alt, entrapTag) {
+			entrapments++
+		} else {
+			targets++
+		}
+	}
+
+	return newEntrapmentSummary("PSM", targets, entrapments, decoys, ratio)
+}
+
+// EntrapmentFDR calculates the entrapment FDR for the peptides
+func (evi PeptideEvidenceList) EntrapmentFDR(entrapTag string, ratio float64) EntrapmentSummary {
+
+	var targets, entrapments, decoys int
+
+	for _, i := range evi {
+
+		if i.IsDecoy {
+			decoys++
+			continue
+		}
+
+		var alt []string
+		for j := range i.MappedProteins {
+			alt = append(alt, j)
+		}
+
+		if cla.IsEntrapmentPSM(i.Protein, alt, entrapTag) {
+			entrapments++
+		} else {
+			targets++
+		}
+	}
+
+	return newEntrapmentSummary("Peptide", targets, entrapments, decoys, ratio)
+}
+
+// EntrapmentFDR calculates the entrapment FDR for the proteins
+func (evi ProteinEvidenceList) EntrapmentFDR(entrapTag string, ratio float64) EntrapmentSummary {
+
+	var targets, entrapments, decoys int
+
+	for _, i := range evi {
+		if i.IsDecoy {
+			decoys++
+		} else if cla.IsEntrapment(i.OriginalHeader, entrapTag) {
+			entrapments++
+		} else {
+			targets++
+		}
+	}
+
+	return newEntrapmentSummary("Protein", targets, entrapments, decoys, ratio)
+}
+
+// Print logs the entrapment FDR next to the target-decoy estimation
+func (s EntrapmentSummary) Print() {
+	logrus.WithFields(logrus.Fields{
+		"targets":        s.Targets,
+		"entrapments":    s.Entrapments,
+		"decoys":         s.Decoys,
+		"decoy FDR":      fmt.Sprintf("%.4f", s.DecoyFDR),
+		"entrapment FDR": fmt.Sprintf("%.4f", s.EntrapmentFDR),
+	}).Info(s.Level, " level entrapment validation")
+}
+
+// EntrapmentReport prints the entrapment validation for every evidence level
+func (evi Evidence) EntrapmentReport(entrapTag string, ratio float64) {
+
+	evi.PSM.EntrapmentFDR(entrapTag, ratio).Print()
+	evi.Peptides.EntrapmentFDR(entrapTag, ratio).Print()
+
+	if len(evi.Proteins) > 0 {
+		evi.Proteins.EntrapmentFDR(entrapTag, ratio).Print()
+	}
+}
@@ -0,0 +1,73 @@
+package rep
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewEntrapmentSummary(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		targets     int
+		entrapments int
+		decoys      int
+		ratio       float64
+		decoyFDR    float64
+		entrapFDR   float64
+	}{
+		{"same size entrapment", 95, 5, 1, 1, 0.01, 0.1},
+		{"double size entrapment", 98, 2, 1, 2, 0.01, 0.03},
+		{"half size entrapment", 90, 10, 0, 0.5, 0, 0.3},
+		{"no entrapment hits", 100, 0, 2, 1, 0.02, 0},
+		{"unknown ratio", 90, 10, 1, 0, 0.01, 0},
+		{"nothing accepted", 0, 0, 3, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			s := newEntrapmentSummary("PSM", tt.targets, tt.entrapments, tt.decoys, tt.ratio)
+
+			if s.Targets != tt.targets || s.Entrapments != tt.entrapments || s.Decoys != tt.decoys {
+				t.Errorf("newEntrapmentSummary() counts = %d %d %d, want %d %d %d", s.Targets, s.Entrapments, s.Decoys, tt.targets, tt.entrapments, tt.decoys)
+			}
+
+			if math.Abs(s.DecoyFDR-tt.decoyFDR) > 1e-9 || math.Abs(s.EntrapmentFDR-tt.entrapFDR) > 1e-9 {
+				t.Errorf("newEntrapmentSummary() FDR = %f %f, want %f %f", s.DecoyFDR, s.EntrapmentFDR, tt.decoyFDR, tt.entrapFDR)
+			}
+		})
+	}
+}
+
+func TestEntrapmentFDR(t *testing.T) {
+
+	psms := PSMEvidenceList{
+		{Protein: "sp|P1|A"},
+		{Protein: "sp|P2|B"},
+		{Protein: "sp|P3|C"},
+		{Protein: "entrap_sp|Q1|D"},
+		{Protein: "entrap_sp|Q2|E", MappedProteins: map[string]string{"sp|P1|A": ""}},
+		{Protein: "rev_sp|P4|F", IsDecoy: true},
+	}
+
+	s := psms.EntrapmentFDR("entrap_", 1)
+	if s.Level != "PSM" || s.Targets != 4 || s.Entrapments != 1 || s.Decoys != 1 {
+		t.Errorf("EntrapmentFDR() counts = %d %d %d, want 4 1 1", s.Targets, s.Entrapments, s.Decoys)
+	}
+
+	if math.Abs(s.EntrapmentFDR-0.4) > 1e-9 || math.Abs(s.DecoyFDR-0.2) > 1e-9 {
+		t.Errorf("EntrapmentFDR() = %f and decoy FDR %f, want 0.4 and 0.2", s.EntrapmentFDR, s.DecoyFDR)
+	}
+
+	proteins := ProteinEvidenceList{
+		{OriginalHeader: "sp|P1|A"},
+		{OriginalHeader: "entrap_sp|Q1|D"},
+		{OriginalHeader: "rev_sp|P4|F", IsDecoy: true},
+	}
+
+	s = proteins.EntrapmentFDR("entrap_", 2)
+	if s.Level != "Protein" || s.Targets != 1 || s.Entrapments != 1 || math.Abs(s.EntrapmentFDR-0.75) > 1e-9 {
+		t.Errorf("EntrapmentFDR() = %+v, want 1 target, 1 entrapment and an FDR of 0.75", s)
+	}
+}
@@ -106,7 +106,7 @@ func (evi *Evidence) AssembleIonReport(ion id.PepIDList, decoyTag string) {
 }
 
 // IonReport reports consist on ion reporting
-func (evi IonEvidenceList) IonReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasLabels, hasPrefix, removeContam bool) {
+func (evi IonEvidenceList) IonReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasLabels, hasPrefix, removeContam, hasErrorRates bool) {
 
 	var header string
 	var output string
@@ -129,7 +129,7 @@ func (evi IonEvidenceList) IonReport(workspace, brand, decoyTag string, channels
 	var printSet []*IonEvidence
 	for idx, i := range evi {
 
-		if removeContam && (strings.HasPrefix(i.Protein, "contam_") || strings.HasPrefix(i.Protein, "Cont_")) {
+		if removeContam && (i.IsContaminant || cla.IsContaminant(i.Protein)) {
 			continue
 		}
 
@@ -370,6 +370,10 @@ func (evi IonEvidenceList) IonReport(workspace, brand, decoyTag string, channels
 		)
 	}
 
+	if hasErrorRates {
+		header += "\tQ-Value\tPEP"
+	}
+
 	header += "\n"
 
 	_, e = io.WriteString(bw, header)
@@ -646,6 +650,15 @@ func (evi IonEvidenceList) IonReport(workspace, brand, decoyTag string, channels
 				i.Labels.Channel29.Intensity,
 			)
 		}
+
+		if hasErrorRates {
+			line = fmt.Sprintf("%s\t%.6f\t%.6f",
+				line,
+				i.QValue,
+				i.PEP,
+			)
+		}
+
 		line += "\n"
 
 		_, e = io.WriteString(bw, line)
@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/Nesvilab/philosopher/lib/cla"
+	"github.com/Nesvilab/philosopher/lib/dat"
 	"github.com/Nesvilab/philosopher/lib/id"
 	"github.com/Nesvilab/philosopher/lib/mod"
 	"github.com/Nesvilab/philosopher/lib/msg"
@@ -135,7 +136,7 @@ func (evi *Evidence) AssemblePeptideReport(pep id.PepIDList, decoyTag string) {
 }
 
 // PeptideReport report consist on ion reporting
-func (evi PeptideEvidenceList) PeptideReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasLabels, hasPrefix, removeContam bool) {
+func (evi PeptideEvidenceList) PeptideReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasLabels, hasPrefix, removeContam, hasErrorRates bool) {
 
 	var header string
 	var output string
@@ -156,9 +157,19 @@ func (evi PeptideEvidenceList) PeptideReport(workspace, brand, decoyTag string,
 
 	// building the printing set tat may or not contain decoys
 	var printSet []*PeptideEvidence
+	var hasVariants bool
+	var hasLoci bool
 	for idx, i := range evi {
 
-		if removeContam && (strings.HasPrefix(i.Protein, "contam_") || strings.HasPrefix(i.Protein, "Cont_")) {
+		if len(i.GenomicLoci) > 0 {
+			hasLoci = true
+		}
+
+		if !hasVariants && strings.HasPrefix(i.Protein, dat.VariantPrefix) {
+			hasVariants = true
+		}
+
+		if removeContam && (i.IsContaminant || cla.IsContaminant(i.Protein)) {
 			continue
 		}
 
@@ -396,6 +407,18 @@ func (evi PeptideEvidenceList) PeptideReport(workspace, brand, decoyTag string,
 		)
 	}
 
+	if hasVariants {
+		header += "\tVariant Only"
+	}
+
+	if hasLoci {
+		header += "\tGenomic Coordinates"
+	}
+
+	if hasErrorRates {
+		header += "\tQ-Value\tPEP"
+	}
+
 	header += "\n"
 
 	//_, e = io.WriteString(file, header)
@@ -675,6 +698,29 @@ func (evi PeptideEvidenceList) PeptideReport(workspace, brand, decoyTag string,
 				i.Labels.Channel29.Intensity,
 			)
 		}
+
+		if hasVariants {
+			line = fmt.Sprintf("%s\t%t",
+				line,
+				cla.IsVariantOnly(i.Protein, mappedProteins, dat.VariantPrefix),
+			)
+		}
+
+		if hasLoci {
+			line = fmt.Sprintf("%s\t%s",
+				line,
+				strings.Join(i.GenomicLoci, ", "),
+			)
+		}
+
+		if hasErrorRates {
+			line = fmt.Sprintf("%s\t%.6f\t%.6f",
+				line,
+				i.QValue,
+				i.PEP,
+			)
+		}
+
 		line += "\n"
 
 		_, e = io.WriteString(bw, line)
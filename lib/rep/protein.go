@@ -11,6 +11,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/Nesvilab/philosopher/lib/cla"
 	"github.com/Nesvilab/philosopher/lib/dat"
 	"github.com/Nesvilab/philosopher/lib/id"
 	"github.com/Nesvilab/philosopher/lib/mod"
@@ -50,6 +51,8 @@ func (evi *Evidence) AssembleProteinReport(pro id.ProtIDList, weight float64, db
 		rep.UniqueStrippedPeptides = len(i.UniqueStrippedPeptides)
 		rep.Probability = i.Probability
 		rep.TopPepProb = i.TopPepProb
+		rep.QValue = i.QValue
+		rep.PEP = i.PEP
 
 		rep.TotalPeptides = make(map[string]int)
 		rep.UniquePeptides = make(map[string]int)
@@ -204,6 +207,12 @@ func (evi *Evidence) AssembleProteinReport(pro id.ProtIDList, weight float64, db
 			pe.Sequence = j.Sequence
 			pe.ProteinName = j.ProteinName
 			pe.Organism = j.Organism
+			pe.IsContaminant = j.IsContaminant || cla.IsContaminant(j.PartHeader)
+			pe.GeneSynonyms = j.GeneSynonyms
+			pe.Keywords = j.Keywords
+			pe.SubcellularLocation = j.SubcellularLocation
+			pe.GOTerms = j.GOTerms
+			pe.Features = j.Features
 
 			// some simple headers might not have a full partheader, so we force them to be
 			// the same as the EntryName
@@ -242,7 +251,7 @@ func (evi *Evidence) AssembleProteinReport(pro id.ProtIDList, weight float64, db
 }
 
 // ProteinReport creates the TSV Protein report
-func (eviProteins ProteinEvidenceList) ProteinReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasRazor, uniqueOnly, hasLabels, hasPrefix, removeContam bool) {
+func (eviProteins ProteinEvidenceList) ProteinReport(workspace, brand, decoyTag string, channels int, hasDecoys, hasRazor, uniqueOnly, hasLabels, hasPrefix, removeContam, hasErrorRates bool) {
 
 	var header string
 	var output string
@@ -265,7 +274,7 @@ func (eviProteins ProteinEvidenceList) ProteinReport(workspace, brand, decoyTag
 	var printSet []*ProteinEvidence
 	for idx, i := range eviProteins {
 
-		if removeContam && (strings.HasPrefix(i.OriginalHeader, "contam_") || strings.HasPrefix(i.OriginalHeader, "Cont_")) {
+		if removeContam && (i.IsContaminant || cla.IsContaminant(i.OriginalHeader)) {
 			continue
 		}
 
@@ -280,6 +289,14 @@ func (eviProteins ProteinEvidenceList) ProteinReport(workspace, brand, decoyTag
 
 	header = "Protein\tProtein ID\tEntry Name\tGene\tLength\tOrganism\tProtein Description\tProtein Existence\tCoverage\tProtein Probability\tTop Peptide Probability\tTotal Peptides\tUnique Peptides\tRazor Peptides\tTotal Spectral Count\tUnique Spectral Count\tRazor Spectral Count\tTotal Intensity\tUnique Intensity\tRazor Intensity\tRazor Assigned Modifications\tRazor Observed Modifications\tIndistinguishable Proteins"
 
+	var hasAnnotations bool
+	for _, i := range printSet {
+		if len(i.Keywords) > 0 || len(i.GOTerms) > 0 || len(i.Features) > 0 {
+			hasAnnotations = true
+			break
+		}
+	}
+
 	var headerIndex int
 	for i := range printSet {
 		if printSet[i].UniqueLabels != nil && len(printSet[i].UniqueLabels.Channel1.CustomName) > 0 {
@@ -503,6 +520,14 @@ func (eviProteins ProteinEvidenceList) ProteinReport(workspace, brand, decoyTag
 		)
 	}
 
+	if hasAnnotations {
+		header += "\tGene Synonyms\tKeywords\tSubcellular Location\tGO Terms\tProcessing Features\tPTM Sites"
+	}
+
+	if hasErrorRates {
+		header += "\tQ-Value\tPEP"
+	}
+
 	header += "\n"
 
 	_, e = io.WriteString(bw, header)
@@ -845,6 +870,37 @@ func (eviProteins ProteinEvidenceList) ProteinReport(workspace, brand, decoyTag
 			)
 		}
 
+		if hasAnnotations {
+
+			var processing []string
+			var ptms []string
+			for _, f := range i.Features {
+				if f.IsProcessing() {
+					processing = append(processing, f.String())
+				} else if f.IsPTM() {
+					ptms = append(ptms, f.String())
+				}
+			}
+
+			line = fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s",
+				line,
+				strings.Join(i.GeneSynonyms, ", "),
+				strings.Join(i.Keywords, ", "),
+				strings.Join(i.SubcellularLocation, ", "),
+				strings.Join(i.GOTerms, ", "),
+				strings.Join(processing, ", "),
+				strings.Join(ptms, ", "),
+			)
+		}
+
+		if hasErrorRates {
+			line = fmt.Sprintf("%s\t%.6f\t%.6f",
+				line,
+				i.QValue,
+				i.PEP,
+			)
+		}
+
 		line += "\n"
 
 		_, e = io.WriteString(bw, line)
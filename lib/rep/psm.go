@@ -17,6 +17,7 @@ import (
 
 	"github.com/Nesvilab/philosopher/lib/bio"
 	"github.com/Nesvilab/philosopher/lib/cla"
+	"github.com/Nesvilab/philosopher/lib/dat"
 	"github.com/Nesvilab/philosopher/lib/id"
 )
 
@@ -56,6 +57,7 @@ func (evi *Evidence) AssemblePSMReport(pep id.PepIDList, decoyTag string) {
 		p.Nextscore = i.Nextscore
 		p.SpectralSim = i.SpectralSim
 		p.Rtscore = i.Rtscore
+		p.Rescore = i.Rescore
 		p.Intensity = i.Intensity
 		p.IonMobility = i.IonMobility
 		p.CompensationVoltage = i.CompensationVoltage
@@ -120,16 +122,18 @@ func (evi *Evidence) AssemblePSMReport(pep id.PepIDList, decoyTag string) {
 }
 
 // PSMReport report all psms from study that passed the FDR filter
-func (evi PSMEvidenceList) PSMReport(workspace, brand, decoyTag string, channels int, hasDecoys, isComet, hasLoc, hasIonMob, hasLabels, hasPrefix, removeContam bool) {
+func (evi PSMEvidenceList) PSMReport(workspace, brand, decoyTag string, channels int, hasDecoys, isComet, hasLoc, hasIonMob, hasLabels, hasPrefix, removeContam, hasErrorRates, hasRescore bool) {
 
 	var header string
 	var output string
 	var modMap = make(map[string]string)
 	var modList []string
 	var hasCompVolt bool
+	var hasScanMeta bool
 	var hasClass bool
 	var hasSpectralSim bool
 	var hasRtScore bool
+	var hasVariants bool
 
 	if hasPrefix {
 		output = fmt.Sprintf("%s%s%s_psm.tsv", workspace, string(filepath.Separator), path.Base(workspace))
@@ -151,7 +155,7 @@ func (evi PSMEvidenceList) PSMReport(workspace, brand, decoyTag string, channels
 	var printSet []*PSMEvidence
 	for i := range evi {
 
-		if removeContam && (strings.HasPrefix(evi[i].Protein, "contam_") || strings.HasPrefix(evi[i].Protein, "Cont_")) {
+		if removeContam && (evi[i].IsContaminant || cla.IsContaminant(evi[i].Protein)) {
 			continue
 		}
 
@@ -178,6 +182,14 @@ func (evi PSMEvidenceList) PSMReport(workspace, brand, decoyTag string, channels
 			hasCompVolt = true
 		}
 
+		if evi[i].IonInjectionTime > 0 || len(evi[i].FilterString) > 0 {
+			hasScanMeta = true
+		}
+
+		if !hasVariants && strings.HasPrefix(evi[i].Protein, dat.VariantPrefix) {
+			hasVariants = true
+		}
+
 		if !hasIonMob && evi[i].IonMobility > 0 {
 			hasIonMob = true
 		}
@@ -505,6 +517,22 @@ func (evi PSMEvidenceList) PSMReport(workspace, brand, decoyTag string, channels
 		)
 	}
 
+	if hasVariants {
+		header += "\tVariant Only"
+	}
+
+	if hasScanMeta {
+		header += "\tIon Injection Time\tMaster Scan\tMonoisotopic M/Z\tAGC Target\tFilter String"
+	}
+
+	if hasErrorRates {
+		header += "\tQ-Value\tPEP"
+	}
+
+	if hasRescore {
+		header += "\tRescore"
+	}
+
 	header += "\n"
 
 	_, e = io.WriteString(bw, header)
@@ -907,6 +935,40 @@ func (evi PSMEvidenceList) PSMReport(workspace, brand, decoyTag string, channels
 				i.Labels.Channel29.Intensity,
 			)
 		}
+
+		if hasVariants {
+			line = fmt.Sprintf("%s\t%t",
+				line,
+				cla.IsVariantOnly(i.Protein, mappedProteins, dat.VariantPrefix),
+			)
+		}
+
+		if hasScanMeta {
+			line = fmt.Sprintf("%s\t%.4f\t%s\t%.4f\t%.0f\t%s",
+				line,
+				i.IonInjectionTime,
+				i.MasterScan,
+				i.MonoisotopicMz,
+				i.AGCTarget,
+				i.FilterString,
+			)
+		}
+
+		if hasErrorRates {
+			line = fmt.Sprintf("%s\t%.6f\t%.6f",
+				line,
+				i.QValue,
+				i.PEP,
+			)
+		}
+
+		if hasRescore {
+			line = fmt.Sprintf("%s\t%.4f",
+				line,
+				i.Rescore,
+			)
+		}
+
 		line += "\n"
 
 		_, e = io.WriteString(bw, line)
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"sync"
 
+	"github.com/Nesvilab/philosopher/lib/dat"
 	"github.com/Nesvilab/philosopher/lib/id"
 	"github.com/Nesvilab/philosopher/lib/iso"
 	"github.com/Nesvilab/philosopher/lib/met"
@@ -166,6 +167,15 @@ type PSMEvidence struct {
 	Modifications                    mod.ModificationsSlice
 	MappedProteins                   map[string]string
 	MappedGenes                      map[string]struct{}
+	IsContaminant                    bool
+	IonInjectionTime                 float64
+	MasterScan                       string
+	MonoisotopicMz                   float64
+	AGCTarget                        float64
+	FilterString                     string
+	QValue                           float64
+	PEP                              float64
+	Rescore                          float64
 }
 
 func (e PSMEvidence) IonForm() id.IonFormType {
@@ -227,6 +237,9 @@ type IonEvidence struct {
 	Spectra                  map[id.SpectrumType]int
 	MappedProteins           map[string]int
 	MappedGenes              map[string]struct{}
+	IsContaminant            bool
+	QValue                   float64
+	PEP                      float64
 }
 
 // IonEvidenceList ...
@@ -269,6 +282,10 @@ type PeptideEvidence struct {
 	Labels                 *iso.Labels
 	PhosphoLabels          *iso.Labels
 	Modifications          mod.ModificationsSlice
+	GenomicLoci            []string
+	IsContaminant          bool
+	QValue                 float64
+	PEP                    float64
 }
 
 // PeptideEvidenceList ...
@@ -324,6 +341,13 @@ type ProteinEvidence struct {
 	PhosphoUniqueLabels    *iso.Labels
 	PhosphoURazorLabels    *iso.Labels // Unique + razor
 	Modifications          mod.ModificationsSlice
+	GeneSynonyms           []string
+	Keywords               []string
+	SubcellularLocation    []string
+	GOTerms                []string
+	Features               []dat.Feature
+	QValue                 float64
+	PEP                    float64
 }
 
 // ProteinEvidenceList list
@@ -503,7 +527,7 @@ func Run(m met.Data) {
 		var repoPSM PSMEvidenceList
 		RestorePSM(&repoPSM)
 		// PSM
-		repoPSM.PSMReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, isComet, hasLoc, m.Report.IonMob, hasLabels, m.Report.Prefix, m.Report.RemoveContam)
+		repoPSM.PSMReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, isComet, hasLoc, m.Report.IonMob, hasLabels, m.Report.Prefix, m.Report.RemoveContam, m.Filter.ErrorRates, m.Filter.Rescore)
 	}()
 	wg.Add(1)
 	go func() {
@@ -511,7 +535,7 @@ func Run(m met.Data) {
 		var repoIons IonEvidenceList
 		RestoreIon(&repoIons)
 		// Ion
-		repoIons.IonReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, hasLabels, m.Report.Prefix, m.Report.RemoveContam)
+		repoIons.IonReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, hasLabels, m.Report.Prefix, m.Report.RemoveContam, m.Filter.ErrorRates)
 	}()
 	wg.Add(1)
 	go func() {
@@ -519,7 +543,7 @@ func Run(m met.Data) {
 		// Peptide
 		var repoPeptides PeptideEvidenceList
 		RestorePeptide(&repoPeptides)
-		repoPeptides.PeptideReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, hasLabels, m.Report.Prefix, m.Report.RemoveContam)
+		repoPeptides.PeptideReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, hasLabels, m.Report.Prefix, m.Report.RemoveContam, m.Filter.ErrorRates)
 	}()
 	// Protein
 	if len(m.Filter.Pox) > 0 || m.Filter.Inference {
@@ -528,11 +552,22 @@ func Run(m met.Data) {
 			defer wg.Done()
 			var repoProteins ProteinEvidenceList
 			RestoreProtein(&repoProteins)
-			repoProteins.ProteinReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, m.Filter.Razor, m.Quantify.Unique, hasLabels, m.Report.Prefix, m.Report.RemoveContam)
+			repoProteins.ProteinReport(m.Home, isoBrand, m.Filter.Tag, isoChannels, m.Report.Decoys, m.Filter.Razor, m.Quantify.Unique, hasLabels, m.Report.Prefix, m.Report.RemoveContam, m.Filter.ErrorRates)
 			repoProteins.ProteinFastaReport(m.Home, m.Report.Decoys)
 		}()
 	}
 	wg.Wait()
+
+	if len(m.Database.EntrapTag) > 0 && m.Database.EntrapmentRatio > 0 {
+		var evi Evidence
+		RestorePSM(&evi.PSM)
+		RestorePeptide(&evi.Peptides)
+		if len(m.Filter.Pox) > 0 || m.Filter.Inference {
+			RestoreProtein(&evi.Proteins)
+		}
+		evi.EntrapmentReport(m.Database.EntrapTag, m.Database.EntrapmentRatio)
+	}
+
 	// Modifications
 	repo := New()
 	if len(repo.Modifications.MassBins) > 0 {
@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/Nesvilab/philosopher/lib/cla"
 	"github.com/Nesvilab/philosopher/lib/dat"
 	"github.com/Nesvilab/philosopher/lib/id"
 	"github.com/Nesvilab/philosopher/lib/raz"
@@ -436,6 +437,7 @@ func (evi *Evidence) UpdateLayerswithDatabase(dbBin, decoyTag string) {
 		GeneNames   string
 		Description string
 		Sequence    string
+		Contaminant bool
 	}
 	var recordMap = make(map[string]liteRecord)
 
@@ -449,7 +451,7 @@ func (evi *Evidence) UpdateLayerswithDatabase(dbBin, decoyTag string) {
 		}
 
 		for _, j := range dtb.Records {
-			recordMap[j.PartHeader] = liteRecord{j.ID, j.EntryName, j.GeneNames, strings.TrimSpace(j.ProteinName), j.Sequence}
+			recordMap[j.PartHeader] = liteRecord{j.ID, j.EntryName, j.GeneNames, strings.TrimSpace(j.ProteinName), j.Sequence, j.IsContaminant || cla.IsContaminant(j.PartHeader)}
 		}
 	}
 
@@ -464,6 +466,7 @@ func (evi *Evidence) UpdateLayerswithDatabase(dbBin, decoyTag string) {
 		evi.PSM[i].EntryName = rec.EntryName
 		evi.PSM[i].GeneName = rec.GeneNames
 		evi.PSM[i].ProteinDescription = rec.Description
+		evi.PSM[i].IsContaminant = rec.Contaminant
 
 		// ensure the assignment is a decoy
 		if strings.HasPrefix(evi.PSM[i].Protein, decoyTag) {
@@ -571,6 +574,7 @@ func (evi *Evidence) UpdateLayerswithDatabase(dbBin, decoyTag string) {
 		tmp.EntryName = rec.EntryName
 		tmp.GeneName = rec.GeneNames
 		tmp.ProteinDescription = rec.Description
+		tmp.IsContaminant = rec.Contaminant
 
 		// ensure the assignment is a decoy
 		if strings.HasPrefix(evi.Ions[i].Protein, decoyTag) {
@@ -602,6 +606,7 @@ func (evi *Evidence) UpdateLayerswithDatabase(dbBin, decoyTag string) {
 		evi.Peptides[i].EntryName = rec.EntryName
 		evi.Peptides[i].GeneName = rec.GeneNames
 		evi.Peptides[i].ProteinDescription = rec.Description
+		evi.Peptides[i].IsContaminant = rec.Contaminant
 
 		// ensure the assignment is a decoy
 		if strings.HasPrefix(evi.Peptides[i].Protein, decoyTag) {